@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"url-shortener-go/config"
+	"url-shortener-go/internal/models"
+	"url-shortener-go/internal/repo/postgres"
+	"url-shortener-go/internal/repo/sqlite"
+	"url-shortener-go/internal/transfer"
+)
+
+const progressEvery = 10000
+
+type exporter interface {
+	ExportURLs(ctx context.Context, fn func(*models.URLDetails) error) error
+	Close() error
+}
+
+func main() {
+	formatFlag := flag.String("format", "ndjson", "Output format: ndjson or csv")
+	outputFlag := flag.String("output", "", "Output file (default: stdout)")
+	flag.Parse()
+
+	format, err := transfer.ParseFormat(*formatFlag)
+	if err != nil {
+		log.Fatalf("Invalid --format: %v", err)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	var repo exporter
+	switch cfg.StorageBackend {
+	case config.StorageBackendPostgres:
+		pgRepo, err := postgres.NewRepository(cfg.GetPostgresConnString(), postgres.PoolConfig{
+			MaxOpenConns:    cfg.DBMaxOpenConns,
+			MaxIdleConns:    cfg.DBMaxIdleConns,
+			ConnMaxLifetime: cfg.DBConnMaxLifetime,
+			ConnMaxIdleTime: cfg.DBConnMaxIdleTime,
+		})
+		if err != nil {
+			log.Fatalf("Failed to create PostgreSQL repository: %v", err)
+		}
+		repo = pgRepo
+	case config.StorageBackendSQLite:
+		sqliteRepo, err := sqlite.NewRepository(cfg.SQLitePath)
+		if err != nil {
+			log.Fatalf("Failed to open SQLite database: %v", err)
+		}
+		repo = sqliteRepo
+	default:
+		log.Fatalf("Export does not support STORAGE_BACKEND=%s", cfg.StorageBackend)
+	}
+	defer repo.Close()
+
+	out := os.Stdout
+	if *outputFlag != "" {
+		out, err = os.Create(*outputFlag)
+		if err != nil {
+			log.Fatalf("Error creating %s: %v", *outputFlag, err)
+		}
+		defer out.Close()
+	}
+
+	buffered := bufio.NewWriter(out)
+	writer, err := transfer.NewWriter(buffered, format)
+	if err != nil {
+		log.Fatalf("Error creating writer: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	exported := 0
+	err = repo.ExportURLs(ctx, func(details *models.URLDetails) error {
+		if err := writer.Write(transfer.FromDetails(details)); err != nil {
+			return err
+		}
+		exported++
+		if exported%progressEvery == 0 {
+			log.Printf("Exported %d rows", exported)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Export failed after %d rows: %v", exported, err)
+	}
+
+	if err := writer.Flush(); err != nil {
+		log.Fatalf("Error writing output: %v", err)
+	}
+	if err := buffered.Flush(); err != nil {
+		log.Fatalf("Error writing output: %v", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Exported %d rows\n", exported)
+}
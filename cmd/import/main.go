@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"url-shortener-go/config"
+	"url-shortener-go/internal/bloom"
+	"url-shortener-go/internal/cache/redis"
+	"url-shortener-go/internal/models"
+	"url-shortener-go/internal/repo/postgres"
+	"url-shortener-go/internal/repo/sqlite"
+	"url-shortener-go/internal/service"
+	"url-shortener-go/internal/transfer"
+)
+
+const (
+	conflictSkip      = "skip"
+	conflictOverwrite = "overwrite"
+	conflictFail      = "fail"
+
+	progressEvery   = 1000
+	maxReportErrors = 50
+)
+
+type importer interface {
+	Import(ctx context.Context, details *models.URLDetails, overwrite bool) error
+}
+
+// filteredImporter adds codes to the shared code filter before importing
+// them, so running servers do not reject them. A failed import only leaves a
+// harmless false positive behind.
+type filteredImporter struct {
+	importer
+	filter service.CodeFilter
+}
+
+func (i filteredImporter) Import(ctx context.Context, details *models.URLDetails, overwrite bool) error {
+	if err := i.filter.Add(ctx, details.ShortCode); err != nil {
+		return fmt.Errorf("failed to update code filter: %w", err)
+	}
+	return i.importer.Import(ctx, details, overwrite)
+}
+
+// invalidatingImporter drops each imported code from the shared cache and
+// tells running servers to drop their local copies, so an overwritten link
+// stops redirecting to its old destination and a code cached as unknown is
+// served right away.
+type invalidatingImporter struct {
+	importer
+	cache *redis.CacheRepository
+}
+
+func (i invalidatingImporter) Import(ctx context.Context, details *models.URLDetails, overwrite bool) error {
+	if err := i.importer.Import(ctx, details, overwrite); err != nil {
+		return err
+	}
+	key := service.CacheKey(details.ShortCode)
+	if err := errors.Join(i.cache.Delete(ctx, key), i.cache.PublishInvalidation(ctx, key)); err != nil {
+		return fmt.Errorf("imported, but failed to invalidate the cached link: %w", err)
+	}
+	return nil
+}
+
+type report struct {
+	read     int
+	imported int
+	skipped  int
+	invalid  int
+	failed   int
+	errors   []string
+}
+
+func (r *report) addError(line int, err error) {
+	if len(r.errors) < maxReportErrors {
+		r.errors = append(r.errors, fmt.Sprintf("line %d: %v", line, err))
+	}
+}
+
+func main() {
+	fileFlag := flag.String("file", "", "Path to a CSV or JSONL file to import")
+	formatFlag := flag.String("format", "", "Input format: csv or jsonl (default: from file extension)")
+	dryRunFlag := flag.Bool("dry-run", false, "Validate the file without writing anything")
+	conflictFlag := flag.String("on-conflict", conflictSkip, "What to do with existing short codes: skip, overwrite or fail")
+	timeoutFlag := flag.Duration("timeout", 5*time.Second, "Timeout for each row write")
+	flag.Parse()
+
+	if *fileFlag == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	switch *conflictFlag {
+	case conflictSkip, conflictOverwrite, conflictFail:
+	default:
+		log.Fatalf("Invalid --on-conflict %q: must be skip, overwrite or fail", *conflictFlag)
+	}
+
+	format, err := transfer.FormatFromPath(*fileFlag)
+	if *formatFlag != "" {
+		format, err = transfer.ParseFormat(*formatFlag)
+	}
+	if err != nil {
+		log.Fatalf("Cannot determine input format: %v", err)
+	}
+
+	file, err := os.Open(*fileFlag)
+	if err != nil {
+		log.Fatalf("Error opening %s: %v", *fileFlag, err)
+	}
+	defer file.Close()
+
+	reader, err := transfer.NewReader(file, format)
+	if err != nil {
+		log.Fatalf("Error reading %s: %v", *fileFlag, err)
+	}
+
+	// A dry run needs no database, so only the destination settings are used.
+	cfg := config.LoadUnvalidated()
+	if !*dryRunFlag {
+		cfg, err = config.Load()
+		if err != nil {
+			log.Fatalf("Failed to load config: %v", err)
+		}
+	}
+	check := service.NewImportCheck(service.DestinationPolicy{
+		Schemes:               cfg.AllowedSchemes,
+		AllowedDomains:        cfg.AllowedDomains,
+		BlockedDomains:        cfg.BlockedDomains,
+		AllowPrivateAddresses: cfg.AllowPrivateDestinations,
+	}, cfg.BaseURL, cfg.AliasDomains)
+
+	var repo importer
+	if !*dryRunFlag {
+		switch cfg.StorageBackend {
+		case config.StorageBackendPostgres:
+			pgRepo, err := postgres.NewRepository(cfg.GetPostgresConnString(), postgres.PoolConfig{
+				MaxOpenConns:    cfg.DBMaxOpenConns,
+				MaxIdleConns:    cfg.DBMaxIdleConns,
+				ConnMaxLifetime: cfg.DBConnMaxLifetime,
+				ConnMaxIdleTime: cfg.DBConnMaxIdleTime,
+			})
+			if err != nil {
+				log.Fatalf("Failed to create PostgreSQL repository: %v", err)
+			}
+			defer pgRepo.Close()
+			repo = pgRepo
+
+			cache, err := redis.NewCacheRepository(cfg.GetRedisOpts())
+			if err != nil {
+				log.Fatalf("Failed to connect to Redis to invalidate cached links: %v", err)
+			}
+			defer cache.Close()
+			if cfg.CodeFilterEnabled {
+				params := bloom.Estimate(cfg.CodeFilterCapacity, bloom.DefaultFalsePositiveRate)
+				repo = filteredImporter{importer: repo, filter: redis.NewCodeFilter(cache, params)}
+			}
+			repo = invalidatingImporter{importer: repo, cache: cache}
+		case config.StorageBackendSQLite:
+			sqliteRepo, err := sqlite.NewRepository(cfg.SQLitePath)
+			if err != nil {
+				log.Fatalf("Failed to open SQLite database: %v", err)
+			}
+			defer sqliteRepo.Close()
+			repo = sqliteRepo
+		default:
+			log.Fatalf("Import does not support STORAGE_BACKEND=%s", cfg.StorageBackend)
+		}
+	}
+
+	result, err := run(reader, repo, check, *conflictFlag, *timeoutFlag)
+	printReport(result, *dryRunFlag)
+	if err != nil {
+		log.Fatalf("Import aborted: %v", err)
+	}
+	if result.invalid > 0 || result.failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// run imports every record from reader whose destination passes check. A nil
+// repo validates without writing.
+func run(reader transfer.Reader, repo importer, check *service.ImportCheck, onConflict string, timeout time.Duration) (*report, error) {
+	result := &report{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return result, nil
+		}
+
+		var recordErr *transfer.RecordError
+		if errors.As(err, &recordErr) {
+			result.read++
+			result.invalid++
+			result.addError(recordErr.Line, recordErr.Err)
+			continue
+		}
+		if err != nil {
+			return result, err
+		}
+
+		line := reader.Line()
+		result.read++
+		if result.read%progressEvery == 0 {
+			log.Printf("Processed %d rows (%d imported, %d skipped, %d errors)", result.read, result.imported, result.skipped, result.invalid+result.failed)
+		}
+
+		if service.IsReservedCode(record.Code) {
+			result.invalid++
+			result.addError(line, fmt.Errorf("code %q: %w", record.Code, service.ErrReservedCode))
+			continue
+		}
+		if err := check.Check(record.OriginalURL); err != nil {
+			result.invalid++
+			result.addError(line, fmt.Errorf("code %q: %w", record.Code, err))
+			continue
+		}
+
+		if repo == nil {
+			result.imported++
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err = repo.Import(ctx, record.Details(), onConflict == conflictOverwrite)
+		cancel()
+
+		switch {
+		case err == nil:
+			result.imported++
+		case errors.Is(err, service.ErrConflict) && onConflict == conflictSkip:
+			result.skipped++
+		case errors.Is(err, service.ErrConflict):
+			result.failed++
+			result.addError(line, fmt.Errorf("code %q already exists", record.Code))
+			return result, fmt.Errorf("short code %q already exists", record.Code)
+		default:
+			result.failed++
+			result.addError(line, fmt.Errorf("code %q: %w", record.Code, err))
+		}
+	}
+}
+
+func printReport(result *report, dryRun bool) {
+	if dryRun {
+		fmt.Println("Dry run: nothing was written.")
+		fmt.Printf("Rows read: %d, would import: %d, invalid: %d\n", result.read, result.imported, result.invalid)
+	} else {
+		fmt.Printf("Rows read: %d, imported: %d, skipped: %d, invalid: %d, failed: %d\n",
+			result.read, result.imported, result.skipped, result.invalid, result.failed)
+	}
+
+	if len(result.errors) == 0 {
+		return
+	}
+	fmt.Println("Errors:")
+	for _, msg := range result.errors {
+		fmt.Println("  " + msg)
+	}
+	if hidden := result.invalid + result.failed - len(result.errors); hidden > 0 {
+		fmt.Printf("  ... and %d more\n", hidden)
+	}
+}
@@ -29,6 +29,9 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
+	if cfg.StorageBackend != config.StorageBackendPostgres {
+		log.Fatalf("Migrations only apply to STORAGE_BACKEND=postgres; %s creates its schema on startup", cfg.StorageBackend)
+	}
 
 	db, err := postgres.WithInstance(
 		mustOpenDB(cfg.GetPostgresConnString()),
@@ -7,55 +7,126 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"url-shortener-go/config"
-	"url-shortener-go/internal/cache/redis"
+	"url-shortener-go/internal/health"
 	"url-shortener-go/internal/httpapi"
 	httpmiddleware "url-shortener-go/internal/httpapi/middleware"
-	"url-shortener-go/internal/repo/postgres"
+	"url-shortener-go/internal/jobs"
+	"url-shortener-go/internal/metrics"
 	"url-shortener-go/internal/service"
 	"url-shortener-go/internal/telemetry"
 )
 
+// Job names, used in logs, metrics and job locks.
+const (
+	cleanupJobName      = "cleanup_expired"
+	archivePurgeJobName = "archive_purge"
+)
+
 func main() {
 	cfg, err := config.Load()
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
+	if err := cfg.ValidateServer(); err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
 
 	logger := telemetry.NewLogger()
 
-	repo, err := postgres.NewRepository(cfg.GetPostgresConnString(), postgres.PoolConfig{
-		MaxOpenConns:    cfg.DBMaxOpenConns,
-		MaxIdleConns:    cfg.DBMaxIdleConns,
-		ConnMaxLifetime: cfg.DBConnMaxLifetime,
-		ConnMaxIdleTime: cfg.DBConnMaxIdleTime,
+	shutdownTracing, err := telemetry.SetupTracing(context.Background(), telemetry.TracingConfig{
+		Exporter:    cfg.TracingExporter,
+		FilePath:    cfg.TracingFile,
+		SampleRatio: cfg.TracingSampleRatio,
 	})
 	if err != nil {
-		log.Fatalf("Failed to create PostgreSQL repository: %v", err)
+		log.Fatalf("Failed to set up tracing: %v", err)
 	}
-	defer func() {
-		if err := repo.Close(); err != nil {
-			log.Printf("Error closing repository: %v", err)
-		}
-	}()
 
-	cache, err := redis.NewCacheRepository(cfg.GetRedisOpts())
+	metrics := metrics.New()
+
+	storage, err := openStorage(cfg, logger, metrics)
 	if err != nil {
-		log.Fatalf("Failed to create Redis cache: %v", err)
+		log.Fatalf("Failed to open storage: %v", err)
+	}
+	defer storage.Close()
+
+	archive, err := openArchive(cfg, storage.repo)
+	if err != nil {
+		log.Fatalf("Failed to open archive: %v", err)
+	}
+
+	opts := []service.Option{
+		service.WithIPHashSalt(cfg.ClickIPSalt),
+		service.WithClickFlushInterval(cfg.ClickFlushInterval),
+		service.WithClickFlushSize(cfg.ClickFlushSize),
+		service.WithNegativeCacheTTL(cfg.NegativeCacheTTL),
+		service.WithCacheTimeout(cfg.CacheTimeout),
+		service.WithCircuitBreakers(cfg.BreakerThreshold, cfg.BreakerCooldown),
+		service.WithReadOnly(cfg.ReadOnly),
+		service.WithCleanupBatchSize(cfg.CleanupBatchSize),
+		service.WithDestinationPolicy(service.DestinationPolicy{
+			Schemes:               cfg.AllowedSchemes,
+			AllowedDomains:        cfg.AllowedDomains,
+			BlockedDomains:        cfg.BlockedDomains,
+			AllowPrivateAddresses: cfg.AllowPrivateDestinations,
+		}),
+		service.WithSelfLinks(cfg.AliasDomains, cfg.SelfLinks == config.SelfLinksResolve),
+		service.WithMetrics(metrics),
+	}
+	if storage.codeFilter != nil {
+		opts = append(opts, service.WithCodeFilter(storage.codeFilter))
 	}
-	defer func() {
-		if err := cache.Close(); err != nil {
-			log.Printf("Error closing cache: %v", err)
+	if archive != nil {
+		opts = append(opts, service.WithArchive(archive, cfg.ArchiveRetention))
+		logger.Info("archiving expired links", "mode", cfg.ArchiveMode, "retention", cfg.ArchiveRetention)
+	}
+	service := service.New(storage.repo, storage.cache, cfg.BaseURL, cfg.CacheTTL, cfg.RequestTimeout, opts...)
+	if cfg.ReadOnly {
+		logger.Warn("read-only mode; writes are rejected with 503")
+	}
+
+	if storage.codeFilter != nil {
+		go func() {
+			start := time.Now()
+			if err := service.LoadCodeFilter(context.Background()); err != nil {
+				logger.Error("failed to load code filter; lookups will not use it", "error", err)
+				return
+			}
+			logger.Info("code filter loaded", "duration", time.Since(start))
+		}()
+	}
+	scheduler := jobs.NewScheduler(storage.locker, logger, metrics)
+	if cfg.CleanupInterval > 0 && !cfg.ReadOnly {
+		scheduler.Add(jobs.Job{
+			Name:     cleanupJobName,
+			Interval: cfg.CleanupInterval,
+			Run:      service.CleanupExpiredURLs,
+		})
+		if archive != nil && cfg.ArchiveRetention > 0 {
+			scheduler.Add(jobs.Job{
+				Name:     archivePurgeJobName,
+				Interval: cfg.CleanupInterval,
+				Run:      service.PurgeArchive,
+			})
 		}
-	}()
+	}
+	scheduler.Start()
 
-	service := service.New(repo, cache, cfg.BaseURL, cfg.CacheTTL, cfg.RequestTimeout)
-	handlers := httpapi.NewHandlers(service)
+	checker := health.NewChecker(cfg.HealthCheckTimeout, storage.checks...)
+	handlerOpts := []httpapi.HandlerOption{httpapi.WithHealthChecker(checker), httpapi.WithLogger(logger)}
+	if cfg.EnableMetrics {
+		handlerOpts = append(handlerOpts, httpapi.WithMetricsHandler(metrics.Handler()))
+	}
+	handlers := httpapi.NewHandlers(service, handlerOpts...)
 
 	router := httpapi.SetupRoutes(handlers, cfg.EnableSwagger)
 
 	router.Use(telemetry.RequestIDMiddleware)
+	router.Use(telemetry.TracingMiddleware)
+	router.Use(metrics.Middleware)
 	router.Use(telemetry.LoggingMiddleware(logger))
 	router.Use(telemetry.RecoveryMiddleware(logger))
 	router.Use(httpmiddleware.CorsMiddleware)
@@ -81,6 +152,14 @@ func main() {
 	<-quit
 	logger.Info("shutting down server")
 
+	// Fail readiness first so load balancers stop sending new requests while
+	// the listener is still open.
+	checker.Drain()
+	if cfg.Server.ShutdownDrainDelay > 0 {
+		logger.Info("draining before shutdown", "delay", cfg.Server.ShutdownDrainDelay)
+		time.Sleep(cfg.Server.ShutdownDrainDelay)
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.GracefulShutdownTimeout)
 	defer cancel()
 
@@ -88,5 +167,15 @@ func main() {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
+	if err := scheduler.Stop(ctx); err != nil {
+		logger.Error("failed to stop background jobs", "error", err)
+	}
+	if err := service.Close(ctx); err != nil {
+		logger.Error("failed to flush buffered clicks", "error", err)
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Error("failed to flush traces", "error", err)
+	}
+
 	logger.Info("server exited gracefully")
 }
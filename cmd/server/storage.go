@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"time"
+
+	"url-shortener-go/config"
+	"url-shortener-go/internal/archive"
+	"url-shortener-go/internal/bloom"
+	memorycache "url-shortener-go/internal/cache/memory"
+	"url-shortener-go/internal/cache/redis"
+	"url-shortener-go/internal/cache/tiered"
+	"url-shortener-go/internal/health"
+	"url-shortener-go/internal/jobs"
+	"url-shortener-go/internal/metrics"
+	memoryrepo "url-shortener-go/internal/repo/memory"
+	"url-shortener-go/internal/repo/postgres"
+	"url-shortener-go/internal/repo/sqlite"
+	"url-shortener-go/internal/service"
+)
+
+// redisStartupTimeout bounds the startup check that only logs whether Redis
+// is up.
+const redisStartupTimeout = 2 * time.Second
+
+// storage is the repository and cache selected by STORAGE_BACKEND. codeFilter
+// is nil unless CODE_FILTER_ENABLED is set; the memory backend never uses one.
+// checks back the readiness probe. locker is nil unless replicas share the
+// database.
+type storage struct {
+	repo       service.Repository
+	cache      service.Cache
+	codeFilter service.CodeFilter
+	checks     []health.Check
+	locker     jobs.Locker
+	closers    []func() error
+}
+
+// openStorage also registers pool stats with m and counts cache lookups per
+// tier.
+func openStorage(cfg *config.Config, logger *slog.Logger, m *metrics.Metrics) (*storage, error) {
+	switch cfg.StorageBackend {
+	case config.StorageBackendMemory:
+		logger.Warn("using in-memory storage; links are lost on restart")
+		return &storage{
+			repo:  memoryrepo.NewRepository(),
+			cache: m.InstrumentCache(metrics.TierMemory, memorycache.NewCacheRepository(0)),
+		}, nil
+	case config.StorageBackendSQLite:
+		repo, err := sqlite.NewRepository(cfg.SQLitePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+		}
+		logger.Info("using SQLite storage", "path", cfg.SQLitePath)
+		m.MustRegister(metrics.NewDBStatsCollector(repo.Stats))
+		s := &storage{
+			repo:    repo,
+			cache:   m.InstrumentCache(metrics.TierMemory, memorycache.NewCacheRepository(0)),
+			checks:  []health.Check{{Name: "database", Probe: repo.Ping}},
+			closers: []func() error{repo.Close},
+		}
+		if cfg.CodeFilterEnabled {
+			s.codeFilter = bloom.New(codeFilterParams(cfg))
+		}
+		return s, nil
+	case config.StorageBackendPostgres:
+		repo, err := postgres.NewRepository(cfg.GetPostgresConnString(), postgres.PoolConfig{
+			MaxOpenConns:    cfg.DBMaxOpenConns,
+			MaxIdleConns:    cfg.DBMaxIdleConns,
+			ConnMaxLifetime: cfg.DBConnMaxLifetime,
+			ConnMaxIdleTime: cfg.DBConnMaxIdleTime,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create PostgreSQL repository: %w", err)
+		}
+
+		// Start without Redis if it is down; lookups go to the database until
+		// it comes back.
+		cache := redis.OpenCacheRepository(cfg.GetRedisOpts())
+		pingCtx, cancel := context.WithTimeout(context.Background(), redisStartupTimeout)
+		if err := cache.Ping(pingCtx); err != nil {
+			logger.Warn("Redis is unavailable; serving without cache until it recovers", "error", err)
+		}
+		cancel()
+		m.MustRegister(metrics.NewDBStatsCollector(repo.Stats), metrics.NewRedisStatsCollector(cache.PoolStats))
+
+		s := &storage{
+			repo:  repo,
+			cache: m.InstrumentCache(metrics.TierRedis, cache),
+			checks: []health.Check{
+				{Name: "database", Probe: repo.Ping},
+				{Name: "migrations", Probe: repo.CheckSchema},
+				{Name: "cache", Probe: cache.Ping, Optional: true},
+			},
+			locker:  advisoryLocker(repo),
+			closers: []func() error{cache.Close, repo.Close},
+		}
+		if cfg.CodeFilterEnabled {
+			s.codeFilter = redis.NewCodeFilter(cache, codeFilterParams(cfg))
+		}
+		if cfg.CacheLocalSize <= 0 || cfg.CacheLocalTTL <= 0 {
+			return s, nil
+		}
+
+		local, err := tiered.New(s.cache, cache, cfg.CacheLocalSize, cfg.CacheLocalTTL,
+			tiered.WithLocalObserver(func(hit bool) { m.CacheLookup(metrics.TierLocal, hit) }))
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("failed to subscribe to cache invalidations: %w", err)
+		}
+		s.cache = local
+		s.closers = append([]func() error{local.Close}, s.closers...)
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.StorageBackend)
+	}
+}
+
+// Close releases connections, cache first.
+func (s *storage) Close() {
+	for _, closeFn := range s.closers {
+		if err := closeFn(); err != nil {
+			log.Printf("Error closing storage: %v", err)
+		}
+	}
+}
+
+// advisoryLocker elects job leaders through Postgres advisory locks.
+func advisoryLocker(repo *postgres.Repository) jobs.Locker {
+	return jobs.LockerFunc(func(ctx context.Context, name string) (jobs.Lock, error) {
+		lock, err := repo.TryAdvisoryLock(ctx, name)
+		if lock == nil {
+			return nil, err
+		}
+		return lock, nil
+	})
+}
+
+// openArchive returns the archive selected by ARCHIVE_MODE, or nil when
+// expired links are deleted outright.
+func openArchive(cfg *config.Config, repo service.Repository) (service.Archive, error) {
+	switch cfg.ArchiveMode {
+	case config.ArchiveModeTable:
+		tables, ok := repo.(service.Archive)
+		if !ok {
+			return nil, fmt.Errorf("storage backend %q cannot archive to tables", cfg.StorageBackend)
+		}
+		return tables, nil
+	case config.ArchiveModeFile:
+		files, err := archive.NewFileArchive(cfg.ArchiveDir)
+		if err != nil {
+			return nil, err
+		}
+		return files, nil
+	default:
+		return nil, nil
+	}
+}
+
+func codeFilterParams(cfg *config.Config) bloom.Params {
+	return bloom.Estimate(cfg.CodeFilterCapacity, bloom.DefaultFalsePositiveRate)
+}
@@ -12,15 +12,38 @@ import (
 	"github.com/joho/godotenv"
 )
 
+const (
+	StorageBackendPostgres = "postgres"
+	StorageBackendMemory   = "memory"
+	StorageBackendSQLite   = "sqlite"
+)
+
+const (
+	ArchiveModeNone  = "none"
+	ArchiveModeTable = "table"
+	ArchiveModeFile  = "file"
+)
+
+// SELF_LINKS modes: how destinations on BASE_URL's host or ALIAS_DOMAINS
+// are handled.
+const (
+	SelfLinksResolve = "resolve"
+	SelfLinksReject  = "reject"
+)
+
 type ServerConfig struct {
 	Address                 string
 	ReadTimeout             time.Duration
 	WriteTimeout            time.Duration
 	IdleTimeout             time.Duration
 	GracefulShutdownTimeout time.Duration
+	ShutdownDrainDelay      time.Duration
 }
 
 type Config struct {
+	StorageBackend string
+	SQLitePath     string
+
 	DBHost     string
 	DBPort     string
 	DBUser     string
@@ -36,18 +59,51 @@ type Config struct {
 	MigrationsPath string
 	APIKey         string
 	EnableSwagger  bool
+	EnableMetrics  bool
+	ClickIPSalt    string
+
+	AllowedSchemes           []string
+	AllowedDomains           []string
+	BlockedDomains           []string
+	AllowPrivateDestinations bool
+	AliasDomains             []string
+	SelfLinks                string
 
 	Server ServerConfig
 
-	CacheTTL          time.Duration
-	RequestTimeout    time.Duration
-	DBMaxOpenConns    int
-	DBMaxIdleConns    int
-	DBConnMaxLifetime time.Duration
-	DBConnMaxIdleTime time.Duration
+	CacheTTL           time.Duration
+	CacheLocalSize     int
+	CacheLocalTTL      time.Duration
+	NegativeCacheTTL   time.Duration
+	CodeFilterEnabled  bool
+	CodeFilterCapacity int
+	CacheTimeout       time.Duration
+	HealthCheckTimeout time.Duration
+	BreakerThreshold   int
+	BreakerCooldown    time.Duration
+	ReadOnly           bool
+	RequestTimeout     time.Duration
+	ClickFlushInterval time.Duration
+	ClickFlushSize     int
+	CleanupInterval    time.Duration
+	CleanupBatchSize   int
+	ArchiveMode        string
+	ArchiveDir         string
+	ArchiveRetention   time.Duration
+	DBMaxOpenConns     int
+	DBMaxIdleConns     int
+	DBConnMaxLifetime  time.Duration
+	DBConnMaxIdleTime  time.Duration
+
+	TracingExporter    string
+	TracingFile        string
+	TracingSampleRatio float64
 }
 
 type Env struct {
+	StorageBackend string
+	SQLitePath     string
+
 	DBHost     string
 	DBPort     string
 	DBUser     string
@@ -63,34 +119,75 @@ type Env struct {
 	MigrationsPath string
 	APIKey         string
 	EnableSwagger  bool
+	EnableMetrics  bool
+	ClickIPSalt    string
 	Address        string
 
+	AllowedSchemes           []string
+	AllowedDomains           []string
+	BlockedDomains           []string
+	AllowPrivateDestinations bool
+	AliasDomains             []string
+	SelfLinks                string
+
 	ReadTimeout             time.Duration
 	WriteTimeout            time.Duration
 	IdleTimeout             time.Duration
 	GracefulShutdownTimeout time.Duration
+	ShutdownDrainDelay      time.Duration
+	HealthCheckTimeout      time.Duration
 	RequestTimeout          time.Duration
 	CacheTTL                time.Duration
+	CacheLocalSize          int
+	CacheLocalTTL           time.Duration
+	NegativeCacheTTL        time.Duration
+	CodeFilterEnabled       bool
+	CodeFilterCapacity      int
+	CacheTimeout            time.Duration
+	BreakerThreshold        int
+	BreakerCooldown         time.Duration
+	ReadOnly                bool
+	ClickFlushInterval      time.Duration
+	ClickFlushSize          int
+	CleanupInterval         time.Duration
+	CleanupBatchSize        int
+	ArchiveMode             string
+	ArchiveDir              string
+	ArchiveRetention        time.Duration
 
 	DBMaxOpenConns    int
 	DBMaxIdleConns    int
 	DBConnMaxLifetime time.Duration
 	DBConnMaxIdleTime time.Duration
+
+	TracingExporter    string
+	TracingFile        string
+	TracingSampleRatio float64
 }
 
 func Load() (*Config, error) {
+	env := loadEnv()
+	if err := env.Validate(); err != nil {
+		return nil, err
+	}
+
+	return env.ToConfig(), nil
+}
+
+// LoadUnvalidated reads the configuration like Load but requires no setting,
+// for tools such as a dry-run import that only use a few of them.
+func LoadUnvalidated() *Config {
+	return loadEnv().ToConfig()
+}
+
+func loadEnv() Env {
 	if _, err := os.Stat(".env"); err == nil {
 		if err = godotenv.Load(); err != nil {
 			log.Printf("Failed to load .env: %v", err)
 		}
 	}
 
-	env := FromEnv(os.Environ())
-	if err := env.Validate(); err != nil {
-		return nil, err
-	}
-
-	return env.ToConfig(), nil
+	return FromEnv(os.Environ())
 }
 
 func (c *Config) GetPostgresConnString() string {
@@ -108,6 +205,9 @@ func (c *Config) GetRedisOpts() *redis.Options {
 func FromEnv(envVars []string) Env {
 	envMap := toEnvMap(envVars)
 	return Env{
+		StorageBackend: getString(envMap, "STORAGE_BACKEND", StorageBackendPostgres),
+		SQLitePath:     getString(envMap, "SQLITE_PATH", "url-shortener.db"),
+
 		DBHost:     getRequiredString(envMap, "DB_HOST"),
 		DBPort:     getRequiredString(envMap, "DB_PORT"),
 		DBUser:     getRequiredString(envMap, "DB_USER"),
@@ -123,42 +223,105 @@ func FromEnv(envVars []string) Env {
 		MigrationsPath: getRequiredString(envMap, "MIGRATIONS_PATH"),
 		APIKey:         getRequiredString(envMap, "API_KEY"),
 		EnableSwagger:  getBool(envMap, "ENABLE_SWAGGER", false),
+		EnableMetrics:  getBool(envMap, "ENABLE_METRICS", true),
+		ClickIPSalt:    getRequiredString(envMap, "CLICK_IP_SALT"),
 		Address:        getRequiredString(envMap, "ADDRESS"),
 
+		AllowedSchemes:           getList(envMap, "ALLOWED_SCHEMES", []string{"http", "https"}),
+		AllowedDomains:           getList(envMap, "ALLOWED_DOMAINS", nil),
+		BlockedDomains:           getList(envMap, "BLOCKED_DOMAINS", nil),
+		AllowPrivateDestinations: getBool(envMap, "ALLOW_PRIVATE_DESTINATIONS", false),
+		AliasDomains:             getList(envMap, "ALIAS_DOMAINS", nil),
+		SelfLinks:                getString(envMap, "SELF_LINKS", SelfLinksReject),
+
 		ReadTimeout:             getDuration(envMap, "READ_TIMEOUT", 15*time.Second),
 		WriteTimeout:            getDuration(envMap, "WRITE_TIMEOUT", 15*time.Second),
 		IdleTimeout:             getDuration(envMap, "IDLE_TIMEOUT", 60*time.Second),
 		GracefulShutdownTimeout: getDuration(envMap, "GRACEFUL_SHUTDOWN_TIMEOUT", 5*time.Second),
+		ShutdownDrainDelay:      getDuration(envMap, "SHUTDOWN_DRAIN_DELAY", 0),
+		HealthCheckTimeout:      getDuration(envMap, "HEALTH_CHECK_TIMEOUT", 1*time.Second),
 		RequestTimeout:          getDuration(envMap, "REQUEST_TIMEOUT", 5*time.Second),
 		CacheTTL:                getDuration(envMap, "CACHE_TTL", 1*time.Hour),
+		CacheLocalSize:          getInt(envMap, "CACHE_LOCAL_SIZE", 10000),
+		CacheLocalTTL:           getDuration(envMap, "CACHE_LOCAL_TTL", 10*time.Second),
+		NegativeCacheTTL:        getDuration(envMap, "NEGATIVE_CACHE_TTL", 30*time.Second),
+		CodeFilterEnabled:       getBool(envMap, "CODE_FILTER_ENABLED", false),
+		CodeFilterCapacity:      getInt(envMap, "CODE_FILTER_CAPACITY", 1000000),
+		CacheTimeout:            getDuration(envMap, "CACHE_TIMEOUT", 100*time.Millisecond),
+		BreakerThreshold:        getInt(envMap, "BREAKER_THRESHOLD", 5),
+		BreakerCooldown:         getDuration(envMap, "BREAKER_COOLDOWN", 10*time.Second),
+		ReadOnly:                getBool(envMap, "READ_ONLY", false),
+		ClickFlushInterval:      getDuration(envMap, "CLICK_FLUSH_INTERVAL", 1*time.Second),
+		ClickFlushSize:          getInt(envMap, "CLICK_FLUSH_SIZE", 500),
+		CleanupInterval:         getDuration(envMap, "CLEANUP_INTERVAL", 1*time.Hour),
+		CleanupBatchSize:        getInt(envMap, "CLEANUP_BATCH_SIZE", 1000),
+		ArchiveMode:             getString(envMap, "ARCHIVE_MODE", ArchiveModeNone),
+		ArchiveDir:              getString(envMap, "ARCHIVE_DIR", "archive"),
+		ArchiveRetention:        getDuration(envMap, "ARCHIVE_RETENTION", 0),
 
 		DBMaxOpenConns:    getInt(envMap, "DB_MAX_OPEN_CONNS", 25),
 		DBMaxIdleConns:    getInt(envMap, "DB_MAX_IDLE_CONNS", 10),
 		DBConnMaxLifetime: getDuration(envMap, "DB_CONN_MAX_LIFETIME", 30*time.Minute),
 		DBConnMaxIdleTime: getDuration(envMap, "DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
+
+		TracingExporter:    getString(envMap, "TRACING_EXPORTER", "none"),
+		TracingFile:        getString(envMap, "TRACING_FILE", "traces.jsonl"),
+		TracingSampleRatio: getFloat(envMap, "TRACING_SAMPLE_RATIO", 1),
 	}
 }
 
+type requiredVar struct {
+	name  string
+	value string
+}
+
 func (e Env) Validate() error {
 	var missing []string
-	required := []struct {
-		name  string
-		value string
-	}{
-		{"DB_HOST", e.DBHost},
-		{"DB_PORT", e.DBPort},
-		{"DB_USER", e.DBUser},
-		{"DB_PASSWORD", e.DBPassword},
-		{"DB_NAME", e.DBName},
-		{"REDIS_HOST", e.RedisHost},
-		{"REDIS_PORT", e.RedisPort},
-		{"REDIS_PASSWORD", e.RedisPassword},
+	required := []requiredVar{
 		{"BASE_URL", e.BaseURL},
-		{"MIGRATIONS_PATH", e.MigrationsPath},
 		{"API_KEY", e.APIKey},
 		{"ADDRESS", e.Address},
 	}
 
+	switch e.StorageBackend {
+	case StorageBackendPostgres:
+		required = append(required,
+			requiredVar{"DB_HOST", e.DBHost},
+			requiredVar{"DB_PORT", e.DBPort},
+			requiredVar{"DB_USER", e.DBUser},
+			requiredVar{"DB_PASSWORD", e.DBPassword},
+			requiredVar{"DB_NAME", e.DBName},
+			requiredVar{"REDIS_HOST", e.RedisHost},
+			requiredVar{"REDIS_PORT", e.RedisPort},
+			requiredVar{"REDIS_PASSWORD", e.RedisPassword},
+			requiredVar{"MIGRATIONS_PATH", e.MigrationsPath},
+		)
+	case StorageBackendMemory, StorageBackendSQLite:
+	default:
+		return errors.New("unknown STORAGE_BACKEND: " + e.StorageBackend)
+	}
+
+	switch e.ArchiveMode {
+	case ArchiveModeNone, ArchiveModeTable:
+	case ArchiveModeFile:
+		required = append(required, requiredVar{"ARCHIVE_DIR", e.ArchiveDir})
+	default:
+		return errors.New("unknown ARCHIVE_MODE: " + e.ArchiveMode)
+	}
+
+	switch e.SelfLinks {
+	case SelfLinksResolve, SelfLinksReject:
+	default:
+		return errors.New("unknown SELF_LINKS: " + e.SelfLinks)
+	}
+	for _, domain := range e.AliasDomains {
+		// Hosts are matched without their port, so an entry with a scheme,
+		// port or path would never match.
+		if strings.ContainsAny(domain, ":/") {
+			return errors.New("ALIAS_DOMAINS entries must be host names without a scheme, port or path: " + domain)
+		}
+	}
+
 	for _, item := range required {
 		if strings.TrimSpace(item.value) == "" {
 			missing = append(missing, item.name)
@@ -172,8 +335,20 @@ func (e Env) Validate() error {
 	return nil
 }
 
+// ValidateServer checks the settings only the server needs on top of
+// Validate, so tools such as cmd/migrate and cmd/export can run without them.
+func (c *Config) ValidateServer() error {
+	if strings.TrimSpace(c.ClickIPSalt) == "" {
+		return errors.New("missing required env vars: CLICK_IP_SALT")
+	}
+	return nil
+}
+
 func (e Env) ToConfig() *Config {
 	return &Config{
+		StorageBackend: e.StorageBackend,
+		SQLitePath:     e.SQLitePath,
+
 		DBHost:     e.DBHost,
 		DBPort:     e.DBPort,
 		DBUser:     e.DBUser,
@@ -189,19 +364,50 @@ func (e Env) ToConfig() *Config {
 		MigrationsPath: e.MigrationsPath,
 		APIKey:         e.APIKey,
 		EnableSwagger:  e.EnableSwagger,
+		EnableMetrics:  e.EnableMetrics,
+		ClickIPSalt:    e.ClickIPSalt,
+
+		AllowedSchemes:           e.AllowedSchemes,
+		AllowedDomains:           e.AllowedDomains,
+		BlockedDomains:           e.BlockedDomains,
+		AllowPrivateDestinations: e.AllowPrivateDestinations,
+		AliasDomains:             e.AliasDomains,
+		SelfLinks:                e.SelfLinks,
+
 		Server: ServerConfig{
 			Address:                 e.Address,
 			ReadTimeout:             e.ReadTimeout,
 			WriteTimeout:            e.WriteTimeout,
 			IdleTimeout:             e.IdleTimeout,
 			GracefulShutdownTimeout: e.GracefulShutdownTimeout,
+			ShutdownDrainDelay:      e.ShutdownDrainDelay,
 		},
-		CacheTTL:          e.CacheTTL,
-		RequestTimeout:    e.RequestTimeout,
-		DBMaxOpenConns:    e.DBMaxOpenConns,
-		DBMaxIdleConns:    e.DBMaxIdleConns,
-		DBConnMaxLifetime: e.DBConnMaxLifetime,
-		DBConnMaxIdleTime: e.DBConnMaxIdleTime,
+		CacheTTL:           e.CacheTTL,
+		CacheLocalSize:     e.CacheLocalSize,
+		CacheLocalTTL:      e.CacheLocalTTL,
+		NegativeCacheTTL:   e.NegativeCacheTTL,
+		CodeFilterEnabled:  e.CodeFilterEnabled,
+		CodeFilterCapacity: e.CodeFilterCapacity,
+		CacheTimeout:       e.CacheTimeout,
+		HealthCheckTimeout: e.HealthCheckTimeout,
+		BreakerThreshold:   e.BreakerThreshold,
+		BreakerCooldown:    e.BreakerCooldown,
+		ReadOnly:           e.ReadOnly,
+		RequestTimeout:     e.RequestTimeout,
+		ClickFlushInterval: e.ClickFlushInterval,
+		ClickFlushSize:     e.ClickFlushSize,
+		CleanupInterval:    e.CleanupInterval,
+		CleanupBatchSize:   e.CleanupBatchSize,
+		ArchiveMode:        e.ArchiveMode,
+		ArchiveDir:         e.ArchiveDir,
+		ArchiveRetention:   e.ArchiveRetention,
+		DBMaxOpenConns:     e.DBMaxOpenConns,
+		DBMaxIdleConns:     e.DBMaxIdleConns,
+		DBConnMaxLifetime:  e.DBConnMaxLifetime,
+		DBConnMaxIdleTime:  e.DBConnMaxIdleTime,
+		TracingExporter:    e.TracingExporter,
+		TracingFile:        e.TracingFile,
+		TracingSampleRatio: e.TracingSampleRatio,
 	}
 }
 
@@ -210,6 +416,14 @@ func getRequiredString(envMap map[string]string, key string) string {
 	return value
 }
 
+func getString(envMap map[string]string, key string, defaultValue string) string {
+	value := strings.TrimSpace(envMap[key])
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
 func getInt(envMap map[string]string, key string, defaultValue int) int {
 	valueStr := strings.TrimSpace(envMap[key])
 	if valueStr == "" {
@@ -223,6 +437,19 @@ func getInt(envMap map[string]string, key string, defaultValue int) int {
 	return value
 }
 
+func getFloat(envMap map[string]string, key string, defaultValue float64) float64 {
+	valueStr := strings.TrimSpace(envMap[key])
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseFloat(valueStr, 64)
+	if err != nil {
+		log.Printf("Invalid value for %s: %s. Using default: %g", key, valueStr, defaultValue)
+		return defaultValue
+	}
+	return value
+}
+
 func getDuration(envMap map[string]string, key string, defaultValue time.Duration) time.Duration {
 	valueStr := strings.TrimSpace(envMap[key])
 	if valueStr == "" {
@@ -249,6 +476,20 @@ func getBool(envMap map[string]string, key string, defaultValue bool) bool {
 	return value
 }
 
+// getList splits a comma-separated value, dropping empty items.
+func getList(envMap map[string]string, key string, defaultValue []string) []string {
+	var values []string
+	for _, item := range strings.Split(envMap[key], ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	if len(values) == 0 {
+		return defaultValue
+	}
+	return values
+}
+
 func toEnvMap(envVars []string) map[string]string {
 	envMap := make(map[string]string, len(envVars))
 	for _, entry := range envVars {
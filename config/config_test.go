@@ -20,6 +20,7 @@ func TestFromEnvAndValidate(t *testing.T) {
 		"BASE_URL=http://localhost:8080/",
 		"MIGRATIONS_PATH=file:///root/migrations",
 		"API_KEY=secret",
+		"CLICK_IP_SALT=pepper",
 		"ENABLE_SWAGGER=true",
 		"ADDRESS=:8080",
 		"READ_TIMEOUT=10s",
@@ -89,6 +90,7 @@ func TestDefaults(t *testing.T) {
 		"BASE_URL=http://localhost:8080",
 		"MIGRATIONS_PATH=file:///root/migrations",
 		"API_KEY=secret",
+		"CLICK_IP_SALT=pepper",
 		"ADDRESS=:8080",
 	})
 
@@ -101,4 +103,157 @@ func TestDefaults(t *testing.T) {
 	if cfgEnv.EnableSwagger {
 		t.Fatalf("expected default swagger disabled")
 	}
+	if cfgEnv.StorageBackend != StorageBackendPostgres {
+		t.Fatalf("expected default storage backend postgres, got %s", cfgEnv.StorageBackend)
+	}
+}
+
+func TestValidateEmbeddedBackends(t *testing.T) {
+	cfgEnv := FromEnv([]string{
+		"STORAGE_BACKEND=memory",
+		"BASE_URL=http://localhost:8080",
+		"API_KEY=secret",
+		"CLICK_IP_SALT=pepper",
+		"ADDRESS=:8080",
+	})
+
+	if err := cfgEnv.Validate(); err != nil {
+		t.Fatalf("expected DB and Redis vars to be optional, got %v", err)
+	}
+	if cfg := cfgEnv.ToConfig(); cfg.StorageBackend != StorageBackendMemory {
+		t.Fatalf("expected memory backend, got %s", cfg.StorageBackend)
+	}
+
+	cfgEnv = FromEnv([]string{
+		"STORAGE_BACKEND=sqlite",
+		"BASE_URL=http://localhost:8080",
+		"API_KEY=secret",
+		"CLICK_IP_SALT=pepper",
+		"ADDRESS=:8080",
+	})
+	if err := cfgEnv.Validate(); err != nil {
+		t.Fatalf("expected sqlite backend to be valid, got %v", err)
+	}
+	if cfgEnv.SQLitePath != "url-shortener.db" {
+		t.Fatalf("expected default sqlite path, got %s", cfgEnv.SQLitePath)
+	}
+
+	cfgEnv = FromEnv([]string{
+		"STORAGE_BACKEND=mongo",
+		"BASE_URL=http://localhost:8080",
+		"API_KEY=secret",
+		"CLICK_IP_SALT=pepper",
+		"ADDRESS=:8080",
+	})
+	if err := cfgEnv.Validate(); err == nil {
+		t.Fatalf("expected unknown backend to be rejected")
+	}
+}
+
+func TestValidateArchiveMode(t *testing.T) {
+	base := []string{
+		"STORAGE_BACKEND=memory",
+		"BASE_URL=http://localhost:8080",
+		"API_KEY=secret",
+		"CLICK_IP_SALT=pepper",
+		"ADDRESS=:8080",
+	}
+
+	cfgEnv := FromEnv(base)
+	if cfgEnv.ArchiveMode != ArchiveModeNone {
+		t.Fatalf("expected archiving off by default, got %s", cfgEnv.ArchiveMode)
+	}
+
+	cfgEnv = FromEnv(append(base, "ARCHIVE_MODE=file", "ARCHIVE_RETENTION=8760h"))
+	if err := cfgEnv.Validate(); err != nil {
+		t.Fatalf("expected file archive to be valid, got %v", err)
+	}
+	if cfgEnv.ArchiveDir != "archive" || cfgEnv.ArchiveRetention != 8760*time.Hour {
+		t.Fatalf("unexpected archive settings: %s, %s", cfgEnv.ArchiveDir, cfgEnv.ArchiveRetention)
+	}
+
+	cfgEnv = FromEnv(append(base, "ARCHIVE_MODE=s3"))
+	if err := cfgEnv.Validate(); err == nil {
+		t.Fatalf("expected unknown archive mode to be rejected")
+	}
+}
+
+func TestDestinationPolicyLists(t *testing.T) {
+	base := []string{
+		"STORAGE_BACKEND=memory",
+		"BASE_URL=http://localhost:8080",
+		"API_KEY=secret",
+		"CLICK_IP_SALT=pepper",
+		"ADDRESS=:8080",
+	}
+
+	cfg := FromEnv(base).ToConfig()
+	if len(cfg.AllowedSchemes) != 2 || cfg.AllowedDomains != nil || cfg.AllowPrivateDestinations {
+		t.Fatalf("unexpected defaults: %v, %v, %t", cfg.AllowedSchemes, cfg.AllowedDomains, cfg.AllowPrivateDestinations)
+	}
+
+	cfg = FromEnv(append(base, "ALLOWED_SCHEMES=https", "BLOCKED_DOMAINS= evil.example , *.evil.example,")).ToConfig()
+	if len(cfg.AllowedSchemes) != 1 || cfg.AllowedSchemes[0] != "https" {
+		t.Fatalf("unexpected schemes: %v", cfg.AllowedSchemes)
+	}
+	if len(cfg.BlockedDomains) != 2 || cfg.BlockedDomains[0] != "evil.example" || cfg.BlockedDomains[1] != "*.evil.example" {
+		t.Fatalf("unexpected blocked domains: %q", cfg.BlockedDomains)
+	}
+}
+
+func TestSelfLinks(t *testing.T) {
+	base := []string{
+		"STORAGE_BACKEND=memory",
+		"BASE_URL=http://localhost:8080",
+		"API_KEY=secret",
+		"CLICK_IP_SALT=pepper",
+		"ADDRESS=:8080",
+	}
+
+	cfgEnv := FromEnv(base)
+	if cfgEnv.SelfLinks != SelfLinksReject || cfgEnv.AliasDomains != nil {
+		t.Fatalf("unexpected defaults: %s, %v", cfgEnv.SelfLinks, cfgEnv.AliasDomains)
+	}
+
+	cfgEnv = FromEnv(append(base, "SELF_LINKS=resolve", "ALIAS_DOMAINS=sho.rt,go.example.com"))
+	if err := cfgEnv.Validate(); err != nil {
+		t.Fatalf("expected resolve mode to be valid, got %v", err)
+	}
+	if cfg := cfgEnv.ToConfig(); cfg.SelfLinks != SelfLinksResolve || len(cfg.AliasDomains) != 2 {
+		t.Fatalf("unexpected self link settings: %s, %v", cfg.SelfLinks, cfg.AliasDomains)
+	}
+
+	cfgEnv = FromEnv(append(base, "SELF_LINKS=follow"))
+	if err := cfgEnv.Validate(); err == nil {
+		t.Fatalf("expected unknown self link mode to be rejected")
+	}
+
+	for _, domain := range []string{"sho.rt:8080", "https://sho.rt", "sho.rt/s"} {
+		cfgEnv = FromEnv(append(base, "ALIAS_DOMAINS=go.example.com,"+domain))
+		if err := cfgEnv.Validate(); err == nil {
+			t.Fatalf("expected alias domain %q to be rejected", domain)
+		}
+	}
+}
+
+func TestValidateServerRequiresClickIPSalt(t *testing.T) {
+	cfgEnv := FromEnv([]string{
+		"STORAGE_BACKEND=memory",
+		"BASE_URL=http://localhost:8080",
+		"API_KEY=secret",
+		"ADDRESS=:8080",
+	})
+
+	if err := cfgEnv.Validate(); err != nil {
+		t.Fatalf("expected tools that do not hash IPs to load without a salt, got %v", err)
+	}
+	err := cfgEnv.ToConfig().ValidateServer()
+	if err == nil || !strings.Contains(err.Error(), "CLICK_IP_SALT") {
+		t.Fatalf("expected a missing CLICK_IP_SALT error, got %v", err)
+	}
+
+	cfgEnv = FromEnv([]string{"CLICK_IP_SALT=pepper"})
+	if err := cfgEnv.ToConfig().ValidateServer(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 }
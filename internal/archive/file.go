@@ -0,0 +1,241 @@
+// Package archive keeps expired links in daily JSON Lines files, for
+// deployments that want their record of served links outside the database.
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"url-shortener-go/internal/models"
+)
+
+const (
+	filePrefix = "urls-"
+	fileSuffix = ".ndjson"
+	dayLayout  = "2006-01-02"
+
+	// maxLineSize bounds one archived link; destinations are far shorter.
+	maxLineSize = 1 << 20
+)
+
+// FileArchive appends each archived link as one JSON object to a file per
+// UTC day, named urls-YYYY-MM-DD.ndjson. Lookups scan every file, which is
+// fine for the occasional audit query it serves.
+type FileArchive struct {
+	dir string
+	now func() time.Time
+
+	// mu serializes appends with purges, so a file is never removed while
+	// a batch is being written to it.
+	mu sync.Mutex
+}
+
+// NewFileArchive stores archived links in dir, creating it if needed.
+func NewFileArchive(dir string) (*FileArchive, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	return &FileArchive{dir: dir, now: time.Now}, nil
+}
+
+// ArchiveURLs appends urls to today's file and syncs it to disk. The batch is
+// written with a single write, so a failed append loses at most that batch,
+// which cleanup then retries, and the next append starts on a new line. A
+// link archived twice is returned once.
+func (a *FileArchive) ArchiveURLs(_ context.Context, urls []models.URLDetails) error {
+	archivedAt := a.now().UTC()
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, url := range urls {
+		if err := encoder.Encode(models.ArchivedURL{URLDetails: url, ArchivedAt: archivedAt}); err != nil {
+			return err
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	file, err := os.OpenFile(a.path(archivedAt), os.O_CREATE|os.O_APPEND|os.O_RDWR, 0o644)
+	if err != nil {
+		return err
+	}
+	torn, err := endsMidLine(file)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	batch := buf.Bytes()
+	if torn {
+		batch = append([]byte{'\n'}, batch...)
+	}
+	if _, err := file.Write(batch); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// GetArchivedURLs returns the archived links that used shortCode, most
+// recently archived first. Lines that cannot be decoded, such as the tail of
+// an interrupted append, are skipped.
+func (a *FileArchive) GetArchivedURLs(ctx context.Context, shortCode string) ([]models.ArchivedURL, error) {
+	files, err := a.files()
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := json.Marshal(shortCode)
+	if err != nil {
+		return nil, err
+	}
+	needle := append([]byte(`"short_code":`), code...)
+
+	latest := make(map[int]models.ArchivedURL)
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		err := scanFile(file.path, func(line []byte) {
+			if !bytes.Contains(line, needle) {
+				return
+			}
+			var archived models.ArchivedURL
+			if json.Unmarshal(line, &archived) != nil || archived.ShortCode != shortCode {
+				return
+			}
+			if previous, ok := latest[archived.ID]; !ok || archived.ArchivedAt.After(previous.ArchivedAt) {
+				latest[archived.ID] = archived
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	urls := make([]models.ArchivedURL, 0, len(latest))
+	for _, archived := range latest {
+		urls = append(urls, archived)
+	}
+	sort.Slice(urls, func(i, j int) bool {
+		if !urls[i].ArchivedAt.Equal(urls[j].ArchivedAt) {
+			return urls[i].ArchivedAt.After(urls[j].ArchivedAt)
+		}
+		return urls[i].ID > urls[j].ID
+	})
+	return urls, nil
+}
+
+// PurgeArchivedURLs removes whole files once every link in them was archived
+// before cutoff, so links are kept for up to a day past it. It reports how
+// many archived links the removed files held.
+func (a *FileArchive) PurgeArchivedURLs(_ context.Context, cutoff time.Time) (int64, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	files, err := a.files()
+	if err != nil {
+		return 0, err
+	}
+
+	var purged int64
+	for _, file := range files {
+		if file.day.Add(24 * time.Hour).After(cutoff) {
+			continue
+		}
+
+		var lines int64
+		if err := scanFile(file.path, func([]byte) { lines++ }); err != nil {
+			return purged, err
+		}
+		if err := os.Remove(file.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return purged, err
+		}
+		purged += lines
+	}
+	return purged, nil
+}
+
+// endsMidLine reports whether file is left with a partial last line by an
+// interrupted append.
+func endsMidLine(file *os.File) (bool, error) {
+	info, err := file.Stat()
+	if err != nil || info.Size() == 0 {
+		return false, err
+	}
+	last := make([]byte, 1)
+	if _, err := file.ReadAt(last, info.Size()-1); err != nil {
+		return false, err
+	}
+	return last[0] != '\n', nil
+}
+
+func (a *FileArchive) path(day time.Time) string {
+	return filepath.Join(a.dir, filePrefix+day.Format(dayLayout)+fileSuffix)
+}
+
+type archiveFile struct {
+	path string
+	day  time.Time
+}
+
+// files lists the archive's daily files, oldest first. Other files in the
+// directory are ignored.
+func (a *FileArchive) files() ([]archiveFile, error) {
+	entries, err := os.ReadDir(a.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []archiveFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, filePrefix) || !strings.HasSuffix(name, fileSuffix) {
+			continue
+		}
+		day, err := time.Parse(dayLayout, strings.TrimSuffix(strings.TrimPrefix(name, filePrefix), fileSuffix))
+		if err != nil {
+			continue
+		}
+		files = append(files, archiveFile{path: filepath.Join(a.dir, name), day: day})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].day.Before(files[j].day) })
+	return files, nil
+}
+
+// scanFile calls fn with each non-empty line of path. A file removed by a
+// concurrent purge reads as empty.
+func scanFile(path string, fn func(line []byte)) error {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		if line := scanner.Bytes(); len(line) > 0 {
+			fn(line)
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,113 @@
+package archive
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"url-shortener-go/internal/models"
+	"url-shortener-go/internal/service"
+)
+
+var _ service.Archive = (*FileArchive)(nil)
+
+func newTestArchive(t *testing.T, now time.Time) *FileArchive {
+	t.Helper()
+	archive, err := NewFileArchive(filepath.Join(t.TempDir(), "archive"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	archive.now = func() time.Time { return now }
+	return archive
+}
+
+func details(id int, code string) models.URLDetails {
+	expiresAt := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	return models.URLDetails{
+		URL: models.URL{
+			ID:          id,
+			ShortCode:   code,
+			OriginalURL: "https://example.com/" + code,
+			Status:      models.URLStatusActive,
+			CreatedAt:   expiresAt.Add(-24 * time.Hour),
+			ExpiresAt:   &expiresAt,
+		},
+		ClickCount: int64(id),
+	}
+}
+
+func TestFileArchive_ArchiveAndGet(t *testing.T) {
+	ctx := context.Background()
+	day := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	archive := newTestArchive(t, day)
+
+	if err := archive.ArchiveURLs(ctx, []models.URLDetails{details(1, "abc123"), details(2, "xyz789")}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// A retried batch and a later link that reused the code.
+	archive.now = func() time.Time { return day.Add(26 * time.Hour) }
+	if err := archive.ArchiveURLs(ctx, []models.URLDetails{details(1, "abc123"), details(3, "abc123")}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	urls, err := archive.GetArchivedURLs(ctx, "abc123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(urls) != 2 || urls[0].ID != 3 || urls[1].ID != 1 {
+		t.Fatalf("expected links 3 and 1 once each, newest first, got %+v", urls)
+	}
+	if urls[1].ClickCount != 1 || urls[1].ExpiresAt == nil || !urls[1].ArchivedAt.Equal(day.Add(26*time.Hour)) {
+		t.Fatalf("unexpected archived link: %+v", urls[1])
+	}
+
+	if urls, err := archive.GetArchivedURLs(ctx, "missing"); err != nil || len(urls) != 0 {
+		t.Fatalf("expected no links, got %v, %v", urls, err)
+	}
+}
+
+func TestFileArchive_SkipsTornLines(t *testing.T) {
+	ctx := context.Background()
+	day := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	archive := newTestArchive(t, day)
+
+	if err := os.WriteFile(archive.path(day), []byte(`{"id":9,"short_code":"abc123","orig`), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := archive.ArchiveURLs(ctx, []models.URLDetails{details(1, "abc123")}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	urls, err := archive.GetArchivedURLs(ctx, "abc123")
+	if err != nil || len(urls) != 1 || urls[0].ID != 1 {
+		t.Fatalf("expected the intact link only, got %+v, %v", urls, err)
+	}
+}
+
+func TestFileArchive_PurgeRemovesWholeDays(t *testing.T) {
+	ctx := context.Background()
+	day := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	archive := newTestArchive(t, day)
+
+	archive.ArchiveURLs(ctx, []models.URLDetails{details(1, "aaa111"), details(2, "bbb222")})
+	archive.now = func() time.Time { return day.Add(24 * time.Hour) }
+	archive.ArchiveURLs(ctx, []models.URLDetails{details(3, "ccc333")})
+
+	// The second day is not over at the cutoff, so its file stays.
+	purged, err := archive.PurgeArchivedURLs(ctx, day.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if purged != 2 {
+		t.Fatalf("expected 2 purged, got %d", purged)
+	}
+
+	if urls, _ := archive.GetArchivedURLs(ctx, "aaa111"); len(urls) != 0 {
+		t.Fatalf("expected the first day to be purged, got %+v", urls)
+	}
+	if urls, _ := archive.GetArchivedURLs(ctx, "ccc333"); len(urls) != 1 {
+		t.Fatalf("expected the second day to be kept, got %+v", urls)
+	}
+}
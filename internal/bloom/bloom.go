@@ -0,0 +1,93 @@
+// Package bloom implements a Bloom filter of short codes. Params and
+// Locations define the bit layout, so a Redis bitmap can share it with the
+// in-process Filter.
+package bloom
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"sync/atomic"
+)
+
+const (
+	// DefaultFalsePositiveRate is the share of unknown codes a filter sized
+	// with Estimate lets through when full.
+	DefaultFalsePositiveRate = 0.01
+
+	// maxBits is the largest bitmap Redis can address.
+	maxBits = 1 << 32
+)
+
+// Params sizes a filter.
+type Params struct {
+	Bits   uint64
+	Hashes int
+}
+
+// Estimate returns the parameters for holding n codes with a false positive
+// rate of p.
+func Estimate(n int, p float64) Params {
+	n = max(n, 1)
+	bits := math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2))
+	hashes := math.Round(bits / float64(n) * math.Ln2)
+	return Params{
+		Bits:   min(max(uint64(bits), 64), maxBits),
+		Hashes: max(int(hashes), 1),
+	}
+}
+
+// Locations returns the bits set for code, using double hashing over a
+// 64-bit FNV-1a hash.
+func (p Params) Locations(code string) []uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(code))
+	h1 := h.Sum64()
+	h2 := mix(h1) | 1
+
+	locations := make([]uint64, p.Hashes)
+	for i := range locations {
+		locations[i] = (h1 + uint64(i)*h2) % p.Bits
+	}
+	return locations
+}
+
+// mix is the splitmix64 finalizer, used to derive a second hash.
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// Filter is an in-process Bloom filter, safe for concurrent use.
+type Filter struct {
+	params Params
+	words  []atomic.Uint64
+}
+
+func New(params Params) *Filter {
+	return &Filter{
+		params: params,
+		words:  make([]atomic.Uint64, (params.Bits+63)/64),
+	}
+}
+
+func (f *Filter) Add(_ context.Context, code string) error {
+	for _, location := range f.params.Locations(code) {
+		f.words[location/64].Or(1 << (location % 64))
+	}
+	return nil
+}
+
+// MayContain reports false only for codes that were never added.
+func (f *Filter) MayContain(_ context.Context, code string) (bool, error) {
+	for _, location := range f.params.Locations(code) {
+		if f.words[location/64].Load()&(1<<(location%64)) == 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,50 @@
+package bloom
+
+import (
+	"context"
+	"strconv"
+	"testing"
+)
+
+func TestEstimate(t *testing.T) {
+	params := Estimate(1_000_000, 0.01)
+	if params.Bits < 9_000_000 || params.Bits > 10_000_000 {
+		t.Fatalf("unexpected bit count %d", params.Bits)
+	}
+	if params.Hashes != 7 {
+		t.Fatalf("expected 7 hashes, got %d", params.Hashes)
+	}
+}
+
+func TestFilter_NoFalseNegatives(t *testing.T) {
+	filter := New(Estimate(10_000, 0.01))
+	ctx := context.Background()
+
+	for i := range 10_000 {
+		filter.Add(ctx, "code"+strconv.Itoa(i))
+	}
+	for i := range 10_000 {
+		if ok, _ := filter.MayContain(ctx, "code"+strconv.Itoa(i)); !ok {
+			t.Fatalf("expected code%d to be found", i)
+		}
+	}
+}
+
+func TestFilter_FalsePositiveRate(t *testing.T) {
+	filter := New(Estimate(10_000, 0.01))
+	ctx := context.Background()
+
+	for i := range 10_000 {
+		filter.Add(ctx, "code"+strconv.Itoa(i))
+	}
+
+	falsePositives := 0
+	for i := range 10_000 {
+		if ok, _ := filter.MayContain(ctx, "other"+strconv.Itoa(i)); ok {
+			falsePositives++
+		}
+	}
+	if falsePositives > 200 {
+		t.Fatalf("expected about 1%% false positives, got %d in 10000", falsePositives)
+	}
+}
@@ -0,0 +1,118 @@
+// Package breaker implements a circuit breaker that stops calls to a failing
+// dependency for a cooldown period instead of letting every request wait on
+// it.
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrOpen = errors.New("circuit breaker is open")
+
+type State int
+
+const (
+	// Closed lets every call through.
+	Closed State = iota
+	// Open rejects calls until the cooldown has passed.
+	Open
+	// HalfOpen lets one trial call through to decide whether to close again.
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// Breaker opens after threshold consecutive failures. Once cooldown has
+// passed, one trial call is let through: success closes the breaker, failure
+// opens it for another cooldown.
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+func New(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{
+		threshold: max(threshold, 1),
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// Allow returns ErrOpen if the call must not be made. Every allowed call must
+// be followed by Done.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case Open:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return ErrOpen
+		}
+		b.state = HalfOpen
+		b.trial = true
+	case HalfOpen:
+		if b.trial {
+			return ErrOpen
+		}
+		b.trial = true
+	}
+	return nil
+}
+
+// Done records the outcome of an allowed call.
+func (b *Breaker) Done(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case b.state == HalfOpen:
+		b.trial = false
+		if failed {
+			b.openLocked()
+		} else {
+			b.state = Closed
+			b.failures = 0
+		}
+	case failed:
+		b.failures++
+		if b.failures >= b.threshold {
+			b.openLocked()
+		}
+	default:
+		b.failures = 0
+	}
+}
+
+// State reports the current state. An open breaker whose cooldown has passed
+// still reports Open until the next call is let through.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state
+}
+
+func (b *Breaker) openLocked() {
+	b.state = Open
+	b.openedAt = b.now()
+	b.failures = 0
+}
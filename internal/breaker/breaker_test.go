@@ -0,0 +1,70 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestBreaker(threshold int, cooldown time.Duration) (*Breaker, *time.Time) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	b := New(threshold, cooldown)
+	b.now = func() time.Time { return now }
+	return b, &now
+}
+
+func call(b *Breaker, failed bool) error {
+	if err := b.Allow(); err != nil {
+		return err
+	}
+	b.Done(failed)
+	return nil
+}
+
+func TestBreaker_OpensAfterConsecutiveFailures(t *testing.T) {
+	b, _ := newTestBreaker(3, time.Minute)
+
+	call(b, true)
+	call(b, true)
+	call(b, false)
+	call(b, true)
+	call(b, true)
+	if b.State() != Closed {
+		t.Fatalf("expected a success to reset the failure count, got %s", b.State())
+	}
+
+	call(b, true)
+	if b.State() != Open {
+		t.Fatalf("expected open after 3 consecutive failures, got %s", b.State())
+	}
+	if err := b.Allow(); !errors.Is(err, ErrOpen) {
+		t.Fatalf("expected ErrOpen, got %v", err)
+	}
+}
+
+func TestBreaker_HalfOpenTrial(t *testing.T) {
+	b, now := newTestBreaker(1, time.Minute)
+
+	call(b, true)
+	*now = now.Add(time.Minute)
+
+	if err := b.Allow(); err != nil {
+		t.Fatalf("expected a trial call after the cooldown, got %v", err)
+	}
+	if err := b.Allow(); !errors.Is(err, ErrOpen) {
+		t.Fatalf("expected only one trial call, got %v", err)
+	}
+
+	b.Done(true)
+	if b.State() != Open {
+		t.Fatalf("expected a failed trial to reopen, got %s", b.State())
+	}
+
+	*now = now.Add(time.Minute)
+	if err := call(b, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if b.State() != Closed {
+		t.Fatalf("expected a successful trial to close, got %s", b.State())
+	}
+}
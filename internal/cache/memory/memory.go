@@ -0,0 +1,163 @@
+// Package memory implements service.Cache in process memory, either as the
+// only cache when running without Redis or as the local tier in front of it.
+package memory
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"url-shortener-go/internal/models"
+	"url-shortener-go/internal/service"
+)
+
+// sweepEvery is how many Set calls pass between sweeps of expired entries,
+// so keys that are never read again do not pile up.
+const sweepEvery = 1024
+
+type item struct {
+	key       string
+	url       models.URL
+	expiresAt time.Time
+}
+
+// CacheRepository is a thread-safe map with per-key expiry. A zero
+// expiration keeps the entry until it is deleted, like Redis. When
+// maxEntries is set, the least recently used entry is evicted to make room.
+type CacheRepository struct {
+	mu         sync.Mutex
+	maxEntries int
+	items      map[string]*list.Element
+	recency    *list.List
+	sets       int
+}
+
+// NewCacheRepository returns a cache holding at most maxEntries entries;
+// zero means unbounded.
+func NewCacheRepository(maxEntries int) *CacheRepository {
+	return &CacheRepository{
+		maxEntries: maxEntries,
+		items:      make(map[string]*list.Element),
+		recency:    list.New(),
+	}
+}
+
+func (c *CacheRepository) Set(_ context.Context, key string, value *models.URL, expiration time.Duration) error {
+	stored := &item{key: key, url: copyURL(value)}
+	if expiration > 0 {
+		stored.expiresAt = time.Now().Add(expiration)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.items[key]; ok {
+		element.Value = stored
+		c.recency.MoveToFront(element)
+	} else {
+		c.items[key] = c.recency.PushFront(stored)
+	}
+
+	c.sets++
+	if c.sets%sweepEvery == 0 {
+		c.sweepLocked(time.Now())
+	}
+	for c.maxEntries > 0 && c.recency.Len() > c.maxEntries {
+		c.removeLocked(c.recency.Back())
+	}
+
+	return nil
+}
+
+func (c *CacheRepository) Get(ctx context.Context, key string) (*models.URL, error) {
+	url, _, err := c.GetWithTTL(ctx, key)
+	return url, err
+}
+
+// GetWithTTL is Get plus the time left before the entry expires. An entry
+// without expiry reports zero.
+func (c *CacheRepository) GetWithTTL(_ context.Context, key string) (*models.URL, time.Duration, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.items[key]
+	if !ok {
+		return nil, 0, service.ErrNotFound
+	}
+	stored := element.Value.(*item)
+	now := time.Now()
+	if stored.expired(now) {
+		c.removeLocked(element)
+		return nil, 0, service.ErrNotFound
+	}
+	c.recency.MoveToFront(element)
+
+	var ttl time.Duration
+	if !stored.expiresAt.IsZero() {
+		ttl = stored.expiresAt.Sub(now)
+	}
+
+	url := copyURL(&stored.url)
+	return &url, ttl, nil
+}
+
+func (c *CacheRepository) Delete(_ context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.items[key]; ok {
+		c.removeLocked(element)
+	}
+	return nil
+}
+
+func (c *CacheRepository) DeletePrefix(_ context.Context, prefix string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, element := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			c.removeLocked(element)
+		}
+	}
+	return nil
+}
+
+// Len returns the number of entries, including expired ones not yet swept.
+func (c *CacheRepository) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.recency.Len()
+}
+
+func (c *CacheRepository) Close() error {
+	return nil
+}
+
+func (c *CacheRepository) removeLocked(element *list.Element) {
+	c.recency.Remove(element)
+	delete(c.items, element.Value.(*item).key)
+}
+
+func (c *CacheRepository) sweepLocked(now time.Time) {
+	for _, element := range c.items {
+		if element.Value.(*item).expired(now) {
+			c.removeLocked(element)
+		}
+	}
+}
+
+func (i *item) expired(now time.Time) bool {
+	return !i.expiresAt.IsZero() && !now.Before(i.expiresAt)
+}
+
+func copyURL(url *models.URL) models.URL {
+	copied := *url
+	if url.ExpiresAt != nil {
+		copied.ExpiresAt = new(*url.ExpiresAt)
+	}
+	return copied
+}
@@ -0,0 +1,47 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"url-shortener-go/internal/models"
+	"url-shortener-go/internal/service"
+	"url-shortener-go/internal/storagetest"
+)
+
+func TestCacheRepository_Conformance(t *testing.T) {
+	storagetest.RunCacheTests(t, func(t *testing.T) service.Cache {
+		return NewCacheRepository(0)
+	})
+}
+
+func TestCacheRepository_EvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewCacheRepository(2)
+	ctx := context.Background()
+
+	for _, key := range []string{"a", "b"} {
+		if err := cache.Set(ctx, key, &models.URL{ShortCode: key}, time.Minute); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if _, err := cache.Get(ctx, "a"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := cache.Set(ctx, "c", &models.URL{ShortCode: "c"}, time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cache.Len() != 2 {
+		t.Fatalf("expected 2 entries, got %d", cache.Len())
+	}
+	if _, err := cache.Get(ctx, "b"); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("expected least recently used entry to be evicted, got %v", err)
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, err := cache.Get(ctx, key); err != nil {
+			t.Fatalf("expected %s to be kept, got %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+
+	"url-shortener-go/internal/bloom"
+	"url-shortener-go/internal/service"
+
+	redis "github.com/redis/go-redis/v9"
+)
+
+// CodeFilter is a Bloom filter of short codes kept in a Redis bitmap, so every
+// instance sees codes created by the others.
+type CodeFilter struct {
+	cache  *CacheRepository
+	key    string
+	params bloom.Params
+}
+
+// NewCodeFilter returns a filter stored next to the cache. The key includes
+// the filter size, so resizing starts from an empty bitmap.
+func NewCodeFilter(cache *CacheRepository, params bloom.Params) *CodeFilter {
+	return &CodeFilter{
+		cache:  cache,
+		key:    fmt.Sprintf("url-shortener:code-filter:%d:%d", params.Bits, params.Hashes),
+		params: params,
+	}
+}
+
+func (f *CodeFilter) Add(ctx context.Context, code string) error {
+	pipe := f.cache.client.Pipeline()
+	for _, location := range f.params.Locations(code) {
+		pipe.SetBit(ctx, f.key, int64(location), 1)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// MayContain reports false only for codes that were never added. A missing
+// bitmap means Redis lost it, which is reported as service.ErrCodeFilterLost
+// rather than as a miss for every code.
+func (f *CodeFilter) MayContain(ctx context.Context, code string) (bool, error) {
+	pipe := f.cache.client.Pipeline()
+	exists := pipe.Exists(ctx, f.key)
+	locations := f.params.Locations(code)
+	bits := make([]*redis.IntCmd, len(locations))
+	for i, location := range locations {
+		bits[i] = pipe.GetBit(ctx, f.key, int64(location))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+	if exists.Val() == 0 {
+		return false, service.ErrCodeFilterLost
+	}
+
+	for _, bit := range bits {
+		if bit.Val() == 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
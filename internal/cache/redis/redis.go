@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"url-shortener-go/internal/models"
@@ -12,24 +13,54 @@ import (
 	redis "github.com/redis/go-redis/v9"
 )
 
+const (
+	// invalidationChannel carries keys whose cached value must be dropped by
+	// every instance's local cache tier.
+	invalidationChannel = "url-shortener:cache-invalidations"
+
+	// deleteBatchSize is the SCAN count used by DeletePrefix.
+	deleteBatchSize = 500
+
+	subscribeTimeout = time.Second
+)
+
 type CacheRepository struct {
 	client *redis.Client
 }
 
 func NewCacheRepository(opts *redis.Options) (*CacheRepository, error) {
-	client := redis.NewClient(opts)
-
-	_, err := client.Ping(context.Background()).Result()
-	if err != nil {
-		return nil, fmt.Errorf("error connecting to Redis: %w", err)
+	cache := OpenCacheRepository(opts)
+	if err := cache.Ping(context.Background()); err != nil {
+		cache.Close()
+		return nil, err
 	}
+	return cache, nil
+}
 
+// OpenCacheRepository returns a cache without checking that Redis is up. The
+// client connects on first use and reconnects on its own.
+func OpenCacheRepository(opts *redis.Options) *CacheRepository {
 	return &CacheRepository{
-		client: client,
-	}, nil
+		client: redis.NewClient(opts),
+	}
+}
+
+func (r *CacheRepository) Ping(ctx context.Context) error {
+	if err := r.client.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("error connecting to Redis: %w", err)
+	}
+	return nil
+}
+
+// PoolStats reports the client's connection pool usage.
+func (r *CacheRepository) PoolStats() *redis.PoolStats {
+	return r.client.PoolStats()
 }
 
-func (r *CacheRepository) Set(ctx context.Context, key string, value *models.URL, expiration time.Duration) error {
+func (r *CacheRepository) Set(ctx context.Context, key string, value *models.URL, expiration time.Duration) (err error) {
+	ctx, span := startSpan(ctx, "Set")
+	defer func() { endSpan(span, err) }()
+
 	urlJSON, err := json.Marshal(value)
 	if err != nil {
 		return err
@@ -38,7 +69,10 @@ func (r *CacheRepository) Set(ctx context.Context, key string, value *models.URL
 	return r.client.Set(ctx, key, urlJSON, expiration).Err()
 }
 
-func (r *CacheRepository) Get(ctx context.Context, key string) (*models.URL, error) {
+func (r *CacheRepository) Get(ctx context.Context, key string) (_ *models.URL, err error) {
+	ctx, span := startSpan(ctx, "Get")
+	defer func() { endSpan(span, err) }()
+
 	urlJSON, err := r.client.Get(ctx, key).Result()
 	if err != nil {
 		if err == redis.Nil {
@@ -55,6 +89,112 @@ func (r *CacheRepository) Get(ctx context.Context, key string) (*models.URL, err
 	return &url, nil
 }
 
+// GetWithTTL is Get plus the time left before the key expires, read in the
+// same round trip. A key without expiry reports zero.
+func (r *CacheRepository) GetWithTTL(ctx context.Context, key string) (_ *models.URL, _ time.Duration, err error) {
+	ctx, span := startSpan(ctx, "GetWithTTL")
+	defer func() { endSpan(span, err) }()
+
+	pipe := r.client.Pipeline()
+	getCmd := pipe.Get(ctx, key)
+	ttlCmd := pipe.PTTL(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, 0, err
+	}
+
+	urlJSON, err := getCmd.Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, 0, service.ErrNotFound
+		}
+		return nil, 0, err
+	}
+
+	var url models.URL
+	if err := json.Unmarshal([]byte(urlJSON), &url); err != nil {
+		return nil, 0, err
+	}
+
+	return &url, max(ttlCmd.Val(), 0), nil
+}
+
+func (r *CacheRepository) Delete(ctx context.Context, key string) (err error) {
+	ctx, span := startSpan(ctx, "Delete")
+	defer func() { endSpan(span, err) }()
+
+	return r.client.Del(ctx, key).Err()
+}
+
+// DeletePrefix removes every key starting with prefix. It scans in batches
+// and unlinks as it goes, so it does not block Redis on a large keyspace.
+func (r *CacheRepository) DeletePrefix(ctx context.Context, prefix string) (err error) {
+	ctx, span := startSpan(ctx, "DeletePrefix")
+	defer func() { endSpan(span, err) }()
+
+	var cursor uint64
+	for {
+		keys, next, err := r.client.Scan(ctx, cursor, escapeGlob(prefix)+"*", deleteBatchSize).Result()
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			if err := r.client.Unlink(ctx, keys...).Err(); err != nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// PublishInvalidation tells every subscribed instance to drop key locally.
+func (r *CacheRepository) PublishInvalidation(ctx context.Context, key string) (err error) {
+	ctx, span := startSpan(ctx, "PublishInvalidation")
+	defer func() { endSpan(span, err) }()
+
+	return r.client.Publish(ctx, invalidationChannel, key).Err()
+}
+
+// SubscribeInvalidations calls onInvalidate for every published key until ctx
+// is done. If Redis is down it keeps retrying in the background, and the
+// client resubscribes on its own after a reconnect. Keys published while
+// disconnected are lost, so local entries must have a short TTL.
+func (r *CacheRepository) SubscribeInvalidations(ctx context.Context, onInvalidate func(key string)) error {
+	pubsub := r.client.Subscribe(ctx, invalidationChannel)
+
+	// Wait for the confirmation so keys published right after are not missed,
+	// but do not hold up startup while Redis is unreachable.
+	confirmCtx, cancel := context.WithTimeout(ctx, subscribeTimeout)
+	pubsub.Receive(confirmCtx)
+	cancel()
+
+	go func() {
+		defer pubsub.Close()
+
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case message, ok := <-messages:
+				if !ok {
+					return
+				}
+				onInvalidate(message.Payload)
+			}
+		}
+	}()
+
+	return nil
+}
+
 func (r *CacheRepository) Close() error {
 	return r.client.Close()
 }
+
+// escapeGlob quotes the characters SCAN MATCH treats as patterns.
+func escapeGlob(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`).Replace(value)
+}
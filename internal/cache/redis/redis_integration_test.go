@@ -3,13 +3,16 @@ package redis
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os"
 	"testing"
 	"time"
 
 	"url-shortener-go/config"
+	"url-shortener-go/internal/bloom"
 	"url-shortener-go/internal/models"
 	"url-shortener-go/internal/service"
+	"url-shortener-go/internal/storagetest"
 )
 
 func TestRedisCache_SetGet(t *testing.T) {
@@ -113,3 +116,140 @@ func TestRedisCache_TTLExpires(t *testing.T) {
 		t.Fatalf("expected cache miss after ttl")
 	}
 }
+
+func TestRedisCache_Delete(t *testing.T) {
+	if os.Getenv("INTEGRATION_TESTS") == "" {
+		t.Skip("INTEGRATION_TESTS not set")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	cache, err := NewCacheRepository(cfg.GetRedisOpts())
+	if err != nil {
+		t.Fatalf("failed to init cache: %v", err)
+	}
+	defer cache.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	value := &models.URL{
+		ID:          3,
+		ShortCode:   "del",
+		OriginalURL: "https://example.com/del",
+		CreatedAt:   time.Now(),
+	}
+
+	if err := cache.Set(ctx, "url:del", value, time.Minute); err != nil {
+		t.Fatalf("failed to set cache: %v", err)
+	}
+	if err := cache.Delete(ctx, "url:del"); err != nil {
+		t.Fatalf("failed to delete cache: %v", err)
+	}
+
+	_, err = cache.Get(ctx, "url:del")
+	if err == nil || !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("expected cache miss after delete")
+	}
+}
+
+func TestRedisCache_Conformance(t *testing.T) {
+	if os.Getenv("INTEGRATION_TESTS") == "" {
+		t.Skip("INTEGRATION_TESTS not set")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	cache, err := NewCacheRepository(cfg.GetRedisOpts())
+	if err != nil {
+		t.Fatalf("failed to init cache: %v", err)
+	}
+	defer cache.Close()
+
+	storagetest.RunCacheTests(t, func(t *testing.T) service.Cache {
+		return cache
+	})
+}
+
+func TestRedisCache_InvalidationFanOut(t *testing.T) {
+	if os.Getenv("INTEGRATION_TESTS") == "" {
+		t.Skip("INTEGRATION_TESTS not set")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	cache, err := NewCacheRepository(cfg.GetRedisOpts())
+	if err != nil {
+		t.Fatalf("failed to init cache: %v", err)
+	}
+	defer cache.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	received := make(chan string, 1)
+	if err := cache.SubscribeInvalidations(ctx, func(key string) { received <- key }); err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	if err := cache.PublishInvalidation(ctx, "url:fanout"); err != nil {
+		t.Fatalf("failed to publish: %v", err)
+	}
+
+	select {
+	case key := <-received:
+		if key != "url:fanout" {
+			t.Fatalf("expected url:fanout, got %s", key)
+		}
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for invalidation")
+	}
+}
+
+func TestCodeFilter_AddAndMayContain(t *testing.T) {
+	if os.Getenv("INTEGRATION_TESTS") == "" {
+		t.Skip("INTEGRATION_TESTS not set")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	cache, err := NewCacheRepository(cfg.GetRedisOpts())
+	if err != nil {
+		t.Fatalf("failed to init cache: %v", err)
+	}
+	defer cache.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	code := fmt.Sprintf("bloom-%d", time.Now().UnixNano())
+	filter := NewCodeFilter(cache, bloom.Estimate(1000, bloom.DefaultFalsePositiveRate))
+
+	if err := filter.Add(ctx, code); err != nil {
+		t.Fatalf("failed to add code: %v", err)
+	}
+	if ok, err := filter.MayContain(ctx, code); err != nil || !ok {
+		t.Fatalf("expected added code to be found, got %t, %v", ok, err)
+	}
+	if ok, err := filter.MayContain(ctx, code+"-unseen"); err != nil || ok {
+		t.Fatalf("expected unseen code to be rejected, got %t, %v", ok, err)
+	}
+
+	if err := cache.client.Del(ctx, filter.key).Err(); err != nil {
+		t.Fatalf("failed to delete filter: %v", err)
+	}
+	if _, err := filter.MayContain(ctx, code); !errors.Is(err, service.ErrCodeFilterLost) {
+		t.Fatalf("expected ErrCodeFilterLost after the bitmap is gone, got %v", err)
+	}
+}
@@ -0,0 +1,30 @@
+package redis
+
+import (
+	"context"
+
+	"url-shortener-go/internal/service"
+	"url-shortener-go/internal/telemetry"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("url-shortener-go/internal/cache/redis")
+
+// startSpan starts a client span for one cache call.
+func startSpan(ctx context.Context, operation string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "redis."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system.name", "redis"),
+			attribute.String("db.operation.name", operation),
+		),
+	)
+}
+
+// endSpan ends the span, recording err unless it is a cache miss.
+func endSpan(span trace.Span, err error) {
+	telemetry.EndSpan(span, err, service.ErrNotFound)
+}
@@ -0,0 +1,160 @@
+// Package tiered layers a small in-process cache in front of a shared one, so
+// the hottest links skip the Redis round trip and JSON decode.
+package tiered
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"url-shortener-go/internal/cache/memory"
+	"url-shortener-go/internal/models"
+	"url-shortener-go/internal/service"
+)
+
+// prefixWildcard marks a published invalidation as a prefix.
+const prefixWildcard = "*"
+
+// Invalidator fans out deletions so other instances drop their local copy.
+type Invalidator interface {
+	PublishInvalidation(ctx context.Context, key string) error
+	SubscribeInvalidations(ctx context.Context, onInvalidate func(key string)) error
+}
+
+// Cache is a service.Cache with a bounded LRU/TTL tier in front of a remote
+// cache. Reads try the local tier first and fill it from the remote one.
+// Deletes are applied to both tiers and published, so an edited or deleted
+// link is not served stale from another instance's local tier. If a
+// published invalidation is missed, the local TTL bounds the staleness.
+type Cache struct {
+	local       *memory.CacheRepository
+	remote      service.Cache
+	localTTL    time.Duration
+	invalidator Invalidator
+	observe     func(hit bool)
+	cancel      context.CancelFunc
+}
+
+// Option customizes a Cache.
+type Option func(*Cache)
+
+// WithLocalObserver calls observe with the outcome of every local tier
+// lookup. A negative entry for an unknown code is reported as a miss.
+func WithLocalObserver(observe func(hit bool)) Option {
+	return func(c *Cache) {
+		c.observe = observe
+	}
+}
+
+// New wraps remote with a local tier of at most maxEntries entries kept for
+// at most localTTL. invalidator may be nil for a single instance.
+func New(remote service.Cache, invalidator Invalidator, maxEntries int, localTTL time.Duration, opts ...Option) (*Cache, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Cache{
+		local:       memory.NewCacheRepository(maxEntries),
+		remote:      remote,
+		localTTL:    localTTL,
+		invalidator: invalidator,
+		observe:     func(bool) {},
+		cancel:      cancel,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if invalidator != nil {
+		if err := invalidator.SubscribeInvalidations(ctx, c.dropLocal); err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+func (c *Cache) Get(ctx context.Context, key string) (*models.URL, error) {
+	url, _, err := c.GetWithTTL(ctx, key)
+	return url, err
+}
+
+// GetWithTTL reports the remote entry's remaining TTL when the local tier
+// misses. Local hits report zero since the remote TTL is not known there;
+// early refresh then happens on the next local miss.
+func (c *Cache) GetWithTTL(ctx context.Context, key string) (*models.URL, time.Duration, error) {
+	if url, err := c.local.Get(ctx, key); err == nil {
+		c.observe(!service.IsMissing(url))
+		return url, 0, nil
+	}
+	c.observe(false)
+
+	var (
+		url *models.URL
+		ttl time.Duration
+		err error
+	)
+	if remote, ok := c.remote.(service.TTLCache); ok {
+		url, ttl, err = remote.GetWithTTL(ctx, key)
+	} else {
+		url, err = c.remote.Get(ctx, key)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	localTTL := c.localTTL
+	if ttl > 0 && ttl < localTTL {
+		localTTL = ttl
+	}
+	c.local.Set(ctx, key, url, localTTL)
+	return url, ttl, nil
+}
+
+func (c *Cache) Set(ctx context.Context, key string, value *models.URL, expiration time.Duration) error {
+	if err := c.remote.Set(ctx, key, value, expiration); err != nil {
+		return err
+	}
+
+	localTTL := c.localTTL
+	if expiration > 0 && expiration < localTTL {
+		localTTL = expiration
+	}
+	return c.local.Set(ctx, key, value, localTTL)
+}
+
+func (c *Cache) Delete(ctx context.Context, key string) error {
+	c.dropLocal(key)
+
+	err := c.remote.Delete(ctx, key)
+	if c.invalidator != nil {
+		err = errors.Join(err, c.invalidator.PublishInvalidation(ctx, key))
+	}
+	return err
+}
+
+// DeletePrefix is published as the prefix followed by "*", which cache keys
+// never end with.
+func (c *Cache) DeletePrefix(ctx context.Context, prefix string) error {
+	c.local.DeletePrefix(ctx, prefix)
+
+	err := c.remote.DeletePrefix(ctx, prefix)
+	if c.invalidator != nil {
+		err = errors.Join(err, c.invalidator.PublishInvalidation(ctx, prefix+prefixWildcard))
+	}
+	return err
+}
+
+// Close stops listening for invalidations. The remote cache is closed by its
+// owner.
+func (c *Cache) Close() error {
+	c.cancel()
+	return nil
+}
+
+func (c *Cache) dropLocal(key string) {
+	if prefix, ok := strings.CutSuffix(key, prefixWildcard); ok {
+		c.local.DeletePrefix(context.Background(), prefix)
+		return
+	}
+	c.local.Delete(context.Background(), key)
+}
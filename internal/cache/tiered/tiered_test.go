@@ -0,0 +1,197 @@
+package tiered
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"url-shortener-go/internal/cache/memory"
+	"url-shortener-go/internal/models"
+	repomemory "url-shortener-go/internal/repo/memory"
+	"url-shortener-go/internal/service"
+	"url-shortener-go/internal/storagetest"
+)
+
+// fakeBus delivers published keys to every subscriber synchronously, like a
+// Redis channel shared by several instances.
+type fakeBus struct {
+	mu          sync.Mutex
+	subscribers []func(key string)
+}
+
+func (b *fakeBus) PublishInvalidation(_ context.Context, key string) error {
+	b.mu.Lock()
+	subscribers := append([]func(string){}, b.subscribers...)
+	b.mu.Unlock()
+
+	for _, onInvalidate := range subscribers {
+		onInvalidate(key)
+	}
+	return nil
+}
+
+func (b *fakeBus) SubscribeInvalidations(_ context.Context, onInvalidate func(key string)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.subscribers = append(b.subscribers, onInvalidate)
+	return nil
+}
+
+// countingCache counts reads that reach the remote tier.
+type countingCache struct {
+	*memory.CacheRepository
+	gets int
+}
+
+func (c *countingCache) GetWithTTL(ctx context.Context, key string) (*models.URL, time.Duration, error) {
+	c.gets++
+	return c.CacheRepository.GetWithTTL(ctx, key)
+}
+
+func newCache(t *testing.T, remote service.Cache, bus Invalidator) *Cache {
+	t.Helper()
+
+	cache, err := New(remote, bus, 100, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	t.Cleanup(func() { cache.Close() })
+	return cache
+}
+
+func TestCache_Conformance(t *testing.T) {
+	storagetest.RunCacheTests(t, func(t *testing.T) service.Cache {
+		return newCache(t, memory.NewCacheRepository(0), &fakeBus{})
+	})
+}
+
+func TestCache_ServesHotKeysLocally(t *testing.T) {
+	remote := &countingCache{CacheRepository: memory.NewCacheRepository(0)}
+	cache := newCache(t, remote, nil)
+	ctx := context.Background()
+
+	remote.Set(ctx, "url:abc", &models.URL{ID: 1, ShortCode: "abc"}, time.Hour)
+
+	for range 3 {
+		if _, err := cache.Get(ctx, "url:abc"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if remote.gets != 1 {
+		t.Fatalf("expected one remote read, got %d", remote.gets)
+	}
+}
+
+func TestCache_DeleteInvalidatesOtherInstances(t *testing.T) {
+	remote := memory.NewCacheRepository(0)
+	bus := &fakeBus{}
+	first := newCache(t, remote, bus)
+	second := newCache(t, remote, bus)
+	ctx := context.Background()
+
+	if err := first.Set(ctx, "url:abc", &models.URL{ID: 1, OriginalURL: "https://example.com/old"}, time.Hour); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := second.Get(ctx, "url:abc"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := first.Delete(ctx, "url:abc"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := second.Get(ctx, "url:abc"); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("expected the other instance to drop its local copy, got %v", err)
+	}
+}
+
+func TestCache_LocalTTLIsCappedByExpiration(t *testing.T) {
+	cache := newCache(t, memory.NewCacheRepository(0), nil)
+	ctx := context.Background()
+
+	if err := cache.Set(ctx, "url:abc", &models.URL{ID: 1}, 20*time.Millisecond); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	if _, err := cache.Get(ctx, "url:abc"); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("expected entry to expire in both tiers, got %v", err)
+	}
+}
+
+func TestCache_DeletePrefixInvalidatesOtherInstances(t *testing.T) {
+	remote := memory.NewCacheRepository(0)
+	bus := &fakeBus{}
+	first := newCache(t, remote, bus)
+	second := newCache(t, remote, bus)
+	ctx := context.Background()
+
+	if err := first.Set(ctx, "url:abc", &models.URL{ID: 1}, time.Hour); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := second.Get(ctx, "url:abc"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := first.DeletePrefix(ctx, "url:"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := second.Get(ctx, "url:abc"); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("expected the other instance to drop its local copy, got %v", err)
+	}
+}
+
+func TestCache_CreatedLinkReplacesNegativeEntriesOnOtherInstances(t *testing.T) {
+	remote := memory.NewCacheRepository(0)
+	bus := &fakeBus{}
+	repo := repomemory.NewRepository()
+	ctx := context.Background()
+
+	newService := func() *service.Service {
+		svc := service.New(repo, newCache(t, remote, bus), "http://localhost:8080", time.Hour, time.Second,
+			service.WithNegativeCacheTTL(time.Hour))
+		t.Cleanup(func() { svc.Close(ctx) })
+		return svc
+	}
+	first, second := newService(), newService()
+
+	if _, err := second.GetFullURL(ctx, "alias", models.ClickInfo{}); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := first.CreateShortURL(ctx, models.CreateURLOptions{OriginalURL: "https://example.com", CustomCode: "alias"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := second.GetFullURL(ctx, "alias", models.ClickInfo{}); err != nil {
+		t.Fatalf("expected the other instance to serve the new link, got %v", err)
+	}
+}
+
+func TestCache_ObserverCountsNegativeEntriesAsMisses(t *testing.T) {
+	var hits, misses int
+	cache, err := New(memory.NewCacheRepository(0), nil, 100, time.Minute, WithLocalObserver(func(hit bool) {
+		if hit {
+			hits++
+		} else {
+			misses++
+		}
+	}))
+	if err != nil {
+		t.Fatalf("failed to create cache: %v", err)
+	}
+	defer cache.Close()
+	ctx := context.Background()
+
+	cache.Set(ctx, "url:abc", &models.URL{ID: 1, ShortCode: "abc"}, time.Hour)
+	cache.Set(ctx, "url:nope", &models.URL{ShortCode: "nope"}, time.Hour)
+	cache.Get(ctx, "url:abc")
+	cache.Get(ctx, "url:nope")
+
+	if hits != 1 || misses != 1 {
+		t.Fatalf("expected 1 hit and 1 miss, got %d and %d", hits, misses)
+	}
+}
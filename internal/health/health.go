@@ -0,0 +1,120 @@
+// Package health runs the dependency checks behind the readiness probe.
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Status string
+
+const (
+	// StatusOK means every check passed.
+	StatusOK Status = "ok"
+	// StatusDegraded means only optional checks failed; the instance still
+	// serves traffic.
+	StatusDegraded Status = "degraded"
+	// StatusUnavailable means a required check failed.
+	StatusUnavailable Status = "unavailable"
+	// StatusDraining means the server is shutting down.
+	StatusDraining Status = "draining"
+)
+
+// Check probes one dependency. A failing optional check degrades the report
+// but leaves the instance ready, as the service can run without it.
+type Check struct {
+	Name     string
+	Probe    func(ctx context.Context) error
+	Optional bool
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Name     string
+	Status   Status
+	Latency  time.Duration
+	Optional bool
+	Err      error
+}
+
+// Report is the outcome of all checks.
+type Report struct {
+	Status     Status
+	Components []Result
+}
+
+// Ready reports whether a load balancer should send traffic to the instance.
+func (r Report) Ready() bool {
+	return r.Status == StatusOK || r.Status == StatusDegraded
+}
+
+// Checker runs checks concurrently, each bounded by timeout.
+type Checker struct {
+	checks   []Check
+	timeout  time.Duration
+	draining atomic.Bool
+}
+
+func NewChecker(timeout time.Duration, checks ...Check) *Checker {
+	return &Checker{checks: checks, timeout: timeout}
+}
+
+// Drain makes every later report unready so load balancers stop routing new
+// requests before the server closes.
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// Check runs all checks. While draining it skips them and reports
+// StatusDraining.
+func (c *Checker) Check(ctx context.Context) Report {
+	if c.draining.Load() {
+		return Report{Status: StatusDraining, Components: []Result{}}
+	}
+
+	results := make([]Result, len(c.checks))
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Go(func() {
+			results[i] = c.run(ctx, check)
+		})
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Components: results}
+	for _, result := range results {
+		if result.Status == StatusOK {
+			continue
+		}
+		if !result.Optional {
+			report.Status = StatusUnavailable
+			break
+		}
+		report.Status = StatusDegraded
+	}
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, check Check) Result {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := check.Probe(ctx)
+	result := Result{
+		Name:     check.Name,
+		Status:   StatusOK,
+		Latency:  time.Since(start),
+		Optional: check.Optional,
+		Err:      err,
+	}
+	if err != nil {
+		result.Status = StatusUnavailable
+	}
+	return result
+}
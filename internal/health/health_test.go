@@ -0,0 +1,96 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func probe(err error) func(context.Context) error {
+	return func(context.Context) error { return err }
+}
+
+func TestChecker_Status(t *testing.T) {
+	down := errors.New("down")
+	tests := []struct {
+		name   string
+		checks []Check
+		want   Status
+		ready  bool
+	}{
+		{
+			name:   "all up",
+			checks: []Check{{Name: "database", Probe: probe(nil)}, {Name: "cache", Probe: probe(nil), Optional: true}},
+			want:   StatusOK,
+			ready:  true,
+		},
+		{
+			name:   "optional down",
+			checks: []Check{{Name: "database", Probe: probe(nil)}, {Name: "cache", Probe: probe(down), Optional: true}},
+			want:   StatusDegraded,
+			ready:  true,
+		},
+		{
+			name:   "required down",
+			checks: []Check{{Name: "database", Probe: probe(down)}, {Name: "cache", Probe: probe(down), Optional: true}},
+			want:   StatusUnavailable,
+			ready:  false,
+		},
+		{
+			name:  "no checks",
+			want:  StatusOK,
+			ready: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := NewChecker(time.Second, tt.checks...).Check(context.Background())
+			if report.Status != tt.want {
+				t.Fatalf("expected %s, got %s", tt.want, report.Status)
+			}
+			if report.Ready() != tt.ready {
+				t.Fatalf("expected ready=%v", tt.ready)
+			}
+			if len(report.Components) != len(tt.checks) {
+				t.Fatalf("expected %d components, got %d", len(tt.checks), len(report.Components))
+			}
+		})
+	}
+}
+
+func TestChecker_Timeout(t *testing.T) {
+	checker := NewChecker(10*time.Millisecond, Check{
+		Name: "database",
+		Probe: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	})
+
+	report := checker.Check(context.Background())
+	if report.Status != StatusUnavailable {
+		t.Fatalf("expected a hung check to fail, got %s", report.Status)
+	}
+	if !errors.Is(report.Components[0].Err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", report.Components[0].Err)
+	}
+}
+
+func TestChecker_Drain(t *testing.T) {
+	called := false
+	checker := NewChecker(time.Second, Check{
+		Name:  "database",
+		Probe: func(context.Context) error { called = true; return nil },
+	})
+
+	checker.Drain()
+	report := checker.Check(context.Background())
+	if report.Status != StatusDraining || report.Ready() {
+		t.Fatalf("expected draining and not ready, got %s", report.Status)
+	}
+	if called {
+		t.Fatal("expected checks to be skipped while draining")
+	}
+}
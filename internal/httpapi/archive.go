@@ -0,0 +1,56 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"url-shortener-go/internal/service"
+
+	"github.com/gorilla/mux"
+)
+
+type archivedURLResponse struct {
+	urlDetailsResponse
+	ArchivedAt string `json:"archived_at"`
+}
+
+type archivedURLsResponse struct {
+	Code string                `json:"short_code"`
+	URLs []archivedURLResponse `json:"urls"`
+}
+
+// ArchivedURLsHandler looks up the archived links that used a short code,
+// most recently archived first.
+func (h *Handlers) ArchivedURLsHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := mux.Vars(r)["code"]
+	if shortCode == "" {
+		writeError(w, http.StatusBadRequest, "missing_code", "missing short code")
+		return
+	}
+
+	urls, err := h.service.GetArchivedURLs(r.Context(), shortCode)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrArchiveDisabled):
+			writeError(w, http.StatusNotFound, "archive_disabled", "archiving is not enabled")
+		case errors.Is(err, service.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "no archived URL with this code")
+		default:
+			writeUnexpectedError(w, err)
+		}
+		return
+	}
+
+	response := archivedURLsResponse{
+		Code: shortCode,
+		URLs: make([]archivedURLResponse, 0, len(urls)),
+	}
+	for _, url := range urls {
+		response.URLs = append(response.URLs, archivedURLResponse{
+			urlDetailsResponse: h.newURLDetailsResponse(&url.URLDetails),
+			ArchivedAt:         url.ArchivedAt.UTC().Format(time.RFC3339),
+		})
+	}
+	writeJSON(w, http.StatusOK, response)
+}
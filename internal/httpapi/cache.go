@@ -0,0 +1,32 @@
+package httpapi
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// PurgeCacheHandler drops every cached link. Links themselves are untouched.
+func (h *Handlers) PurgeCacheHandler(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.PurgeCache(r.Context()); err != nil {
+		writeUnexpectedError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PurgeCachedURLHandler drops one code's cached entry, whether or not the code
+// exists.
+func (h *Handlers) PurgeCachedURLHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := mux.Vars(r)["code"]
+	if shortCode == "" {
+		writeError(w, http.StatusBadRequest, "missing_code", "missing short code")
+		return
+	}
+
+	if err := h.service.PurgeCachedURL(r.Context(), shortCode); err != nil {
+		writeUnexpectedError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
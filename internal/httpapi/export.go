@@ -0,0 +1,87 @@
+package httpapi
+
+import (
+	"net/http"
+	"time"
+
+	"url-shortener-go/internal/models"
+	"url-shortener-go/internal/transfer"
+)
+
+// exportFlushEvery is how many rows are written between flushes to the client.
+const exportFlushEvery = 500
+
+func (h *Handlers) ExportHandler(w http.ResponseWriter, r *http.Request) {
+	format := transfer.FormatJSONL
+	if raw := r.URL.Query().Get("format"); raw != "" {
+		parsed, err := transfer.ParseFormat(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_format", "format must be ndjson or csv")
+			return
+		}
+		format = parsed
+	}
+
+	// Large exports outlive the server write timeout. If a wrapping writer
+	// hides the deadline, they are cut off when it passes.
+	controller := http.NewResponseController(w)
+	if err := controller.SetWriteDeadline(time.Time{}); err != nil {
+		h.logger.Warn("export cannot lift the write deadline", "error", err)
+	}
+
+	writer, err := transfer.NewWriter(w, format)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "unexpected error")
+		return
+	}
+
+	// The status line is deferred until the first row so that a failure to
+	// start the export can still be reported as a JSON error.
+	started := false
+	start := func() {
+		extension := "ndjson"
+		if format == transfer.FormatCSV {
+			extension = "csv"
+		}
+		w.Header().Set("Content-Type", transfer.ContentType(format))
+		w.Header().Set("Content-Disposition", `attachment; filename="urls.`+extension+`"`)
+		w.WriteHeader(http.StatusOK)
+		started = true
+	}
+
+	written := 0
+	err = h.service.ExportURLs(r.Context(), func(details *models.URLDetails) error {
+		if !started {
+			start()
+		}
+		if err := writer.Write(transfer.FromDetails(details)); err != nil {
+			return err
+		}
+		written++
+		if written%exportFlushEvery == 0 {
+			if err := writer.Flush(); err != nil {
+				return err
+			}
+			if err := controller.Flush(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if !started {
+			writeUnexpectedError(w, err)
+			return
+		}
+		// The status line is already sent; abort the connection so the
+		// client sees a failed transfer instead of a truncated file.
+		panic(http.ErrAbortHandler)
+	}
+
+	if !started {
+		start()
+	}
+	if err := writer.Flush(); err != nil {
+		h.logger.Warn("export failed to write its last rows", "error", err)
+	}
+}
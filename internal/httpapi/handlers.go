@@ -3,23 +3,64 @@ package httpapi
 import (
 	"encoding/json"
 	"errors"
+	"log/slog"
+	"net"
 	"net/http"
+	"strconv"
 	"time"
 
+	"url-shortener-go/internal/health"
 	"url-shortener-go/internal/models"
 	"url-shortener-go/internal/service"
+	"url-shortener-go/internal/telemetry"
 
 	"github.com/gorilla/mux"
 )
 
-const maxBodyBytes = 1 << 20
+const (
+	maxBodyBytes      = 1 << 20
+	maxBatchBodyBytes = 16 << 20
+)
 
 type Handlers struct {
 	service *service.Service
+	health  *health.Checker
+	metrics http.Handler
+	logger  *slog.Logger
+}
+
+// HandlerOption configures optional Handlers dependencies.
+type HandlerOption func(*Handlers)
+
+// WithHealthChecker sets the checks behind the readiness probe. Without one
+// the probe only reports draining.
+func WithHealthChecker(checker *health.Checker) HandlerOption {
+	return func(h *Handlers) {
+		h.health = checker
+	}
+}
+
+// WithMetricsHandler serves handler at /metrics.
+func WithMetricsHandler(handler http.Handler) HandlerOption {
+	return func(h *Handlers) {
+		h.metrics = handler
+	}
+}
+
+// WithLogger logs failures that cannot be reported to the client. Without
+// it, slog.Default is used.
+func WithLogger(logger *slog.Logger) HandlerOption {
+	return func(h *Handlers) {
+		h.logger = logger
+	}
 }
 
-func NewHandlers(service *service.Service) *Handlers {
-	return &Handlers{service: service}
+func NewHandlers(service *service.Service, opts ...HandlerOption) *Handlers {
+	h := &Handlers{service: service, health: health.NewChecker(0), logger: slog.Default()}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
 type createShortURLRequest struct {
@@ -29,9 +70,69 @@ type createShortURLRequest struct {
 }
 
 type createShortURLResponse struct {
-	ShortURL  string `json:"short_url"`
-	Code      string `json:"code"`
-	ExpiresAt string `json:"expires_at,omitempty"`
+	ShortURL    string `json:"short_url"`
+	Code        string `json:"code"`
+	OriginalURL string `json:"original_url"`
+	// ResolvedFrom is set when the submitted destination was one of our own
+	// short links; OriginalURL is then the target it was resolved to.
+	ResolvedFrom string `json:"resolved_from,omitempty"`
+	ExpiresAt    string `json:"expires_at,omitempty"`
+}
+
+type createShortURLBatchRequest struct {
+	Items []createShortURLRequest `json:"items"`
+}
+
+type batchItemResponse struct {
+	Index int `json:"index"`
+	*createShortURLResponse
+	Error *errorResponse `json:"error,omitempty"`
+}
+
+type createShortURLBatchResponse struct {
+	Results []batchItemResponse `json:"results"`
+}
+
+type updateURLRequest struct {
+	OriginalURL *string      `json:"original_url,omitempty"`
+	ExpiresAt   nullableTime `json:"expires_at"`
+}
+
+// nullableTime tells an absent JSON field apart from an explicit null.
+type nullableTime struct {
+	Set   bool
+	Value *time.Time
+}
+
+func (t *nullableTime) UnmarshalJSON(data []byte) error {
+	t.Set = true
+	if string(data) == "null" {
+		t.Value = nil
+		return nil
+	}
+	var value time.Time
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	t.Value = &value
+	return nil
+}
+
+type urlDetailsResponse struct {
+	ID            int    `json:"id"`
+	Code          string `json:"short_code"`
+	ShortURL      string `json:"short_url"`
+	OriginalURL   string `json:"original_url"`
+	Status        string `json:"status"`
+	CreatedAt     string `json:"created_at"`
+	ExpiresAt     string `json:"expires_at,omitempty"`
+	ClickCount    int64  `json:"click_count"`
+	LastClickedAt string `json:"last_clicked_at,omitempty"`
+}
+
+type listURLsResponse struct {
+	URLs       []urlDetailsResponse `json:"urls"`
+	NextCursor string               `json:"next_cursor,omitempty"`
 }
 
 func (h *Handlers) CreateShortURLHandler(w http.ResponseWriter, r *http.Request) {
@@ -44,68 +145,364 @@ func (h *Handlers) CreateShortURLHandler(w http.ResponseWriter, r *http.Request)
 		writeError(w, http.StatusBadRequest, "bad_request", "invalid JSON body")
 		return
 	}
-	if payload.OriginalURL == "" {
-		writeError(w, http.StatusBadRequest, "missing_url", "original_url is required")
+
+	opts, errResp := payload.toOptions()
+	if errResp != nil {
+		writeError(w, http.StatusBadRequest, errResp.Code, errResp.Message)
+		return
+	}
+
+	url, err := h.service.CreateShortURL(r.Context(), opts)
+	if err != nil {
+		status, errResp := createErrorResponse(err)
+		writeError(w, status, errResp.Code, errResp.Message)
 		return
 	}
 
+	writeJSON(w, http.StatusCreated, h.newCreateShortURLResponse(url))
+}
+
+func (p createShortURLRequest) toOptions() (models.CreateURLOptions, *errorResponse) {
+	if p.OriginalURL == "" {
+		return models.CreateURLOptions{}, &errorResponse{Code: "missing_url", Message: "original_url is required"}
+	}
+
 	var expiresIn time.Duration
-	if payload.ExpiresInSeconds != nil {
-		if *payload.ExpiresInSeconds < 0 {
-			writeError(w, http.StatusBadRequest, "invalid_expires_in", "expires_in_seconds must be positive")
-			return
+	if p.ExpiresInSeconds != nil {
+		if *p.ExpiresInSeconds < 0 {
+			return models.CreateURLOptions{}, &errorResponse{Code: "invalid_expires_in", Message: "expires_in_seconds must be positive"}
 		}
-		expiresIn = time.Duration(*payload.ExpiresInSeconds) * time.Second
+		expiresIn = time.Duration(*p.ExpiresInSeconds) * time.Second
 	}
 
-	url, err := h.service.CreateShortURL(r.Context(), models.CreateURLOptions{
-		OriginalURL: payload.OriginalURL,
-		CustomCode:  payload.CustomCode,
+	return models.CreateURLOptions{
+		OriginalURL: p.OriginalURL,
+		CustomCode:  p.CustomCode,
 		ExpiresIn:   expiresIn,
-	})
+	}, nil
+}
+
+func createErrorResponse(err error) (int, *errorResponse) {
+	switch {
+	case errors.Is(err, service.ErrInvalidURL):
+		return http.StatusBadRequest, destinationErrorResponse(err)
+	case errors.Is(err, service.ErrConflict):
+		return http.StatusConflict, &errorResponse{Code: "short_code_conflict", Message: "short code already exists"}
+	case errors.Is(err, service.ErrReservedCode):
+		return http.StatusBadRequest, &errorResponse{Code: "reserved_code", Message: "custom_code is reserved for an API route"}
+	default:
+		return unexpectedErrorResponse(err)
+	}
+}
+
+// destinationErrorResponse answers a rejected destination URL. Destination
+// policy violations get their own codes.
+func destinationErrorResponse(err error) *errorResponse {
+	switch {
+	case errors.Is(err, service.ErrSchemeNotAllowed):
+		return &errorResponse{Code: "scheme_not_allowed", Message: "URL scheme is not allowed"}
+	case errors.Is(err, service.ErrDomainBlocked):
+		return &errorResponse{Code: "domain_blocked", Message: "destination domain is blocked"}
+	case errors.Is(err, service.ErrDomainNotAllowed):
+		return &errorResponse{Code: "domain_not_allowed", Message: "destination domain is not allowed"}
+	case errors.Is(err, service.ErrPrivateAddress):
+		return &errorResponse{Code: "private_address", Message: "destination must not be a private or loopback address"}
+	case errors.Is(err, service.ErrSelfLink):
+		return &errorResponse{Code: "self_link", Message: "destination must not point back to this shortener, except to an active short link"}
+	default:
+		return &errorResponse{Code: "invalid_url", Message: "invalid URL"}
+	}
+}
+
+func (h *Handlers) newCreateShortURLResponse(url *models.URL) createShortURLResponse {
+	response := createShortURLResponse{
+		ShortURL:     h.service.GenerateShortURL(url.ShortCode),
+		Code:         url.ShortCode,
+		OriginalURL:  url.OriginalURL,
+		ResolvedFrom: url.ResolvedFrom,
+	}
+	if url.ExpiresAt != nil {
+		response.ExpiresAt = url.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	return response
+}
+
+func (h *Handlers) CreateShortURLBatchHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBatchBodyBytes)
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	var payload createShortURLBatchRequest
+	if err := decoder.Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid JSON body")
+		return
+	}
+	if len(payload.Items) == 0 {
+		writeError(w, http.StatusBadRequest, "empty_batch", "items is required")
+		return
+	}
+	if len(payload.Items) > service.MaxBatchSize {
+		writeError(w, http.StatusBadRequest, "batch_too_large", "at most "+strconv.Itoa(service.MaxBatchSize)+" items are allowed")
+		return
+	}
+
+	response := createShortURLBatchResponse{Results: make([]batchItemResponse, len(payload.Items))}
+	// Items rejected here are not sent to the service; indexes maps service
+	// results back to their position in the request.
+	var (
+		items   []models.CreateURLOptions
+		indexes []int
+	)
+	for i, item := range payload.Items {
+		response.Results[i].Index = i
+		opts, errResp := item.toOptions()
+		if errResp != nil {
+			response.Results[i].Error = errResp
+			continue
+		}
+		items = append(items, opts)
+		indexes = append(indexes, i)
+	}
+
+	results, err := h.service.CreateShortURLBatch(r.Context(), items)
+	if err != nil {
+		writeUnexpectedError(w, err)
+		return
+	}
+
+	for j, result := range results {
+		item := &response.Results[indexes[j]]
+		if result.Err != nil {
+			_, item.Error = createErrorResponse(result.Err)
+			continue
+		}
+		created := h.newCreateShortURLResponse(result.URL)
+		item.createShortURLResponse = &created
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (h *Handlers) GetFullURLHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := mux.Vars(r)["code"]
+	if shortCode == "" {
+		writeError(w, http.StatusBadRequest, "missing_code", "missing short code")
+		return
+	}
+
+	url, err := h.service.GetFullURL(r.Context(), shortCode, clickInfo(r))
 	if err != nil {
 		switch {
-		case errors.Is(err, service.ErrInvalidURL):
-			writeError(w, http.StatusBadRequest, "invalid_url", "invalid URL")
-		case errors.Is(err, service.ErrConflict):
-			writeError(w, http.StatusConflict, "short_code_conflict", "short code already exists")
+		case errors.Is(err, service.ErrExpired):
+			writeError(w, http.StatusGone, "expired", "URL has expired")
+		case errors.Is(err, service.ErrDisabled):
+			writeError(w, http.StatusGone, "disabled", "URL has been disabled")
+		case errors.Is(err, service.ErrDeleted):
+			writeError(w, http.StatusGone, "deleted", "URL has been deleted")
+		case errors.Is(err, service.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "URL not found")
 		default:
-			writeError(w, http.StatusInternalServerError, "internal_error", "unexpected error")
+			writeUnexpectedError(w, err)
 		}
 		return
 	}
 
-	response := createShortURLResponse{
-		ShortURL: h.service.GenerateShortURL(url.ShortCode),
-		Code:     url.ShortCode,
+	http.Redirect(w, r, url.OriginalURL, http.StatusFound)
+}
+
+func clickInfo(r *http.Request) models.ClickInfo {
+	clientIP, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		clientIP = r.RemoteAddr
 	}
-	if url.ExpiresAt != nil {
-		response.ExpiresAt = url.ExpiresAt.UTC().Format(time.RFC3339)
+
+	return models.ClickInfo{
+		Referrer:  r.Referer(),
+		UserAgent: r.UserAgent(),
+		ClientIP:  clientIP,
+		RequestID: telemetry.GetRequestID(r.Context()),
+	}
+}
+
+func (h *Handlers) GetURLDetailsHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := mux.Vars(r)["code"]
+	if shortCode == "" {
+		writeError(w, http.StatusBadRequest, "missing_code", "missing short code")
+		return
+	}
+
+	details, err := h.service.GetURLDetails(r.Context(), shortCode)
+	if err != nil {
+		if errors.Is(err, service.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "not_found", "URL not found")
+			return
+		}
+		writeUnexpectedError(w, err)
+		return
 	}
 
-	writeJSON(w, http.StatusCreated, response)
+	writeJSON(w, http.StatusOK, h.newURLDetailsResponse(details))
 }
 
-func (h *Handlers) GetFullURLHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) UpdateURLHandler(w http.ResponseWriter, r *http.Request) {
 	shortCode := mux.Vars(r)["code"]
 	if shortCode == "" {
 		writeError(w, http.StatusBadRequest, "missing_code", "missing short code")
 		return
 	}
 
-	url, err := h.service.GetFullURL(r.Context(), shortCode)
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	var payload updateURLRequest
+	if err := decoder.Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid JSON body")
+		return
+	}
+	if payload.OriginalURL == nil && !payload.ExpiresAt.Set {
+		writeError(w, http.StatusBadRequest, "empty_update", "original_url or expires_at is required")
+		return
+	}
+
+	opts := models.UpdateURLOptions{
+		OriginalURL:    payload.OriginalURL,
+		ExpiresAt:      payload.ExpiresAt.Value,
+		ClearExpiresAt: payload.ExpiresAt.Set && payload.ExpiresAt.Value == nil,
+	}
+
+	details, err := h.service.UpdateURL(r.Context(), shortCode, opts)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidURL):
+			resp := destinationErrorResponse(err)
+			writeError(w, http.StatusBadRequest, resp.Code, resp.Message)
+		case errors.Is(err, service.ErrInvalidExpiry):
+			writeError(w, http.StatusBadRequest, "invalid_expires_at", "expires_at must be in the future")
+		case errors.Is(err, service.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "URL not found")
+		default:
+			writeUnexpectedError(w, err)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.newURLDetailsResponse(details))
+}
+
+func (h *Handlers) DeleteURLHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.setURLStatus(w, r, models.URLStatusDeleted); ok {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (h *Handlers) DisableURLHandler(w http.ResponseWriter, r *http.Request) {
+	if details, ok := h.setURLStatus(w, r, models.URLStatusDisabled); ok {
+		writeJSON(w, http.StatusOK, h.newURLDetailsResponse(details))
+	}
+}
+
+func (h *Handlers) RestoreURLHandler(w http.ResponseWriter, r *http.Request) {
+	if details, ok := h.setURLStatus(w, r, models.URLStatusActive); ok {
+		writeJSON(w, http.StatusOK, h.newURLDetailsResponse(details))
+	}
+}
+
+// setURLStatus applies a status change and writes the error response on failure.
+func (h *Handlers) setURLStatus(w http.ResponseWriter, r *http.Request, status models.URLStatus) (*models.URLDetails, bool) {
+	shortCode := mux.Vars(r)["code"]
+	if shortCode == "" {
+		writeError(w, http.StatusBadRequest, "missing_code", "missing short code")
+		return nil, false
+	}
+
+	details, err := h.service.SetURLStatus(r.Context(), shortCode, status)
 	if err != nil {
 		if errors.Is(err, service.ErrNotFound) {
 			writeError(w, http.StatusNotFound, "not_found", "URL not found")
+			return nil, false
+		}
+		writeUnexpectedError(w, err)
+		return nil, false
+	}
+
+	return details, true
+}
+
+func (h *Handlers) ListURLsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	opts := models.ListURLsOptions{
+		Cursor: query.Get("cursor"),
+		Status: query.Get("status"),
+		Query:  query.Get("q"),
+	}
+
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 || limit > service.MaxListLimit {
+			writeError(w, http.StatusBadRequest, "invalid_limit", "limit must be between 1 and "+strconv.Itoa(service.MaxListLimit))
 			return
 		}
-		writeError(w, http.StatusInternalServerError, "internal_error", "unexpected error")
+		opts.Limit = limit
+	}
+	for _, param := range []struct {
+		name   string
+		target **time.Time
+	}{
+		{"created_after", &opts.CreatedAfter},
+		{"created_before", &opts.CreatedBefore},
+	} {
+		raw := query.Get(param.name)
+		if raw == "" {
+			continue
+		}
+		value, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_"+param.name, param.name+" must be an RFC 3339 timestamp")
+			return
+		}
+		*param.target = &value
+	}
+
+	page, err := h.service.ListURLs(r.Context(), opts)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidCursor):
+			writeError(w, http.StatusBadRequest, "invalid_cursor", "invalid cursor")
+		case errors.Is(err, service.ErrInvalidFilter):
+			writeError(w, http.StatusBadRequest, "invalid_filter", "invalid filter")
+		default:
+			writeUnexpectedError(w, err)
+		}
 		return
 	}
 
-	http.Redirect(w, r, url.OriginalURL, http.StatusFound)
+	response := listURLsResponse{
+		URLs:       make([]urlDetailsResponse, 0, len(page.URLs)),
+		NextCursor: page.NextCursor,
+	}
+	for i := range page.URLs {
+		response.URLs = append(response.URLs, h.newURLDetailsResponse(&page.URLs[i]))
+	}
+
+	writeJSON(w, http.StatusOK, response)
 }
 
-func (h *Handlers) HealthHandler(w http.ResponseWriter, _ *http.Request) {
-	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+func (h *Handlers) newURLDetailsResponse(details *models.URLDetails) urlDetailsResponse {
+	response := urlDetailsResponse{
+		ID:          details.ID,
+		Code:        details.ShortCode,
+		ShortURL:    h.service.GenerateShortURL(details.ShortCode),
+		OriginalURL: details.OriginalURL,
+		Status:      string(details.Status),
+		CreatedAt:   details.CreatedAt.UTC().Format(time.RFC3339),
+		ClickCount:  details.ClickCount,
+	}
+	if details.ExpiresAt != nil {
+		response.ExpiresAt = details.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	if details.LastClickedAt != nil {
+		response.LastClickedAt = details.LastClickedAt.UTC().Format(time.RFC3339)
+	}
+	return response
 }
@@ -4,19 +4,34 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"url-shortener-go/internal/health"
+	"url-shortener-go/internal/httpapi/middleware"
+	"url-shortener-go/internal/metrics"
 	"url-shortener-go/internal/models"
 	"url-shortener-go/internal/service"
+	"url-shortener-go/internal/telemetry"
 
 	"github.com/gorilla/mux"
 )
 
 type stubRepo struct {
 	created *models.URL
+	details *models.URLDetails
+	updated *models.UpdateURLOptions
+	status  models.URLStatus
+	getErr  error
+	link    *models.URL
+	hourly  []models.ClickBucket
 }
 
 func (s *stubRepo) Create(_ context.Context, url *models.URL) error {
@@ -24,7 +39,23 @@ func (s *stubRepo) Create(_ context.Context, url *models.URL) error {
 	return nil
 }
 
+func (s *stubRepo) CreateBatch(_ context.Context, urls []*models.URL) ([]error, error) {
+	errs := make([]error, len(urls))
+	for i, url := range urls {
+		if url.ShortCode == "taken" {
+			errs[i] = service.ErrConflict
+		}
+	}
+	return errs, nil
+}
+
 func (s *stubRepo) GetByShortCode(_ context.Context, _ string) (*models.URL, error) {
+	if s.getErr != nil {
+		return nil, s.getErr
+	}
+	if s.link != nil {
+		return s.link, nil
+	}
 	return nil, service.ErrNotFound
 }
 
@@ -32,14 +63,61 @@ func (s *stubRepo) GetByOriginalURL(_ context.Context, _ string) (*models.URL, e
 	return nil, service.ErrNotFound
 }
 
-func (s *stubRepo) IncrementClickCount(_ context.Context, _ int) error {
-	return nil
+func (s *stubRepo) GetByOriginalURLs(_ context.Context, _ []string) (map[string]*models.URL, error) {
+	return map[string]*models.URL{}, nil
+}
+
+func (s *stubRepo) GetDetailsByShortCode(_ context.Context, _ string) (*models.URLDetails, error) {
+	if s.details == nil {
+		return nil, service.ErrNotFound
+	}
+	return s.details, nil
+}
+
+func (s *stubRepo) List(_ context.Context, _ models.ListURLsFilter) ([]models.URLDetails, error) {
+	if s.details == nil {
+		return nil, nil
+	}
+	return []models.URLDetails{*s.details}, nil
+}
+
+func (s *stubRepo) Update(_ context.Context, _ string, opts models.UpdateURLOptions) (*models.URLDetails, error) {
+	s.updated = &opts
+	if s.details == nil {
+		return nil, service.ErrNotFound
+	}
+	return s.details, nil
+}
+
+func (s *stubRepo) SetStatus(_ context.Context, _ string, status models.URLStatus) (*models.URLDetails, error) {
+	s.status = status
+	if s.details == nil {
+		return nil, service.ErrNotFound
+	}
+	details := *s.details
+	details.Status = status
+	return &details, nil
 }
 
-func (s *stubRepo) DeleteExpiredURLs(_ context.Context) error {
+func (s *stubRepo) ExportURLs(_ context.Context, fn func(*models.URLDetails) error) error {
+	if s.details == nil {
+		return nil
+	}
+	return fn(s.details)
+}
+
+func (s *stubRepo) RecordClicks(_ context.Context, _ []models.ClickCount, _ []models.Click) error {
 	return nil
 }
 
+func (s *stubRepo) GetHourlyClicks(_ context.Context, _ int, _, _ time.Time) ([]models.ClickBucket, error) {
+	return s.hourly, nil
+}
+
+func (s *stubRepo) DeleteExpiredURLs(_ context.Context, _ int, _ service.ArchiveFunc) (int64, error) {
+	return 0, nil
+}
+
 func (s *stubRepo) Close() error {
 	return nil
 }
@@ -54,6 +132,14 @@ func (s *stubCache) Get(_ context.Context, _ string) (*models.URL, error) {
 	return nil, service.ErrNotFound
 }
 
+func (s *stubCache) Delete(_ context.Context, _ string) error {
+	return nil
+}
+
+func (s *stubCache) DeletePrefix(_ context.Context, _ string) error {
+	return nil
+}
+
 func TestCreateShortURLHandler_Success(t *testing.T) {
 	repo := &stubRepo{}
 	cache := &stubCache{}
@@ -111,6 +197,64 @@ func TestCreateShortURLHandler_MissingURL(t *testing.T) {
 	}
 }
 
+func TestCreateShortURLHandler_DestinationPolicy(t *testing.T) {
+	svc := service.New(&stubRepo{}, &stubCache{}, "http://localhost:8080", time.Hour, 2*time.Second,
+		service.WithDestinationPolicy(service.DestinationPolicy{
+			AllowedDomains: []string{"*.example.com", "10.0.0.1"},
+			BlockedDomains: []string{"bad.example.com"},
+		}))
+	handlers := NewHandlers(svc)
+
+	tests := []struct {
+		url  string
+		code string
+	}{
+		{"javascript:alert(1)", "scheme_not_allowed"},
+		{"https://example.org", "domain_not_allowed"},
+		{"https://bad.example.com", "domain_blocked"},
+		{"http://10.0.0.1", "private_address"},
+		{"https://", "invalid_url"},
+		{"http://localhost:8080/abc123", "self_link"},
+	}
+	for _, tt := range tests {
+		body, _ := json.Marshal(map[string]string{"original_url": tt.url})
+		req := httptest.NewRequest(http.MethodPost, "/v1/shorten", bytes.NewReader(body))
+		rec := httptest.NewRecorder()
+
+		handlers.CreateShortURLHandler(rec, req)
+
+		var resp errorResponse
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		if rec.Code != http.StatusBadRequest || resp.Code != tt.code {
+			t.Fatalf("%s: expected 400 %s, got %d %s", tt.url, tt.code, rec.Code, resp.Code)
+		}
+	}
+}
+
+func TestCreateShortURLHandler_ReportsResolvedSelfLink(t *testing.T) {
+	repo := &stubRepo{link: &models.URL{ID: 1, ShortCode: "abc123", OriginalURL: "https://example.com/target", Status: models.URLStatusActive}}
+	svc := service.New(repo, &stubCache{}, "http://localhost:8080", time.Hour, 2*time.Second, service.WithSelfLinks(nil, true))
+	handlers := NewHandlers(svc)
+
+	body, _ := json.Marshal(map[string]string{"original_url": "http://localhost:8080/abc123"})
+	req := httptest.NewRequest(http.MethodPost, "/v1/shorten", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	handlers.CreateShortURLHandler(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rec.Code)
+	}
+	var resp createShortURLResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp.OriginalURL != "https://example.com/target" || resp.ResolvedFrom != "http://localhost:8080/abc123" {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	if repo.created == nil || repo.created.OriginalURL != "https://example.com/target" {
+		t.Fatalf("expected the target to be stored, got %+v", repo.created)
+	}
+}
+
 func TestCreateShortURLHandler_NegativeExpires(t *testing.T) {
 	repo := &stubRepo{}
 	cache := &stubCache{}
@@ -132,6 +276,64 @@ func TestCreateShortURLHandler_NegativeExpires(t *testing.T) {
 	}
 }
 
+func TestCreateShortURLBatchHandler_PerItemErrors(t *testing.T) {
+	handlers := NewHandlers(service.New(&stubRepo{}, &stubCache{}, "http://localhost:8080", time.Hour, 2*time.Second))
+
+	body := `{"items":[
+		{"original_url":"https://example.com"},
+		{"original_url":"example.com"},
+		{"original_url":"https://example.com/taken","custom_code":"taken"},
+		{"original_url":""}
+	]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/shorten/batch", bytes.NewBufferString(body))
+	rec := httptest.NewRecorder()
+
+	handlers.CreateShortURLBatchHandler(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var response struct {
+		Results []struct {
+			Index int            `json:"index"`
+			Code  string         `json:"code"`
+			Error *errorResponse `json:"error"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(response.Results) != 4 {
+		t.Fatalf("expected 4 results, got %d", len(response.Results))
+	}
+	if response.Results[0].Error != nil || response.Results[0].Code == "" {
+		t.Fatalf("expected item 0 to succeed, got %+v", response.Results[0])
+	}
+	expected := []string{"", "invalid_url", "short_code_conflict", "missing_url"}
+	for i := 1; i < len(expected); i++ {
+		if response.Results[i].Index != i {
+			t.Fatalf("expected index %d, got %d", i, response.Results[i].Index)
+		}
+		if response.Results[i].Error == nil || response.Results[i].Error.Code != expected[i] {
+			t.Fatalf("item %d: expected %s, got %+v", i, expected[i], response.Results[i].Error)
+		}
+	}
+}
+
+func TestCreateShortURLBatchHandler_Empty(t *testing.T) {
+	handlers := NewHandlers(service.New(&stubRepo{}, &stubCache{}, "http://localhost:8080", time.Hour, 2*time.Second))
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/shorten/batch", bytes.NewBufferString(`{"items":[]}`))
+	rec := httptest.NewRecorder()
+
+	handlers.CreateShortURLBatchHandler(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+}
+
 func TestGetFullURLHandler_NotFound(t *testing.T) {
 	repo := &stubRepo{}
 	cache := &stubCache{}
@@ -149,6 +351,441 @@ func TestGetFullURLHandler_NotFound(t *testing.T) {
 	}
 }
 
+func TestGetURLDetailsHandler_Success(t *testing.T) {
+	lastClicked := time.Date(2026, 2, 15, 10, 0, 0, 0, time.UTC)
+	repo := &stubRepo{details: &models.URLDetails{
+		URL: models.URL{
+			ID:          7,
+			ShortCode:   "abc123",
+			OriginalURL: "https://example.com",
+			CreatedAt:   time.Date(2026, 2, 1, 9, 0, 0, 0, time.UTC),
+		},
+		ClickCount:    42,
+		LastClickedAt: &lastClicked,
+	}}
+	svc := service.New(repo, &stubCache{}, "http://localhost:8080", time.Hour, 2*time.Second)
+	handlers := NewHandlers(svc)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/urls/abc123", nil)
+	req = mux.SetURLVars(req, map[string]string{"code": "abc123"})
+	rec := httptest.NewRecorder()
+
+	handlers.GetURLDetailsHandler(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var body urlDetailsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if body.ClickCount != 42 {
+		t.Fatalf("expected click count 42, got %d", body.ClickCount)
+	}
+	if body.ShortURL != "http://localhost:8080/abc123" {
+		t.Fatalf("unexpected short url: %s", body.ShortURL)
+	}
+	if body.LastClickedAt != "2026-02-15T10:00:00Z" {
+		t.Fatalf("unexpected last clicked at: %s", body.LastClickedAt)
+	}
+}
+
+func TestGetURLDetailsHandler_NotFound(t *testing.T) {
+	svc := service.New(&stubRepo{}, &stubCache{}, "http://localhost:8080", time.Hour, 2*time.Second)
+	handlers := NewHandlers(svc)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/urls/missing", nil)
+	req = mux.SetURLVars(req, map[string]string{"code": "missing"})
+	rec := httptest.NewRecorder()
+
+	handlers.GetURLDetailsHandler(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+}
+
+func TestClickStatsHandler_Success(t *testing.T) {
+	repo := &stubRepo{
+		details: &models.URLDetails{URL: models.URL{ID: 7, ShortCode: "abc123"}},
+		hourly: []models.ClickBucket{
+			{Start: time.Date(2026, 2, 15, 9, 0, 0, 0, time.UTC), Clicks: 3},
+			{Start: time.Date(2026, 2, 16, 18, 0, 0, 0, time.UTC), Clicks: 2},
+		},
+	}
+	handlers := NewHandlers(service.New(repo, &stubCache{}, "http://localhost:8080", time.Hour, 2*time.Second))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/urls/abc123/stats?interval=day&from=2026-02-15T00:00:00Z&to=2026-02-18T00:00:00Z", nil)
+	req = mux.SetURLVars(req, map[string]string{"code": "abc123"})
+	rec := httptest.NewRecorder()
+
+	handlers.ClickStatsHandler(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var body clickStatsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if body.Total != 5 || len(body.Buckets) != 3 {
+		t.Fatalf("unexpected stats: %+v", body)
+	}
+	if body.Buckets[0].Start != "2026-02-15T00:00:00Z" || body.Buckets[0].Clicks != 3 {
+		t.Fatalf("unexpected first bucket: %+v", body.Buckets[0])
+	}
+	if body.Buckets[2].Clicks != 0 {
+		t.Fatalf("expected empty bucket to be zero-filled, got %+v", body.Buckets[2])
+	}
+}
+
+func TestClickStatsHandler_InvalidParams(t *testing.T) {
+	repo := &stubRepo{details: &models.URLDetails{URL: models.URL{ID: 7, ShortCode: "abc123"}}}
+	handlers := NewHandlers(service.New(repo, &stubCache{}, "http://localhost:8080", time.Hour, 2*time.Second))
+
+	for _, target := range []string{
+		"/v1/urls/abc123/stats?interval=week",
+		"/v1/urls/abc123/stats?from=yesterday",
+		"/v1/urls/abc123/stats?from=2026-02-15T00:00:00Z&to=2026-02-14T00:00:00Z",
+		"/v1/urls/abc123/stats?from=2026-01-01T00:00:00Z&to=2026-03-01T00:00:00Z",
+	} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req = mux.SetURLVars(req, map[string]string{"code": "abc123"})
+		rec := httptest.NewRecorder()
+
+		handlers.ClickStatsHandler(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", target, rec.Code)
+		}
+	}
+}
+
+func TestListURLsHandler_Success(t *testing.T) {
+	repo := &stubRepo{details: &models.URLDetails{URL: models.URL{ID: 1, ShortCode: "abc123", OriginalURL: "https://example.com"}}}
+	handlers := NewHandlers(service.New(repo, &stubCache{}, "http://localhost:8080", time.Hour, 2*time.Second))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/urls?status=active&q=example", nil)
+	rec := httptest.NewRecorder()
+
+	handlers.ListURLsHandler(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var body listURLsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(body.URLs) != 1 || body.URLs[0].Code != "abc123" {
+		t.Fatalf("unexpected urls: %+v", body.URLs)
+	}
+}
+
+func TestListURLsHandler_InvalidParams(t *testing.T) {
+	handlers := NewHandlers(service.New(&stubRepo{}, &stubCache{}, "http://localhost:8080", time.Hour, 2*time.Second))
+
+	for _, target := range []string{
+		"/v1/urls?limit=0",
+		"/v1/urls?limit=abc",
+		"/v1/urls?created_after=yesterday",
+		"/v1/urls?status=unknown",
+		"/v1/urls?cursor=%21%21",
+	} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+
+		handlers.ListURLsHandler(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", target, rec.Code)
+		}
+	}
+}
+
+func TestUpdateURLHandler_ClearsExpiry(t *testing.T) {
+	repo := &stubRepo{details: &models.URLDetails{URL: models.URL{ID: 1, ShortCode: "abc123", OriginalURL: "https://example.com"}}}
+	handlers := NewHandlers(service.New(repo, &stubCache{}, "http://localhost:8080", time.Hour, 2*time.Second))
+
+	req := httptest.NewRequest(http.MethodPatch, "/v1/urls/abc123", bytes.NewBufferString(`{"original_url":"https://example.org","expires_at":null}`))
+	req = mux.SetURLVars(req, map[string]string{"code": "abc123"})
+	rec := httptest.NewRecorder()
+
+	handlers.UpdateURLHandler(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if repo.updated == nil || !repo.updated.ClearExpiresAt {
+		t.Fatalf("expected expiry to be cleared, got %+v", repo.updated)
+	}
+	if repo.updated.OriginalURL == nil || *repo.updated.OriginalURL != "https://example.org" {
+		t.Fatalf("expected original url to be updated, got %+v", repo.updated)
+	}
+}
+
+func TestUpdateURLHandler_EmptyBody(t *testing.T) {
+	handlers := NewHandlers(service.New(&stubRepo{}, &stubCache{}, "http://localhost:8080", time.Hour, 2*time.Second))
+
+	req := httptest.NewRequest(http.MethodPatch, "/v1/urls/abc123", bytes.NewBufferString(`{}`))
+	req = mux.SetURLVars(req, map[string]string{"code": "abc123"})
+	rec := httptest.NewRecorder()
+
+	handlers.UpdateURLHandler(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+}
+
+func TestGetFullURLHandler_Gone(t *testing.T) {
+	for _, err := range []error{service.ErrExpired, service.ErrDisabled, service.ErrDeleted} {
+		repo := &stubRepo{getErr: err}
+		handlers := NewHandlers(service.New(repo, &stubCache{}, "http://localhost:8080", time.Hour, 2*time.Second))
+
+		req := httptest.NewRequest(http.MethodGet, "/abc123", nil)
+		req = mux.SetURLVars(req, map[string]string{"code": "abc123"})
+		rec := httptest.NewRecorder()
+
+		handlers.GetFullURLHandler(rec, req)
+
+		if rec.Code != http.StatusGone {
+			t.Fatalf("%v: expected 410, got %d", err, rec.Code)
+		}
+	}
+}
+
+func TestDeleteURLHandler_NoContent(t *testing.T) {
+	repo := &stubRepo{details: &models.URLDetails{URL: models.URL{ID: 1, ShortCode: "abc123"}}}
+	handlers := NewHandlers(service.New(repo, &stubCache{}, "http://localhost:8080", time.Hour, 2*time.Second))
+
+	req := httptest.NewRequest(http.MethodDelete, "/v1/urls/abc123", nil)
+	req = mux.SetURLVars(req, map[string]string{"code": "abc123"})
+	rec := httptest.NewRecorder()
+
+	handlers.DeleteURLHandler(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rec.Code)
+	}
+	if repo.status != models.URLStatusDeleted {
+		t.Fatalf("expected status deleted, got %q", repo.status)
+	}
+}
+
+func TestRestoreURLHandler_NotFound(t *testing.T) {
+	handlers := NewHandlers(service.New(&stubRepo{}, &stubCache{}, "http://localhost:8080", time.Hour, 2*time.Second))
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/urls/missing/restore", nil)
+	req = mux.SetURLVars(req, map[string]string{"code": "missing"})
+	rec := httptest.NewRecorder()
+
+	handlers.RestoreURLHandler(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+}
+
+func TestExportHandler_Formats(t *testing.T) {
+	repo := &stubRepo{details: &models.URLDetails{
+		URL:        models.URL{ID: 1, ShortCode: "abc123", OriginalURL: "https://example.com", Status: models.URLStatusActive},
+		ClickCount: 5,
+	}}
+	handlers := NewHandlers(service.New(repo, &stubCache{}, "http://localhost:8080", time.Hour, 2*time.Second))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/export", nil)
+	rec := httptest.NewRecorder()
+	handlers.ExportHandler(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/x-ndjson" {
+		t.Fatalf("unexpected content type %q", got)
+	}
+	var record map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &record); err != nil {
+		t.Fatalf("failed to decode NDJSON line: %v", err)
+	}
+	if record["code"] != "abc123" || record["click_count"] != float64(5) {
+		t.Fatalf("unexpected record: %v", record)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/export?format=csv", nil)
+	rec = httptest.NewRecorder()
+	handlers.ExportHandler(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if !bytes.HasPrefix(rec.Body.Bytes(), []byte("code,original_url,")) {
+		t.Fatalf("expected CSV header, got %q", rec.Body.String())
+	}
+}
+
+// slowExportRepo exports rows rows, pausing between flushes.
+type slowExportRepo struct {
+	*stubRepo
+	rows  int
+	pause time.Duration
+}
+
+func (s *slowExportRepo) ExportURLs(_ context.Context, fn func(*models.URLDetails) error) error {
+	for i := range s.rows {
+		if i%exportFlushEvery == 0 {
+			time.Sleep(s.pause)
+		}
+		if err := fn(&models.URLDetails{URL: models.URL{ID: i + 1, ShortCode: fmt.Sprintf("c%d", i), OriginalURL: "https://example.com"}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The export must outlive the server's write timeout through every
+// middleware the server wraps it in.
+func TestExportHandler_StreamsPastWriteTimeoutThroughMiddleware(t *testing.T) {
+	repo := &slowExportRepo{stubRepo: &stubRepo{}, rows: 3*exportFlushEvery + 1, pause: 40 * time.Millisecond}
+	handlers := NewHandlers(service.New(repo, &stubCache{}, "http://localhost:8080", time.Hour, 2*time.Second))
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	router := SetupRoutes(handlers, false)
+	router.Use(telemetry.RequestIDMiddleware)
+	router.Use(telemetry.TracingMiddleware)
+	router.Use(metrics.New().Middleware)
+	router.Use(telemetry.LoggingMiddleware(logger))
+	router.Use(telemetry.RecoveryMiddleware(logger))
+	router.Use(middleware.CorsMiddleware)
+	router.Use(middleware.AuthMiddleware("secret", false))
+
+	server := httptest.NewUnstartedServer(router)
+	server.Config.WriteTimeout = 50 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/v1/export", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("export was cut off after %d bytes: %v", len(body), err)
+	}
+	if lines := bytes.Count(body, []byte("\n")); lines != repo.rows {
+		t.Fatalf("expected %d rows, got %d", repo.rows, lines)
+	}
+}
+
+func TestExportHandler_InvalidFormat(t *testing.T) {
+	handlers := NewHandlers(service.New(&stubRepo{}, &stubCache{}, "http://localhost:8080", time.Hour, 2*time.Second))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/export?format=xml", nil)
+	rec := httptest.NewRecorder()
+	handlers.ExportHandler(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+}
+
+func TestCreateShortURLHandler_ReadOnly(t *testing.T) {
+	svc := service.New(&stubRepo{}, &stubCache{}, "http://localhost:8080", time.Hour, 2*time.Second, service.WithReadOnly(true))
+	handlers := NewHandlers(svc)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/shorten", bytes.NewBufferString(`{"original_url":"https://example.com"}`))
+	rec := httptest.NewRecorder()
+	handlers.CreateShortURLHandler(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rec.Code)
+	}
+	var body errorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Code != "read_only" {
+		t.Fatalf("expected read_only error, got %s", rec.Body.String())
+	}
+}
+
+type stubArchive struct {
+	urls []models.ArchivedURL
+}
+
+func (s *stubArchive) ArchiveURLs(_ context.Context, _ []models.URLDetails) error {
+	return nil
+}
+
+func (s *stubArchive) GetArchivedURLs(_ context.Context, _ string) ([]models.ArchivedURL, error) {
+	return s.urls, nil
+}
+
+func (s *stubArchive) PurgeArchivedURLs(_ context.Context, _ time.Time) (int64, error) {
+	return 0, nil
+}
+
+func TestArchivedURLsHandler(t *testing.T) {
+	get := func(svc *service.Service) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v1/archive/abc123", nil)
+		rec := httptest.NewRecorder()
+		SetupRoutes(NewHandlers(svc), false).ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get(service.New(&stubRepo{}, &stubCache{}, "http://localhost:8080", time.Hour, 2*time.Second))
+	if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "archive_disabled") {
+		t.Fatalf("expected 404 archive_disabled, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	archive := &stubArchive{}
+	svc := service.New(&stubRepo{}, &stubCache{}, "http://localhost:8080", time.Hour, 2*time.Second, service.WithArchive(archive, 0))
+	if rec := get(svc); rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "not_found") {
+		t.Fatalf("expected 404 not_found, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	archivedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	archive.urls = []models.ArchivedURL{{
+		URLDetails: models.URLDetails{
+			URL:        models.URL{ID: 7, ShortCode: "abc123", OriginalURL: "https://example.com", Status: models.URLStatusActive},
+			ClickCount: 4,
+		},
+		ArchivedAt: archivedAt,
+	}}
+	rec = get(svc)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var resp archivedURLsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Code != "abc123" || len(resp.URLs) != 1 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	if got := resp.URLs[0]; got.ID != 7 || got.ClickCount != 4 || got.ArchivedAt != "2026-03-01T12:00:00Z" {
+		t.Fatalf("unexpected archived link: %+v", got)
+	}
+}
+
+func TestPurgeCacheRoutes(t *testing.T) {
+	handlers := NewHandlers(service.New(&stubRepo{}, &stubCache{}, "http://localhost:8080", time.Hour, 2*time.Second))
+	router := SetupRoutes(handlers, false)
+
+	for _, path := range []string{"/v1/cache", "/v1/cache/abc123"} {
+		req := httptest.NewRequest(http.MethodDelete, path, nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("%s: expected 204, got %d", path, rec.Code)
+		}
+	}
+}
+
 func TestHealthHandler_OK(t *testing.T) {
 	handlers := NewHandlers(service.New(&stubRepo{}, &stubCache{}, "http://localhost:8080", time.Hour, 2*time.Second))
 	req := httptest.NewRequest(http.MethodGet, "/v1/health", nil)
@@ -161,6 +798,71 @@ func TestHealthHandler_OK(t *testing.T) {
 	}
 }
 
+func TestReadinessHandler(t *testing.T) {
+	down := errors.New("connection refused")
+	tests := []struct {
+		name       string
+		checks     []health.Check
+		drain      bool
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "ready",
+			checks:     []health.Check{{Name: "database", Probe: func(context.Context) error { return nil }}},
+			wantStatus: http.StatusOK,
+			wantBody:   `"status":"ok"`,
+		},
+		{
+			name: "optional cache down",
+			checks: []health.Check{
+				{Name: "database", Probe: func(context.Context) error { return nil }},
+				{Name: "cache", Probe: func(context.Context) error { return down }, Optional: true},
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `"status":"degraded"`,
+		},
+		{
+			name:       "database down",
+			checks:     []health.Check{{Name: "database", Probe: func(context.Context) error { return down }}},
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   `"status":"unavailable"`,
+		},
+		{
+			name:       "draining",
+			checks:     []health.Check{{Name: "database", Probe: func(context.Context) error { return nil }}},
+			drain:      true,
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   `"status":"draining"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := health.NewChecker(time.Second, tt.checks...)
+			if tt.drain {
+				checker.Drain()
+			}
+			svc := service.New(&stubRepo{}, &stubCache{}, "http://localhost:8080", time.Hour, 2*time.Second)
+			router := SetupRoutes(NewHandlers(svc, WithHealthChecker(checker)), false)
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/health/ready", nil)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Fatalf("expected body to contain %s, got %s", tt.wantBody, rec.Body.String())
+			}
+			if strings.Contains(rec.Body.String(), down.Error()) {
+				t.Fatalf("expected dependency errors to stay out of the response, got %s", rec.Body.String())
+			}
+		})
+	}
+}
+
 func TestSetupRoutes_SwaggerEnabled(t *testing.T) {
 	repo := &stubRepo{}
 	cache := &stubCache{}
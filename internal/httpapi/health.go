@@ -0,0 +1,54 @@
+package httpapi
+
+import "net/http"
+
+type componentResponse struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Optional  bool    `json:"optional,omitempty"`
+}
+
+type readinessResponse struct {
+	Status     string              `json:"status"`
+	Components []componentResponse `json:"components"`
+}
+
+// HealthHandler is the liveness probe: it answers as long as the process can
+// serve HTTP and never checks dependencies. /v1/health is kept as an alias of
+// /v1/health/live.
+func (h *Handlers) HealthHandler(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// ReadinessHandler is the readiness probe: 200 while required dependencies
+// answer, 503 when one does not or the server is draining. The probe is
+// public, so failures are logged rather than returned, where they would
+// expose hostnames and driver errors.
+func (h *Handlers) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	report := h.health.Check(r.Context())
+
+	response := readinessResponse{
+		Status:     string(report.Status),
+		Components: make([]componentResponse, 0, len(report.Components)),
+	}
+	for _, result := range report.Components {
+		component := componentResponse{
+			Name:      result.Name,
+			Status:    string(result.Status),
+			LatencyMS: float64(result.Latency.Microseconds()) / 1000,
+			Optional:  result.Optional,
+		}
+		if result.Err != nil {
+			h.logger.WarnContext(r.Context(), "readiness check failed", "component", result.Name, "optional", result.Optional, "error", result.Err)
+		}
+		response.Components = append(response.Components, component)
+	}
+
+	status := http.StatusOK
+	if !report.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, status, response)
+}
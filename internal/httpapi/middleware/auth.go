@@ -4,12 +4,14 @@ import (
 	"crypto/subtle"
 	"net/http"
 	"strings"
+
+	"url-shortener-go/internal/service"
 )
 
 func AuthMiddleware(apiKey string, allowUnauthedDocs bool) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if r.URL.Path == "/v1/health" {
+			if healthPaths[r.URL.Path] {
 				next.ServeHTTP(w, r)
 				return
 			}
@@ -39,6 +41,13 @@ func AuthMiddleware(apiKey string, allowUnauthedDocs bool) func(http.Handler) ht
 	}
 }
 
+// healthPaths are the probes, which load balancers call without a key.
+var healthPaths = map[string]bool{
+	"/v1/health":       true,
+	"/v1/health/live":  true,
+	"/v1/health/ready": true,
+}
+
 func isPublicRedirectRequest(r *http.Request) bool {
 	if r.Method != http.MethodGet {
 		return false
@@ -52,9 +61,9 @@ func isPublicRedirectRequest(r *http.Request) bool {
 	// /v1/{code}
 	if strings.HasPrefix(trimmed, "v1/") {
 		code := strings.TrimPrefix(trimmed, "v1/")
-		return code != "" && !strings.Contains(code, "/")
+		return code != "" && !strings.Contains(code, "/") && !service.IsReservedCode(code)
 	}
 
 	// /{code}
-	return !strings.Contains(trimmed, "/") && trimmed != "v1" && trimmed != "swagger"
+	return !strings.Contains(trimmed, "/") && !service.IsReservedCode(trimmed)
 }
@@ -11,13 +11,15 @@ func TestAuthMiddleware_AllowsHealth(t *testing.T) {
 		w.WriteHeader(http.StatusOK)
 	}))
 
-	req := httptest.NewRequest(http.MethodGet, "/v1/health", nil)
-	rec := httptest.NewRecorder()
+	for _, path := range []string{"/v1/health", "/v1/health/live", "/v1/health/ready"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rec := httptest.NewRecorder()
 
-	handler.ServeHTTP(rec, req)
+		handler.ServeHTTP(rec, req)
 
-	if rec.Code != http.StatusOK {
-		t.Fatalf("expected 200, got %d", rec.Code)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", path, rec.Code)
+		}
 	}
 }
 
@@ -96,3 +98,35 @@ func TestAuthMiddleware_AllowsV1RedirectWithoutToken(t *testing.T) {
 		t.Fatalf("expected 200, got %d", rec.Code)
 	}
 }
+
+func TestAuthMiddleware_RejectsReservedV1PathsWithoutToken(t *testing.T) {
+	handler := AuthMiddleware("secret", false)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, path := range []string{"/v1/urls", "/v1/export"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("%s: expected 401, got %d", path, rec.Code)
+		}
+	}
+}
+
+func TestAuthMiddleware_RejectsMetricsWithoutToken(t *testing.T) {
+	handler := AuthMiddleware("secret", false)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rec.Code)
+	}
+}
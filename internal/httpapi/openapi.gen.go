@@ -11,9 +11,45 @@ import (
 type ServerInterface interface {
 	// (POST /v1/shorten)
 	PostV1Shorten(w http.ResponseWriter, r *http.Request)
+	// (POST /v1/shorten/batch)
+	PostV1ShortenBatch(w http.ResponseWriter, r *http.Request)
+	// (GET /v1/urls)
+	GetV1Urls(w http.ResponseWriter, r *http.Request)
+	// (GET /v1/urls/{code})
+	GetV1UrlsCode(w http.ResponseWriter, r *http.Request)
+	// (PATCH /v1/urls/{code})
+	PatchV1UrlsCode(w http.ResponseWriter, r *http.Request)
+	// (DELETE /v1/urls/{code})
+	DeleteV1UrlsCode(w http.ResponseWriter, r *http.Request)
+	// (POST /v1/urls/{code}/disable)
+	PostV1UrlsCodeDisable(w http.ResponseWriter, r *http.Request)
+	// (POST /v1/urls/{code}/restore)
+	PostV1UrlsCodeRestore(w http.ResponseWriter, r *http.Request)
+	// (GET /v1/urls/{code}/stats)
+	GetV1UrlsCodeStats(w http.ResponseWriter, r *http.Request)
+	// (GET /v1/export)
+	GetV1Export(w http.ResponseWriter, r *http.Request)
+	// (DELETE /v1/cache)
+	DeleteV1Cache(w http.ResponseWriter, r *http.Request)
+	// (DELETE /v1/cache/{code})
+	DeleteV1CacheCode(w http.ResponseWriter, r *http.Request)
+	// (GET /v1/archive/{code})
+	GetV1ArchiveCode(w http.ResponseWriter, r *http.Request)
 }
 
 // RegisterHandlers registers generated OpenAPI handlers.
 func RegisterHandlers(router *mux.Router, si ServerInterface) {
 	router.HandleFunc("/v1/shorten", si.PostV1Shorten).Methods(http.MethodPost)
+	router.HandleFunc("/v1/shorten/batch", si.PostV1ShortenBatch).Methods(http.MethodPost)
+	router.HandleFunc("/v1/urls", si.GetV1Urls).Methods(http.MethodGet)
+	router.HandleFunc("/v1/urls/{code}", si.GetV1UrlsCode).Methods(http.MethodGet)
+	router.HandleFunc("/v1/urls/{code}", si.PatchV1UrlsCode).Methods(http.MethodPatch)
+	router.HandleFunc("/v1/urls/{code}", si.DeleteV1UrlsCode).Methods(http.MethodDelete)
+	router.HandleFunc("/v1/urls/{code}/disable", si.PostV1UrlsCodeDisable).Methods(http.MethodPost)
+	router.HandleFunc("/v1/urls/{code}/restore", si.PostV1UrlsCodeRestore).Methods(http.MethodPost)
+	router.HandleFunc("/v1/urls/{code}/stats", si.GetV1UrlsCodeStats).Methods(http.MethodGet)
+	router.HandleFunc("/v1/export", si.GetV1Export).Methods(http.MethodGet)
+	router.HandleFunc("/v1/cache", si.DeleteV1Cache).Methods(http.MethodDelete)
+	router.HandleFunc("/v1/cache/{code}", si.DeleteV1CacheCode).Methods(http.MethodDelete)
+	router.HandleFunc("/v1/archive/{code}", si.GetV1ArchiveCode).Methods(http.MethodGet)
 }
@@ -6,3 +6,63 @@ import "net/http"
 func (h *Handlers) PostV1Shorten(w http.ResponseWriter, r *http.Request) {
 	h.CreateShortURLHandler(w, r)
 }
+
+// PostV1ShortenBatch satisfies the generated OpenAPI server interface.
+func (h *Handlers) PostV1ShortenBatch(w http.ResponseWriter, r *http.Request) {
+	h.CreateShortURLBatchHandler(w, r)
+}
+
+// GetV1UrlsCode satisfies the generated OpenAPI server interface.
+func (h *Handlers) GetV1UrlsCode(w http.ResponseWriter, r *http.Request) {
+	h.GetURLDetailsHandler(w, r)
+}
+
+// GetV1Urls satisfies the generated OpenAPI server interface.
+func (h *Handlers) GetV1Urls(w http.ResponseWriter, r *http.Request) {
+	h.ListURLsHandler(w, r)
+}
+
+// PatchV1UrlsCode satisfies the generated OpenAPI server interface.
+func (h *Handlers) PatchV1UrlsCode(w http.ResponseWriter, r *http.Request) {
+	h.UpdateURLHandler(w, r)
+}
+
+// DeleteV1UrlsCode satisfies the generated OpenAPI server interface.
+func (h *Handlers) DeleteV1UrlsCode(w http.ResponseWriter, r *http.Request) {
+	h.DeleteURLHandler(w, r)
+}
+
+// PostV1UrlsCodeDisable satisfies the generated OpenAPI server interface.
+func (h *Handlers) PostV1UrlsCodeDisable(w http.ResponseWriter, r *http.Request) {
+	h.DisableURLHandler(w, r)
+}
+
+// PostV1UrlsCodeRestore satisfies the generated OpenAPI server interface.
+func (h *Handlers) PostV1UrlsCodeRestore(w http.ResponseWriter, r *http.Request) {
+	h.RestoreURLHandler(w, r)
+}
+
+// GetV1UrlsCodeStats satisfies the generated OpenAPI server interface.
+func (h *Handlers) GetV1UrlsCodeStats(w http.ResponseWriter, r *http.Request) {
+	h.ClickStatsHandler(w, r)
+}
+
+// GetV1Export satisfies the generated OpenAPI server interface.
+func (h *Handlers) GetV1Export(w http.ResponseWriter, r *http.Request) {
+	h.ExportHandler(w, r)
+}
+
+// DeleteV1Cache satisfies the generated OpenAPI server interface.
+func (h *Handlers) DeleteV1Cache(w http.ResponseWriter, r *http.Request) {
+	h.PurgeCacheHandler(w, r)
+}
+
+// DeleteV1CacheCode satisfies the generated OpenAPI server interface.
+func (h *Handlers) DeleteV1CacheCode(w http.ResponseWriter, r *http.Request) {
+	h.PurgeCachedURLHandler(w, r)
+}
+
+// GetV1ArchiveCode satisfies the generated OpenAPI server interface.
+func (h *Handlers) GetV1ArchiveCode(w http.ResponseWriter, r *http.Request) {
+	h.ArchivedURLsHandler(w, r)
+}
@@ -2,7 +2,10 @@ package httpapi
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+
+	"url-shortener-go/internal/service"
 )
 
 type errorResponse struct {
@@ -22,3 +25,21 @@ func writeError(w http.ResponseWriter, status int, code string, message string)
 		Message: message,
 	})
 }
+
+// writeUnexpectedError answers errors a handler has no specific response for:
+// 503 while storage is unavailable or the service is read-only, 500 otherwise.
+func writeUnexpectedError(w http.ResponseWriter, err error) {
+	status, resp := unexpectedErrorResponse(err)
+	writeError(w, status, resp.Code, resp.Message)
+}
+
+func unexpectedErrorResponse(err error) (int, *errorResponse) {
+	switch {
+	case errors.Is(err, service.ErrReadOnly):
+		return http.StatusServiceUnavailable, &errorResponse{Code: "read_only", Message: "the service is read-only"}
+	case errors.Is(err, service.ErrUnavailable):
+		return http.StatusServiceUnavailable, &errorResponse{Code: "unavailable", Message: "storage is temporarily unavailable"}
+	default:
+		return http.StatusInternalServerError, &errorResponse{Code: "internal_error", Message: "unexpected error"}
+	}
+}
@@ -10,6 +10,8 @@ func setupAPIRoutes(handlers *Handlers) *mux.Router {
 	router := mux.NewRouter()
 
 	router.HandleFunc("/v1/health", handlers.HealthHandler).Methods(http.MethodGet)
+	router.HandleFunc("/v1/health/live", handlers.HealthHandler).Methods(http.MethodGet)
+	router.HandleFunc("/v1/health/ready", handlers.ReadinessHandler).Methods(http.MethodGet)
 	RegisterHandlers(router, handlers)
 	router.HandleFunc("/v1/{code}", handlers.GetFullURLHandler).Methods(http.MethodGet)
 
@@ -23,6 +25,9 @@ func SetupRoutes(handlers *Handlers, enableSwagger bool) *mux.Router {
 		router.HandleFunc("/swagger/", handlers.SwaggerUIHandler).Methods(http.MethodGet)
 		router.HandleFunc("/swagger/openapi.yaml", handlers.SwaggerSpecHandler).Methods(http.MethodGet)
 	}
+	if handlers.metrics != nil {
+		router.Handle("/metrics", handlers.metrics).Methods(http.MethodGet)
+	}
 	// Public short links are generated as /{code}.
 	router.HandleFunc("/{code}", handlers.GetFullURLHandler).Methods(http.MethodGet)
 
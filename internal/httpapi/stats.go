@@ -0,0 +1,87 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"url-shortener-go/internal/models"
+	"url-shortener-go/internal/service"
+
+	"github.com/gorilla/mux"
+)
+
+type clickStatsResponse struct {
+	Code     string                `json:"short_code"`
+	Interval string                `json:"interval"`
+	From     string                `json:"from"`
+	To       string                `json:"to"`
+	Total    int64                 `json:"total"`
+	Buckets  []clickBucketResponse `json:"buckets"`
+}
+
+type clickBucketResponse struct {
+	Start  string `json:"start"`
+	Clicks int64  `json:"clicks"`
+}
+
+func (h *Handlers) ClickStatsHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := mux.Vars(r)["code"]
+	if shortCode == "" {
+		writeError(w, http.StatusBadRequest, "missing_code", "missing short code")
+		return
+	}
+
+	query := r.URL.Query()
+	opts := models.ClickStatsOptions{Interval: query.Get("interval")}
+	for _, param := range []struct {
+		name   string
+		target **time.Time
+	}{
+		{"from", &opts.From},
+		{"to", &opts.To},
+	} {
+		raw := query.Get(param.name)
+		if raw == "" {
+			continue
+		}
+		value, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_"+param.name, param.name+" must be an RFC 3339 timestamp")
+			return
+		}
+		*param.target = &value
+	}
+
+	stats, err := h.service.GetClickStats(r.Context(), shortCode, opts)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidFilter):
+			writeError(w, http.StatusBadRequest, "invalid_interval", "interval must be hour or day")
+		case errors.Is(err, service.ErrInvalidStatsRange):
+			writeError(w, http.StatusBadRequest, "invalid_range", "from must be before to and the range at most 31 days for hour or 366 days for day")
+		case errors.Is(err, service.ErrNotFound):
+			writeError(w, http.StatusNotFound, "not_found", "URL not found")
+		default:
+			writeUnexpectedError(w, err)
+		}
+		return
+	}
+
+	response := clickStatsResponse{
+		Code:     shortCode,
+		Interval: stats.Interval,
+		From:     stats.From.Format(time.RFC3339),
+		To:       stats.To.Format(time.RFC3339),
+		Total:    stats.Total,
+		Buckets:  make([]clickBucketResponse, 0, len(stats.Buckets)),
+	}
+	for _, bucket := range stats.Buckets {
+		response.Buckets = append(response.Buckets, clickBucketResponse{
+			Start:  bucket.Start.Format(time.RFC3339),
+			Clicks: bucket.Clicks,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
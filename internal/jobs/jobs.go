@@ -0,0 +1,181 @@
+// Package jobs runs periodic background jobs. With a Locker, each job runs on
+// one replica at a time: the replica holding the job's lock is its leader
+// until it stops or loses the lock.
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Job is one periodic task.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	// Run does one pass and returns how many rows it processed.
+	Run func(ctx context.Context) (int64, error)
+}
+
+// Lock is a held lock.
+type Lock interface {
+	// Check fails once the lock may have been lost.
+	Check(ctx context.Context) error
+	Release(ctx context.Context) error
+}
+
+// Locker takes locks shared by every replica.
+type Locker interface {
+	// TryLock returns a nil Lock and no error if another replica holds it.
+	TryLock(ctx context.Context, name string) (Lock, error)
+}
+
+// LockerFunc adapts a function to Locker.
+type LockerFunc func(ctx context.Context, name string) (Lock, error)
+
+func (f LockerFunc) TryLock(ctx context.Context, name string) (Lock, error) {
+	return f(ctx, name)
+}
+
+// Metrics receives job outcomes.
+type Metrics interface {
+	JobFinished(job string, duration time.Duration, err error)
+	JobRowsProcessed(job string, rows int64)
+}
+
+// lockTimeout bounds taking, checking and releasing a lock.
+const lockTimeout = 5 * time.Second
+
+// lockPrefix namespaces job locks from other advisory locks in the database.
+const lockPrefix = "url-shortener:job:"
+
+type Scheduler struct {
+	locker  Locker
+	logger  *slog.Logger
+	metrics Metrics
+	jobs    []Job
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewScheduler returns a scheduler that runs jobs only while holding their
+// lock from locker. A nil locker runs every job on this instance, which is
+// right for a single replica.
+func NewScheduler(locker Locker, logger *slog.Logger, metrics Metrics) *Scheduler {
+	return &Scheduler{locker: locker, logger: logger, metrics: metrics}
+}
+
+// Add registers a job. It must be called before Start.
+func (s *Scheduler) Add(job Job) {
+	s.jobs = append(s.jobs, job)
+}
+
+// Start runs each job once per interval, starting one interval from now.
+func (s *Scheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	for _, job := range s.jobs {
+		s.wg.Go(func() { s.loop(ctx, job) })
+	}
+}
+
+// Stop cancels running jobs, releases their locks and waits for them to
+// return or for ctx to end.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	if s.cancel == nil {
+		return nil
+	}
+	s.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	var lock Lock
+	defer func() {
+		if lock != nil {
+			s.release(job, lock)
+		}
+	}()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		var leader bool
+		lock, leader = s.lead(ctx, job, lock)
+		if leader {
+			s.run(ctx, job)
+		}
+	}
+}
+
+// lead keeps or takes the job's lock and reports whether this instance
+// should run the job.
+func (s *Scheduler) lead(ctx context.Context, job Job, lock Lock) (Lock, bool) {
+	if s.locker == nil {
+		return nil, true
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, lockTimeout)
+	defer cancel()
+
+	if lock != nil {
+		if err := lock.Check(ctx); err == nil {
+			return lock, true
+		}
+		s.logger.Warn("lost job lock", "job", job.Name)
+		s.release(job, lock)
+	}
+
+	lock, err := s.locker.TryLock(ctx, lockPrefix+job.Name)
+	if err != nil {
+		s.logger.Error("failed to take job lock", "job", job.Name, "error", err)
+		return nil, false
+	}
+	if lock == nil {
+		return nil, false
+	}
+	s.logger.Info("took job lock; this instance runs the job", "job", job.Name)
+	return lock, true
+}
+
+func (s *Scheduler) run(ctx context.Context, job Job) {
+	start := time.Now()
+	rows, err := job.Run(ctx)
+	duration := time.Since(start)
+
+	s.metrics.JobFinished(job.Name, duration, err)
+	s.metrics.JobRowsProcessed(job.Name, rows)
+	if err != nil {
+		s.logger.Error("job failed", "job", job.Name, "rows", rows, "duration", duration, "error", err)
+		return
+	}
+	s.logger.Info("job finished", "job", job.Name, "rows", rows, "duration", duration)
+}
+
+func (s *Scheduler) release(job Job, lock Lock) {
+	ctx, cancel := context.WithTimeout(context.Background(), lockTimeout)
+	defer cancel()
+	if err := lock.Release(ctx); err != nil {
+		s.logger.Warn("failed to release job lock", "job", job.Name, "error", err)
+	}
+}
@@ -0,0 +1,162 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type nopMetrics struct{}
+
+func (nopMetrics) JobFinished(string, time.Duration, error) {}
+func (nopMetrics) JobRowsProcessed(string, int64)           {}
+
+type fakeLock struct {
+	checkErr atomic.Value
+	released atomic.Bool
+}
+
+func (l *fakeLock) Check(context.Context) error {
+	if err, ok := l.checkErr.Load().(error); ok {
+		return err
+	}
+	return nil
+}
+
+func (l *fakeLock) Release(context.Context) error {
+	l.released.Store(true)
+	return nil
+}
+
+// fakeLocker hands out one lock per name at a time, like an advisory lock
+// shared by every replica.
+type fakeLocker struct {
+	mu    sync.Mutex
+	held  map[string]*fakeLock
+	taken int
+}
+
+func (f *fakeLocker) TryLock(_ context.Context, name string) (Lock, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if lock := f.held[name]; lock != nil && !lock.released.Load() {
+		return nil, nil
+	}
+	lock := &fakeLock{}
+	if f.held == nil {
+		f.held = make(map[string]*fakeLock)
+	}
+	f.held[name] = lock
+	f.taken++
+	return lock, nil
+}
+
+func (f *fakeLocker) current(name string) *fakeLock {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.held[lockPrefix+name]
+}
+
+func newTestScheduler(locker Locker) *Scheduler {
+	return NewScheduler(locker, slog.New(slog.NewTextHandler(io.Discard, nil)), nopMetrics{})
+}
+
+func countingJob(name string, runs *atomic.Int64) Job {
+	return Job{
+		Name:     name,
+		Interval: 5 * time.Millisecond,
+		Run: func(context.Context) (int64, error) {
+			runs.Add(1)
+			return 1, nil
+		},
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestScheduler_RunsWithoutLocker(t *testing.T) {
+	var runs atomic.Int64
+	s := newTestScheduler(nil)
+	s.Add(countingJob("cleanup", &runs))
+	s.Start()
+	defer s.Stop(context.Background())
+
+	waitFor(t, func() bool { return runs.Load() >= 2 })
+}
+
+func TestScheduler_OnlyLeaderRuns(t *testing.T) {
+	locker := &fakeLocker{}
+	var leaderRuns, followerRuns atomic.Int64
+
+	leader := newTestScheduler(locker)
+	leader.Add(countingJob("cleanup", &leaderRuns))
+	leader.Start()
+	waitFor(t, func() bool { return leaderRuns.Load() >= 1 })
+
+	follower := newTestScheduler(locker)
+	follower.Add(countingJob("cleanup", &followerRuns))
+	follower.Start()
+	defer follower.Stop(context.Background())
+
+	time.Sleep(30 * time.Millisecond)
+	if followerRuns.Load() != 0 {
+		t.Fatalf("expected the follower not to run while the leader holds the lock, got %d runs", followerRuns.Load())
+	}
+
+	if err := leader.Stop(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitFor(t, func() bool { return followerRuns.Load() >= 1 })
+}
+
+func TestScheduler_RetakesLostLock(t *testing.T) {
+	locker := &fakeLocker{}
+	var runs atomic.Int64
+
+	s := newTestScheduler(locker)
+	s.Add(countingJob("cleanup", &runs))
+	s.Start()
+	defer s.Stop(context.Background())
+
+	waitFor(t, func() bool { return runs.Load() >= 1 })
+	first := locker.current("cleanup")
+	first.checkErr.Store(errors.New("connection lost"))
+
+	waitFor(t, func() bool { return locker.current("cleanup") != first })
+	if !first.released.Load() {
+		t.Fatal("expected the lost lock to be released")
+	}
+	before := runs.Load()
+	waitFor(t, func() bool { return runs.Load() > before })
+}
+
+func TestScheduler_StopReleasesLock(t *testing.T) {
+	locker := &fakeLocker{}
+	var runs atomic.Int64
+
+	s := newTestScheduler(locker)
+	s.Add(countingJob("cleanup", &runs))
+	s.Start()
+	waitFor(t, func() bool { return runs.Load() >= 1 })
+
+	if err := s.Stop(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !locker.current("cleanup").released.Load() {
+		t.Fatal("expected Stop to release the job lock")
+	}
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"url-shortener-go/internal/models"
+	"url-shortener-go/internal/service"
+)
+
+// Cache tiers used as the "tier" label.
+const (
+	TierLocal  = "local"
+	TierRedis  = "redis"
+	TierMemory = "memory"
+)
+
+// instrumentedCache counts the lookups made against one cache tier.
+type instrumentedCache struct {
+	service.Cache
+	tier    string
+	metrics *Metrics
+}
+
+// InstrumentCache wraps cache so its lookups are counted under tier.
+func (m *Metrics) InstrumentCache(tier string, cache service.Cache) service.Cache {
+	return &instrumentedCache{Cache: cache, tier: tier, metrics: m}
+}
+
+func (c *instrumentedCache) Get(ctx context.Context, key string) (*models.URL, error) {
+	url, err := c.Cache.Get(ctx, key)
+	c.record(url, err)
+	return url, err
+}
+
+func (c *instrumentedCache) GetWithTTL(ctx context.Context, key string) (*models.URL, time.Duration, error) {
+	ttlCache, ok := c.Cache.(service.TTLCache)
+	if !ok {
+		url, err := c.Get(ctx, key)
+		return url, 0, err
+	}
+	url, ttl, err := ttlCache.GetWithTTL(ctx, key)
+	c.record(url, err)
+	return url, ttl, err
+}
+
+// record counts a lookup. A negative entry for an unknown code is a miss:
+// the code was not found, only the repository lookup was saved.
+func (c *instrumentedCache) record(url *models.URL, err error) {
+	switch {
+	case err == nil:
+		c.metrics.CacheLookup(c.tier, !service.IsMissing(url))
+	case errors.Is(err, service.ErrNotFound):
+		c.metrics.CacheLookup(c.tier, false)
+	default:
+		c.metrics.cacheError(c.tier)
+	}
+}
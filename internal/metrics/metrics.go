@@ -0,0 +1,142 @@
+// Package metrics exposes Prometheus metrics for the HTTP API, the cache
+// tiers, link creation, connection pools and background jobs.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "url_shortener"
+
+// Metrics owns a registry with every series the server exports. It
+// implements service.Metrics.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	cacheLookups    *prometheus.CounterVec
+	linksCreated    *prometheus.CounterVec
+	codeConflicts   prometheus.Counter
+	jobDuration     *prometheus.HistogramVec
+	jobRows         *prometheus.CounterVec
+	clicksDropped   prometheus.Counter
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by route template, method and status code.",
+		}, []string{"route", "method", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route template and method.",
+			Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+		}, []string{"route", "method"}),
+		cacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_lookups_total",
+			Help:      "Link cache lookups by tier and result (hit, miss or error).",
+		}, []string{"tier", "result"}),
+		linksCreated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "links_created_total",
+			Help:      "Link creation requests by outcome.",
+		}, []string{"outcome"}),
+		codeConflicts: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "code_conflicts_total",
+			Help:      "Generated short codes that were already taken and regenerated.",
+		}),
+		jobDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "job_duration_seconds",
+			Help:      "Background job durations by job and result.",
+			Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+		}, []string{"job", "result"}),
+		jobRows: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "job_rows_total",
+			Help:      "Rows processed by background jobs, such as expired links deleted.",
+		}, []string{"job"}),
+		clicksDropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "click_events_dropped_total",
+			Help:      "Click events not stored, because the database rejected them or the buffer was full. Their counts are kept.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.cacheLookups,
+		m.linksCreated,
+		m.codeConflicts,
+		m.jobDuration,
+		m.jobRows,
+		m.clicksDropped,
+	)
+	return m
+}
+
+// Handler serves the registry in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// MustRegister adds extra collectors, such as connection pool stats, and
+// panics if one is already registered.
+func (m *Metrics) MustRegister(extra ...prometheus.Collector) {
+	m.registry.MustRegister(extra...)
+}
+
+func (m *Metrics) LinkCreated(outcome string) {
+	m.linksCreated.WithLabelValues(outcome).Inc()
+}
+
+func (m *Metrics) CodeConflict() {
+	m.codeConflicts.Inc()
+}
+
+func (m *Metrics) JobFinished(job string, duration time.Duration, err error) {
+	m.jobDuration.WithLabelValues(job, result(err)).Observe(duration.Seconds())
+}
+
+func (m *Metrics) ClickEventsDropped(n int) {
+	m.clicksDropped.Add(float64(n))
+}
+
+func (m *Metrics) JobRowsProcessed(job string, rows int64) {
+	m.jobRows.WithLabelValues(job).Add(float64(rows))
+}
+
+// CacheLookup records one lookup in the given cache tier.
+func (m *Metrics) CacheLookup(tier string, hit bool) {
+	outcome := "miss"
+	if hit {
+		outcome = "hit"
+	}
+	m.cacheLookups.WithLabelValues(tier, outcome).Inc()
+}
+
+func (m *Metrics) cacheError(tier string) {
+	m.cacheLookups.WithLabelValues(tier, "error").Inc()
+}
+
+func result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	memorycache "url-shortener-go/internal/cache/memory"
+	"url-shortener-go/internal/models"
+	"url-shortener-go/internal/service"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddleware_LabelsByRouteTemplate(t *testing.T) {
+	m := New()
+	router := mux.NewRouter()
+	router.HandleFunc("/v1/urls/{code}", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}).Methods(http.MethodGet)
+	router.Use(m.Middleware)
+
+	for _, path := range []string{"/v1/urls/abc123", "/v1/urls/xyz789"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if got := testutil.ToFloat64(m.requests.WithLabelValues("/v1/urls/{code}", http.MethodGet, "404")); got != 2 {
+		t.Fatalf("expected 2 requests under the route template, got %v", got)
+	}
+	if got := testutil.CollectAndCount(m.requestDuration); got != 1 {
+		t.Fatalf("expected one latency series, got %d", got)
+	}
+}
+
+func TestInstrumentCache_CountsHitsAndMisses(t *testing.T) {
+	m := New()
+	cache := m.InstrumentCache(TierRedis, memorycache.NewCacheRepository(0))
+	ctx := context.Background()
+
+	cache.Set(ctx, "url:abc123", &models.URL{ID: 1, ShortCode: "abc123"}, time.Minute)
+	cache.Get(ctx, "url:abc123")
+	cache.(service.TTLCache).GetWithTTL(ctx, "url:abc123")
+	cache.Get(ctx, "url:missing")
+	cache.Set(ctx, "url:unknown", &models.URL{ShortCode: "unknown"}, time.Minute)
+	cache.Get(ctx, "url:unknown")
+
+	if got := testutil.ToFloat64(m.cacheLookups.WithLabelValues(TierRedis, "hit")); got != 2 {
+		t.Fatalf("expected 2 hits, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.cacheLookups.WithLabelValues(TierRedis, "miss")); got != 2 {
+		t.Fatalf("expected 2 misses, counting the negative entry, got %v", got)
+	}
+}
+
+func TestHandler_ExportsSeries(t *testing.T) {
+	m := New()
+	m.LinkCreated(service.OutcomeCreated)
+	m.CodeConflict()
+	m.JobFinished(service.JobClickFlush, time.Millisecond, errors.New("down"))
+	m.MustRegister(NewDBStatsCollector(func() sql.DBStats { return sql.DBStats{OpenConnections: 3} }))
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	body := rec.Body.String()
+	for _, want := range []string{
+		`url_shortener_links_created_total{outcome="created"} 1`,
+		`url_shortener_code_conflicts_total 1`,
+		`url_shortener_job_duration_seconds_count{job="click_flush",result="error"} 1`,
+		`url_shortener_db_pool_open_connections 3`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected %q in:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// ClickInfo describes the request behind a redirect.
+type ClickInfo struct {
+	Referrer  string
+	UserAgent string
+	ClientIP  string
+	RequestID string
+}
+
+// Click is one recorded redirect. The client IP is only kept as a salted hash.
+type Click struct {
+	URLID     int
+	ClickedAt time.Time
+	Referrer  string
+	UserAgent string
+	IPHash    string
+	RequestID string
+}
+
+// ClickCount is the number of clicks a link received in one hour since the
+// last flush. Hour is the UTC start of that hour.
+type ClickCount struct {
+	URLID         int
+	Hour          time.Time
+	Count         int64
+	LastClickedAt time.Time
+}
+
+const (
+	StatsIntervalHour = "hour"
+	StatsIntervalDay  = "day"
+)
+
+// ClickStatsOptions selects the time range and bucket size of click stats.
+// Nil bounds fall back to defaults that depend on the interval.
+type ClickStatsOptions struct {
+	From     *time.Time
+	To       *time.Time
+	Interval string
+}
+
+// ClickBucket is the number of clicks in the interval starting at Start.
+type ClickBucket struct {
+	Start  time.Time
+	Clicks int64
+}
+
+// ClickStats is a zero-filled click time series for one link over [From, To).
+type ClickStats struct {
+	Interval string
+	From     time.Time
+	To       time.Time
+	Total    int64
+	Buckets  []ClickBucket
+}
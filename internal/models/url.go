@@ -2,12 +2,41 @@ package models
 
 import "time"
 
+// URLStatus is the lifecycle state of a link. Only active links redirect.
+type URLStatus string
+
+const (
+	URLStatusActive   URLStatus = "active"
+	URLStatusDisabled URLStatus = "disabled"
+	URLStatusDeleted  URLStatus = "deleted"
+)
+
 type URL struct {
 	ID          int        `json:"id"`
 	ShortCode   string     `json:"short_code"`
 	OriginalURL string     `json:"original_url"`
+	Status      URLStatus  `json:"status,omitempty"`
 	CreatedAt   time.Time  `json:"created_at"`
 	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+
+	// ResolvedFrom is the submitted destination when it was one of our own
+	// short links and was replaced by that link's target. It is only set on
+	// links returned from creation and is never stored.
+	ResolvedFrom string `json:"-"`
+}
+
+// URLDetails is a URL together with its aggregated click statistics.
+type URLDetails struct {
+	URL
+	ClickCount    int64      `json:"click_count"`
+	LastClickedAt *time.Time `json:"last_clicked_at,omitempty"`
+}
+
+// ArchivedURL is an expired link, with its click totals, as kept after
+// cleanup removed it from the urls table.
+type ArchivedURL struct {
+	URLDetails
+	ArchivedAt time.Time `json:"archived_at"`
 }
 
 type CreateURLOptions struct {
@@ -15,3 +44,43 @@ type CreateURLOptions struct {
 	CustomCode  string        `json:"custom_code,omitempty"`
 	ExpiresIn   time.Duration `json:"expires_in,omitempty"`
 }
+
+// UpdateURLOptions describes an in-place edit of a link. Nil fields are left unchanged.
+type UpdateURLOptions struct {
+	OriginalURL    *string
+	ExpiresAt      *time.Time
+	ClearExpiresAt bool
+}
+
+const (
+	ListStatusActive   = "active"
+	ListStatusExpired  = "expired"
+	ListStatusDisabled = "disabled"
+	ListStatusDeleted  = "deleted"
+)
+
+// ListURLsOptions filters a page of links. Pages are ordered by id, newest first.
+// Deleted links are only returned when Status is ListStatusDeleted.
+type ListURLsOptions struct {
+	Limit         int
+	Cursor        string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Status        string
+	Query         string
+}
+
+// ListURLsFilter is the storage-level form of ListURLsOptions with the cursor decoded.
+type ListURLsFilter struct {
+	Limit         int
+	AfterID       int
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Status        string
+	Query         string
+}
+
+type URLPage struct {
+	URLs       []URLDetails
+	NextCursor string
+}
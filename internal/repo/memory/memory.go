@@ -0,0 +1,449 @@
+// Package memory implements service.Repository in process memory. It is meant
+// for local development, demos and tests: nothing survives a restart.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"url-shortener-go/internal/models"
+	"url-shortener-go/internal/service"
+)
+
+type entry struct {
+	url           models.URL
+	clickCount    int64
+	lastClickedAt *time.Time
+}
+
+type hourKey struct {
+	urlID int
+	hour  int64
+}
+
+// Repository is a thread-safe in-memory store with the same conflict, status
+// and expiry semantics as the PostgreSQL repository. Click events are only
+// counted; their details are not kept.
+type Repository struct {
+	mu     sync.RWMutex
+	nextID int
+	byID   map[int]*entry
+	byCode map[string]*entry
+	hourly map[hourKey]int64
+
+	archiveMu sync.RWMutex
+	archived  map[int]models.ArchivedURL
+}
+
+func NewRepository() *Repository {
+	return &Repository{
+		byID:   make(map[int]*entry),
+		byCode: make(map[string]*entry),
+		hourly: make(map[hourKey]int64),
+
+		archived: make(map[int]models.ArchivedURL),
+	}
+}
+
+func (r *Repository) Create(_ context.Context, url *models.URL) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.insertLocked(url)
+}
+
+func (r *Repository) insertLocked(url *models.URL) error {
+	if _, ok := r.byCode[url.ShortCode]; ok {
+		return service.ErrConflict
+	}
+
+	r.nextID++
+	url.ID = r.nextID
+	url.CreatedAt = time.Now()
+
+	stored := &entry{url: *url}
+	stored.url.ExpiresAt = copyTime(url.ExpiresAt)
+	if stored.url.Status == "" {
+		stored.url.Status = models.URLStatusActive
+	}
+	r.byID[url.ID] = stored
+	r.byCode[url.ShortCode] = stored
+
+	return nil
+}
+
+// CreateBatch inserts urls one by one under a single lock. Short code
+// conflicts are reported per item.
+func (r *Repository) CreateBatch(_ context.Context, urls []*models.URL) ([]error, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	errs := make([]error, len(urls))
+	for i, url := range urls {
+		errs[i] = r.insertLocked(url)
+	}
+
+	return errs, nil
+}
+
+func (r *Repository) GetByShortCode(_ context.Context, shortCode string) (*models.URL, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored, ok := r.byCode[shortCode]
+	if !ok {
+		return nil, service.ErrNotFound
+	}
+
+	switch {
+	case stored.url.Status == models.URLStatusDeleted:
+		return nil, service.ErrDeleted
+	case stored.url.Status == models.URLStatusDisabled:
+		return nil, service.ErrDisabled
+	case isExpired(&stored.url, time.Now()):
+		return nil, service.ErrExpired
+	}
+
+	return stored.copyURL(), nil
+}
+
+func (r *Repository) GetByOriginalURL(_ context.Context, originalURL string) (*models.URL, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if stored := r.latestActiveLocked(originalURL, time.Now()); stored != nil {
+		return stored.copyURL(), nil
+	}
+	return nil, service.ErrNotFound
+}
+
+// GetByOriginalURLs is the bulk form of GetByOriginalURL. URLs without an
+// active link are absent from the result.
+func (r *Repository) GetByOriginalURLs(_ context.Context, originalURLs []string) (map[string]*models.URL, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	urls := make(map[string]*models.URL)
+	for _, originalURL := range originalURLs {
+		if stored := r.latestActiveLocked(originalURL, now); stored != nil {
+			urls[originalURL] = stored.copyURL()
+		}
+	}
+
+	return urls, nil
+}
+
+// latestActiveLocked mirrors the ORDER BY created_at DESC, id DESC lookup of
+// the SQL repositories.
+func (r *Repository) latestActiveLocked(originalURL string, now time.Time) *entry {
+	var latest *entry
+	for _, stored := range r.byID {
+		if stored.url.OriginalURL != originalURL || stored.url.Status != models.URLStatusActive || isExpired(&stored.url, now) {
+			continue
+		}
+		if latest == nil || stored.url.CreatedAt.After(latest.url.CreatedAt) ||
+			(stored.url.CreatedAt.Equal(latest.url.CreatedAt) && stored.url.ID > latest.url.ID) {
+			latest = stored
+		}
+	}
+	return latest
+}
+
+func (r *Repository) GetDetailsByShortCode(_ context.Context, shortCode string) (*models.URLDetails, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored, ok := r.byCode[shortCode]
+	if !ok {
+		return nil, service.ErrNotFound
+	}
+	return stored.details(), nil
+}
+
+func (r *Repository) List(_ context.Context, filter models.ListURLsFilter) ([]models.URLDetails, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	query := strings.ToLower(filter.Query)
+	urls := make([]models.URLDetails, 0, filter.Limit)
+	for _, id := range r.sortedIDsLocked(true) {
+		if len(urls) == filter.Limit {
+			break
+		}
+
+		url := &r.byID[id].url
+		if filter.AfterID > 0 && url.ID >= filter.AfterID {
+			continue
+		}
+		if filter.CreatedAfter != nil && url.CreatedAt.Before(*filter.CreatedAfter) {
+			continue
+		}
+		if filter.CreatedBefore != nil && !url.CreatedAt.Before(*filter.CreatedBefore) {
+			continue
+		}
+		if query != "" && !strings.Contains(strings.ToLower(url.OriginalURL), query) {
+			continue
+		}
+		if !matchesStatus(url, filter.Status, now) {
+			continue
+		}
+
+		urls = append(urls, *r.byID[id].details())
+	}
+
+	return urls, nil
+}
+
+func matchesStatus(url *models.URL, status string, now time.Time) bool {
+	switch status {
+	case models.ListStatusActive:
+		return url.Status == models.URLStatusActive && !isExpired(url, now)
+	case models.ListStatusExpired:
+		return url.Status != models.URLStatusDeleted && isExpired(url, now)
+	case models.ListStatusDisabled:
+		return url.Status == models.URLStatusDisabled
+	case models.ListStatusDeleted:
+		return url.Status == models.URLStatusDeleted
+	default:
+		return url.Status != models.URLStatusDeleted
+	}
+}
+
+func (r *Repository) Update(_ context.Context, shortCode string, opts models.UpdateURLOptions) (*models.URLDetails, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.byCode[shortCode]
+	if !ok || stored.url.Status == models.URLStatusDeleted {
+		return nil, service.ErrNotFound
+	}
+
+	if opts.OriginalURL != nil {
+		stored.url.OriginalURL = *opts.OriginalURL
+	}
+	switch {
+	case opts.ClearExpiresAt:
+		stored.url.ExpiresAt = nil
+	case opts.ExpiresAt != nil:
+		stored.url.ExpiresAt = copyTime(opts.ExpiresAt)
+	}
+
+	return stored.details(), nil
+}
+
+func (r *Repository) SetStatus(_ context.Context, shortCode string, status models.URLStatus) (*models.URLDetails, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.byCode[shortCode]
+	if !ok {
+		return nil, service.ErrNotFound
+	}
+	stored.url.Status = status
+
+	return stored.details(), nil
+}
+
+// ExportURLs calls fn for every link, including deleted ones, ordered by id.
+// It works on a snapshot so fn may call back into the repository.
+func (r *Repository) ExportURLs(ctx context.Context, fn func(*models.URLDetails) error) error {
+	r.mu.RLock()
+	snapshot := make([]*models.URLDetails, 0, len(r.byID))
+	for _, id := range r.sortedIDsLocked(false) {
+		snapshot = append(snapshot, r.byID[id].details())
+	}
+	r.mu.RUnlock()
+
+	for _, details := range snapshot {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(details); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Repository) RecordClicks(_ context.Context, counts []models.ClickCount, _ []models.Click) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, count := range counts {
+		stored, ok := r.byID[count.URLID]
+		if !ok {
+			continue
+		}
+		stored.clickCount += count.Count
+		if stored.lastClickedAt == nil || count.LastClickedAt.After(*stored.lastClickedAt) {
+			stored.lastClickedAt = copyTime(&count.LastClickedAt)
+		}
+		r.hourly[hourKey{urlID: count.URLID, hour: count.Hour.Unix()}] += count.Count
+	}
+
+	return nil
+}
+
+// GetHourlyClicks returns the non-empty hourly click buckets of a link in
+// [from, to), oldest first.
+func (r *Repository) GetHourlyClicks(_ context.Context, urlID int, from, to time.Time) ([]models.ClickBucket, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var buckets []models.ClickBucket
+	for key, clicks := range r.hourly {
+		start := time.Unix(key.hour, 0).UTC()
+		if key.urlID != urlID || start.Before(from) || !start.Before(to) {
+			continue
+		}
+		buckets = append(buckets, models.ClickBucket{Start: start, Clicks: clicks})
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].Start.Before(buckets[j].Start)
+	})
+
+	return buckets, nil
+}
+
+// DeleteExpiredURLs removes expired links. With archive set, the links are
+// passed to it under the write lock and only deleted if it succeeds.
+func (r *Repository) DeleteExpiredURLs(ctx context.Context, limit int, archive service.ArchiveFunc) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	var expired []*entry
+	for _, id := range r.sortedIDsLocked(false) {
+		if len(expired) >= limit {
+			break
+		}
+		stored := r.byID[id]
+		if stored.url.ExpiresAt == nil || !stored.url.ExpiresAt.Before(now) {
+			continue
+		}
+		expired = append(expired, stored)
+	}
+
+	if archive != nil && len(expired) > 0 {
+		urls := make([]models.URLDetails, 0, len(expired))
+		for _, stored := range expired {
+			urls = append(urls, *stored.details())
+		}
+		if err := archive(ctx, urls); err != nil {
+			return 0, fmt.Errorf("failed to archive expired links: %w", err)
+		}
+	}
+
+	for _, stored := range expired {
+		id := stored.url.ID
+		delete(r.byID, id)
+		delete(r.byCode, stored.url.ShortCode)
+		for key := range r.hourly {
+			if key.urlID == id {
+				delete(r.hourly, key)
+			}
+		}
+	}
+
+	return int64(len(expired)), nil
+}
+
+// ArchiveURLs keeps copies of urls. Archiving a link again overwrites its
+// earlier copy.
+func (r *Repository) ArchiveURLs(_ context.Context, urls []models.URLDetails) error {
+	r.archiveMu.Lock()
+	defer r.archiveMu.Unlock()
+
+	archivedAt := time.Now()
+	for _, url := range urls {
+		archived := models.ArchivedURL{URLDetails: url, ArchivedAt: archivedAt}
+		archived.ExpiresAt = copyTime(url.ExpiresAt)
+		archived.LastClickedAt = copyTime(url.LastClickedAt)
+		r.archived[url.ID] = archived
+	}
+	return nil
+}
+
+func (r *Repository) GetArchivedURLs(_ context.Context, shortCode string) ([]models.ArchivedURL, error) {
+	r.archiveMu.RLock()
+	defer r.archiveMu.RUnlock()
+
+	var urls []models.ArchivedURL
+	for _, archived := range r.archived {
+		if archived.ShortCode == shortCode {
+			urls = append(urls, archived)
+		}
+	}
+	sort.Slice(urls, func(i, j int) bool {
+		if !urls[i].ArchivedAt.Equal(urls[j].ArchivedAt) {
+			return urls[i].ArchivedAt.After(urls[j].ArchivedAt)
+		}
+		return urls[i].ID > urls[j].ID
+	})
+
+	return urls, nil
+}
+
+func (r *Repository) PurgeArchivedURLs(_ context.Context, cutoff time.Time) (int64, error) {
+	r.archiveMu.Lock()
+	defer r.archiveMu.Unlock()
+
+	var purged int64
+	for id, archived := range r.archived {
+		if archived.ArchivedAt.Before(cutoff) {
+			delete(r.archived, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (r *Repository) Close() error {
+	return nil
+}
+
+func (r *Repository) sortedIDsLocked(descending bool) []int {
+	ids := make([]int, 0, len(r.byID))
+	for id := range r.byID {
+		ids = append(ids, id)
+	}
+	if descending {
+		sort.Sort(sort.Reverse(sort.IntSlice(ids)))
+	} else {
+		sort.Ints(ids)
+	}
+	return ids
+}
+
+func (e *entry) copyURL() *models.URL {
+	url := e.url
+	url.ExpiresAt = copyTime(e.url.ExpiresAt)
+	return &url
+}
+
+func (e *entry) details() *models.URLDetails {
+	return &models.URLDetails{
+		URL:           *e.copyURL(),
+		ClickCount:    e.clickCount,
+		LastClickedAt: copyTime(e.lastClickedAt),
+	}
+}
+
+func isExpired(url *models.URL, now time.Time) bool {
+	return url.ExpiresAt != nil && !url.ExpiresAt.After(now)
+}
+
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	value := *t
+	return &value
+}
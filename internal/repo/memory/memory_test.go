@@ -0,0 +1,14 @@
+package memory
+
+import (
+	"testing"
+
+	"url-shortener-go/internal/service"
+	"url-shortener-go/internal/storagetest"
+)
+
+func TestRepository_Conformance(t *testing.T) {
+	storagetest.RunRepositoryTests(t, func(t *testing.T) service.Repository {
+		return NewRepository()
+	})
+}
@@ -0,0 +1,127 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"url-shortener-go/internal/models"
+)
+
+// ArchiveURLs copies urls and their stats into urls_archive and
+// url_stats_archive. Link IDs are never reused, so archiving a link again
+// overwrites its earlier copy.
+func (r *Repository) ArchiveURLs(ctx context.Context, urls []models.URLDetails) (err error) {
+	ctx, span := startSpan(ctx, "ArchiveURLs")
+	defer func() { endSpan(span, err) }()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	urlStmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO urls_archive (id, short_code, original_url, status, created_at, expires_at, archived_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (id) DO UPDATE
+		SET
+			short_code = EXCLUDED.short_code,
+			original_url = EXCLUDED.original_url,
+			status = EXCLUDED.status,
+			expires_at = EXCLUDED.expires_at,
+			archived_at = EXCLUDED.archived_at
+	`)
+	if err != nil {
+		return err
+	}
+	defer urlStmt.Close()
+
+	statsStmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO url_stats_archive (url_id, click_count, last_clicked_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (url_id) DO UPDATE
+		SET
+			click_count = EXCLUDED.click_count,
+			last_clicked_at = EXCLUDED.last_clicked_at
+	`)
+	if err != nil {
+		return err
+	}
+	defer statsStmt.Close()
+
+	archivedAt := time.Now().UTC()
+	for _, url := range urls {
+		if _, err := urlStmt.ExecContext(ctx,
+			url.ID,
+			url.ShortCode,
+			url.OriginalURL,
+			url.Status,
+			url.CreatedAt,
+			url.ExpiresAt,
+			archivedAt,
+		); err != nil {
+			return err
+		}
+		if _, err := statsStmt.ExecContext(ctx, url.ID, url.ClickCount, url.LastClickedAt); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetArchivedURLs returns the archived links that used shortCode, most
+// recently archived first.
+func (r *Repository) GetArchivedURLs(ctx context.Context, shortCode string) (_ []models.ArchivedURL, err error) {
+	ctx, span := startSpan(ctx, "GetArchivedURLs")
+	defer func() { endSpan(span, err) }()
+
+	query := `
+		SELECT a.id, a.short_code, a.original_url, a.status, a.created_at, a.expires_at,
+			COALESCE(s.click_count, 0), s.last_clicked_at, a.archived_at
+		FROM urls_archive a
+		LEFT JOIN url_stats_archive s ON s.url_id = a.id
+		WHERE a.short_code = $1
+		ORDER BY a.archived_at DESC, a.id DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var urls []models.ArchivedURL
+	for rows.Next() {
+		var archived models.ArchivedURL
+		if err := rows.Scan(
+			&archived.ID,
+			&archived.ShortCode,
+			&archived.OriginalURL,
+			&archived.Status,
+			&archived.CreatedAt,
+			&archived.ExpiresAt,
+			&archived.ClickCount,
+			&archived.LastClickedAt,
+			&archived.ArchivedAt,
+		); err != nil {
+			return nil, err
+		}
+		urls = append(urls, archived)
+	}
+
+	return urls, rows.Err()
+}
+
+// PurgeArchivedURLs removes links archived before cutoff; their archived
+// stats go with them through ON DELETE CASCADE.
+func (r *Repository) PurgeArchivedURLs(ctx context.Context, cutoff time.Time) (_ int64, err error) {
+	ctx, span := startSpan(ctx, "PurgeArchivedURLs")
+	defer func() { endSpan(span, err) }()
+
+	result, err := r.db.ExecContext(ctx, "DELETE FROM urls_archive WHERE archived_at < $1", cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,61 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"hash/fnv"
+)
+
+// AdvisoryLock is a session-level advisory lock held on its own connection.
+// Postgres releases it when that connection closes, so a crashed holder
+// never keeps it.
+type AdvisoryLock struct {
+	conn *sql.Conn
+	key  int64
+}
+
+// TryAdvisoryLock takes the lock called name without waiting. It returns nil
+// and no error if another session holds it.
+func (r *Repository) TryAdvisoryLock(ctx context.Context, name string) (*AdvisoryLock, error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	key := lockKey(name)
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !acquired {
+		conn.Close()
+		return nil, nil
+	}
+
+	return &AdvisoryLock{conn: conn, key: key}, nil
+}
+
+// Check fails if the connection holding the lock, and with it the lock, is
+// gone.
+func (l *AdvisoryLock) Check(ctx context.Context) error {
+	return l.conn.PingContext(ctx)
+}
+
+// Release unlocks and returns the connection to the pool.
+func (l *AdvisoryLock) Release(ctx context.Context) error {
+	if _, err := l.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", l.key); err != nil {
+		// A connection that may still hold the lock must not go back to
+		// the pool; returning ErrBadConn makes database/sql close it.
+		l.conn.Raw(func(any) error { return driver.ErrBadConn })
+		return err
+	}
+	return l.conn.Close()
+}
+
+func lockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return int64(h.Sum64())
+}
@@ -59,6 +59,12 @@ func TestMigrations_Up(t *testing.T) {
 	assertRegclass(t, ctx, db, "public.urls")
 	assertRegclass(t, ctx, db, "public.url_stats")
 	assertRegclass(t, ctx, db, "public.idx_original_url")
+	assertRegclass(t, ctx, db, "public.idx_created_at")
+
+	var status sql.NullString
+	if err := db.QueryRowContext(ctx, "SELECT column_default FROM information_schema.columns WHERE table_name = 'urls' AND column_name = 'status'").Scan(&status); err != nil {
+		t.Fatalf("expected urls.status column: %v", err)
+	}
 }
 
 func assertRegclass(t *testing.T, ctx context.Context, db *sql.DB, name string) {
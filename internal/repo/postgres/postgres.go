@@ -5,14 +5,19 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"url-shortener-go/internal/models"
 	"url-shortener-go/internal/service"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
+// SchemaVersion is the last migration in migrations/ that this build
+// expects to have been applied. Bump it together with every new migration.
+const SchemaVersion = 11
+
 type Repository struct {
 	db *sql.DB
 }
@@ -58,19 +63,140 @@ func pingWithRetry(db *sql.DB, attempts int, baseDelay time.Duration) error {
 	return err
 }
 
-func (r *Repository) Create(ctx context.Context, url *models.URL) error {
+// insertURLQuery inserts a link with an empty stats row and returns nothing
+// when the short code is already taken.
+const insertURLQuery = `
+	WITH inserted_url AS (
+		INSERT INTO urls (short_code, original_url, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (short_code) DO NOTHING
+		RETURNING id, created_at
+	), inserted_stats AS (
+		INSERT INTO url_stats (url_id, click_count)
+		SELECT id, 0
+		FROM inserted_url
+	)
+	SELECT id, created_at FROM inserted_url
+`
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func insertURL(ctx context.Context, q queryRower, url *models.URL) error {
+	err := q.QueryRowContext(ctx, insertURLQuery,
+		url.ShortCode,
+		url.OriginalURL,
+		url.ExpiresAt,
+	).Scan(&url.ID, &url.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return service.ErrConflict
+	}
+	return err
+}
+
+func (r *Repository) Create(ctx context.Context, url *models.URL) (err error) {
+	ctx, span := startSpan(ctx, "Create")
+	defer func() { endSpan(span, err) }()
+
+	return insertURL(ctx, r.db, url)
+}
+
+// CreateBatch inserts all urls in one transaction. Short code conflicts are
+// reported per item; any other error aborts the whole batch.
+func (r *Repository) CreateBatch(ctx context.Context, urls []*models.URL) (_ []error, err error) {
+	ctx, span := startSpan(ctx, "CreateBatch")
+	defer func() { endSpan(span, err) }()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, insertURLQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	errs := make([]error, len(urls))
+	for i, url := range urls {
+		err := stmt.QueryRowContext(ctx, url.ShortCode, url.OriginalURL, url.ExpiresAt).Scan(&url.ID, &url.CreatedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			errs[i] = service.ErrConflict
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return errs, nil
+}
+
+// Import stores a link from another system, keeping its short code,
+// timestamps and click count. An existing short code is a conflict unless
+// overwrite is set, in which case the existing link and its stats are
+// replaced and its click history is deleted.
+func (r *Repository) Import(ctx context.Context, details *models.URLDetails, overwrite bool) (err error) {
+	ctx, span := startSpan(ctx, "Import")
+	defer func() { endSpan(span, err) }()
+
 	query := `
 		WITH inserted_url AS (
-			INSERT INTO urls (short_code, original_url, expires_at)
-			VALUES ($1, $2, $3)
+			INSERT INTO urls (short_code, original_url, status, created_at, expires_at)
+			VALUES ($1, $2, $3, COALESCE($4::timestamp, NOW()), $5)
 			ON CONFLICT (short_code) DO NOTHING
 			RETURNING id
+		), inserted_stats AS (
+			INSERT INTO url_stats (url_id, click_count, last_clicked_at)
+			SELECT id, $6, $7
+			FROM inserted_url
 		)
-		INSERT INTO url_stats (url_id, click_count)
-		SELECT id, 0
-		FROM inserted_url
-		WHERE id IS NOT NULL;
+		SELECT id FROM inserted_url
 	`
+	if overwrite {
+		query = `
+			WITH upserted_url AS (
+				INSERT INTO urls (short_code, original_url, status, created_at, expires_at)
+				VALUES ($1, $2, $3, COALESCE($4::timestamp, NOW()), $5)
+				ON CONFLICT (short_code) DO UPDATE
+				SET
+					original_url = EXCLUDED.original_url,
+					status = EXCLUDED.status,
+					created_at = EXCLUDED.created_at,
+					expires_at = EXCLUDED.expires_at
+				RETURNING id
+			), updated_stats AS (
+				UPDATE url_stats
+				SET click_count = $6, last_clicked_at = $7
+				FROM upserted_url
+				WHERE url_stats.url_id = upserted_url.id
+				RETURNING url_stats.url_id
+			), inserted_stats AS (
+				INSERT INTO url_stats (url_id, click_count, last_clicked_at)
+				SELECT id, $6, $7
+				FROM upserted_url
+				WHERE NOT EXISTS (SELECT 1 FROM updated_stats)
+			)
+			SELECT id FROM upserted_url
+		`
+	}
+
+	status := details.Status
+	if status == "" {
+		status = models.URLStatusActive
+	}
+
+	var createdAt *time.Time
+	if !details.CreatedAt.IsZero() {
+		createdAt = &details.CreatedAt
+	}
 
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -78,42 +204,62 @@ func (r *Repository) Create(ctx context.Context, url *models.URL) error {
 	}
 	defer tx.Rollback()
 
-	result, err := tx.ExecContext(ctx, query,
-		url.ShortCode,
-		url.OriginalURL,
-		url.ExpiresAt,
-	)
-	if err != nil {
-		return err
+	err = tx.QueryRowContext(ctx, query,
+		details.ShortCode,
+		details.OriginalURL,
+		status,
+		utc(createdAt),
+		utc(details.ExpiresAt),
+		details.ClickCount,
+		utc(details.LastClickedAt),
+	).Scan(&details.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return service.ErrConflict
 	}
-
-	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		return err
 	}
 
-	if rowsAffected == 0 {
-		return service.ErrConflict
+	// An overwritten link keeps its id, so the clicks of the link it replaced
+	// would otherwise show up in its stats.
+	if overwrite {
+		for _, query := range []string{
+			"DELETE FROM url_clicks WHERE url_id = $1",
+			"DELETE FROM url_clicks_hourly WHERE url_id = $1",
+		} {
+			if _, err := tx.ExecContext(ctx, query, details.ID); err != nil {
+				return err
+			}
+		}
 	}
 
 	return tx.Commit()
 }
 
-func (r *Repository) GetByShortCode(ctx context.Context, shortCode string) (*models.URL, error) {
+func (r *Repository) GetByShortCode(ctx context.Context, shortCode string) (_ *models.URL, err error) {
+	ctx, span := startSpan(ctx, "GetByShortCode")
+	defer func() { endSpan(span, err) }()
+
 	query := `
-		SELECT id, original_url, created_at, expires_at
+		SELECT id, original_url, status, created_at, expires_at,
+			expires_at IS NOT NULL AND expires_at <= NOW()
 		FROM urls
-		WHERE short_code = $1 AND (expires_at IS NULL OR expires_at > NOW())
+		WHERE short_code = $1
 	`
 
-	var url models.URL
+	var (
+		url     models.URL
+		expired bool
+	)
 	url.ShortCode = shortCode
 
-	err := r.db.QueryRowContext(ctx, query, shortCode).Scan(
+	err = r.db.QueryRowContext(ctx, query, shortCode).Scan(
 		&url.ID,
 		&url.OriginalURL,
+		&url.Status,
 		&url.CreatedAt,
 		&url.ExpiresAt,
+		&expired,
 	)
 
 	if errors.Is(err, sql.ErrNoRows) {
@@ -123,22 +269,37 @@ func (r *Repository) GetByShortCode(ctx context.Context, shortCode string) (*mod
 		return nil, err
 	}
 
+	switch {
+	case url.Status == models.URLStatusDeleted:
+		return nil, service.ErrDeleted
+	case url.Status == models.URLStatusDisabled:
+		return nil, service.ErrDisabled
+	case expired:
+		return nil, service.ErrExpired
+	}
+
 	return &url, nil
 }
 
-func (r *Repository) GetByOriginalURL(ctx context.Context, originalURL string) (*models.URL, error) {
+func (r *Repository) GetByOriginalURL(ctx context.Context, originalURL string) (_ *models.URL, err error) {
+	ctx, span := startSpan(ctx, "GetByOriginalURL")
+	defer func() { endSpan(span, err) }()
+
 	query := `
 		SELECT id, short_code, created_at, expires_at
 		FROM urls
-		WHERE original_url = $1 AND (expires_at IS NULL OR expires_at > NOW())
-		ORDER BY created_at DESC
+		WHERE original_url = $1
+			AND status = 'active'
+			AND (expires_at IS NULL OR expires_at > NOW())
+		ORDER BY created_at DESC, id DESC
 		LIMIT 1
 	`
 
 	var url models.URL
 	url.OriginalURL = originalURL
+	url.Status = models.URLStatusActive
 
-	err := r.db.QueryRowContext(ctx, query, originalURL).Scan(
+	err = r.db.QueryRowContext(ctx, query, originalURL).Scan(
 		&url.ID,
 		&url.ShortCode,
 		&url.CreatedAt,
@@ -155,32 +316,524 @@ func (r *Repository) GetByOriginalURL(ctx context.Context, originalURL string) (
 	return &url, nil
 }
 
-func (r *Repository) IncrementClickCount(ctx context.Context, urlID int) error {
+// GetByOriginalURLs is the bulk form of GetByOriginalURL. URLs without an
+// active link are absent from the result.
+func (r *Repository) GetByOriginalURLs(ctx context.Context, originalURLs []string) (_ map[string]*models.URL, err error) {
+	ctx, span := startSpan(ctx, "GetByOriginalURLs")
+	defer func() { endSpan(span, err) }()
+
 	query := `
+		SELECT DISTINCT ON (original_url) id, short_code, original_url, created_at, expires_at
+		FROM urls
+		WHERE original_url = ANY($1)
+			AND status = 'active'
+			AND (expires_at IS NULL OR expires_at > NOW())
+		ORDER BY original_url, created_at DESC, id DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(originalURLs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	urls := make(map[string]*models.URL)
+	for rows.Next() {
+		url := &models.URL{Status: models.URLStatusActive}
+		if err := rows.Scan(
+			&url.ID,
+			&url.ShortCode,
+			&url.OriginalURL,
+			&url.CreatedAt,
+			&url.ExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		urls[url.OriginalURL] = url
+	}
+
+	return urls, rows.Err()
+}
+
+func (r *Repository) GetDetailsByShortCode(ctx context.Context, shortCode string) (_ *models.URLDetails, err error) {
+	ctx, span := startSpan(ctx, "GetDetailsByShortCode")
+	defer func() { endSpan(span, err) }()
+
+	query := `
+		SELECT u.id, u.original_url, u.status, u.created_at, u.expires_at,
+			COALESCE(s.click_count, 0), s.last_clicked_at
+		FROM urls u
+		LEFT JOIN url_stats s ON s.url_id = u.id
+		WHERE u.short_code = $1
+	`
+
+	return scanDetails(r.db.QueryRowContext(ctx, query, shortCode), shortCode)
+}
+
+func (r *Repository) Update(ctx context.Context, shortCode string, opts models.UpdateURLOptions) (_ *models.URLDetails, err error) {
+	ctx, span := startSpan(ctx, "Update")
+	defer func() { endSpan(span, err) }()
+
+	query := `
+		WITH updated AS (
+			UPDATE urls
+			SET
+				original_url = COALESCE($2::text, original_url),
+				expires_at = CASE
+					WHEN $3 THEN NULL
+					ELSE COALESCE($4::timestamp, expires_at)
+				END
+			WHERE short_code = $1 AND status <> 'deleted'
+			RETURNING id, original_url, status, created_at, expires_at
+		)
+		SELECT u.id, u.original_url, u.status, u.created_at, u.expires_at,
+			COALESCE(s.click_count, 0), s.last_clicked_at
+		FROM updated u
+		LEFT JOIN url_stats s ON s.url_id = u.id
+	`
+
+	return scanDetails(r.db.QueryRowContext(ctx, query, shortCode, opts.OriginalURL, opts.ClearExpiresAt, utc(opts.ExpiresAt)), shortCode)
+}
+
+// utc converts t for a TIMESTAMP column, which would otherwise keep its wall
+// clock time and drop its offset.
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	return new(t.UTC())
+}
+
+func (r *Repository) SetStatus(ctx context.Context, shortCode string, status models.URLStatus) (_ *models.URLDetails, err error) {
+	ctx, span := startSpan(ctx, "SetStatus")
+	defer func() { endSpan(span, err) }()
+
+	query := `
+		WITH updated AS (
+			UPDATE urls
+			SET status = $2
+			WHERE short_code = $1
+			RETURNING id, original_url, status, created_at, expires_at
+		)
+		SELECT u.id, u.original_url, u.status, u.created_at, u.expires_at,
+			COALESCE(s.click_count, 0), s.last_clicked_at
+		FROM updated u
+		LEFT JOIN url_stats s ON s.url_id = u.id
+	`
+
+	return scanDetails(r.db.QueryRowContext(ctx, query, shortCode, status), shortCode)
+}
+
+func scanDetails(row *sql.Row, shortCode string) (*models.URLDetails, error) {
+	var details models.URLDetails
+	details.ShortCode = shortCode
+
+	err := row.Scan(
+		&details.ID,
+		&details.OriginalURL,
+		&details.Status,
+		&details.CreatedAt,
+		&details.ExpiresAt,
+		&details.ClickCount,
+		&details.LastClickedAt,
+	)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, service.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &details, nil
+}
+
+func (r *Repository) List(ctx context.Context, filter models.ListURLsFilter) (_ []models.URLDetails, err error) {
+	ctx, span := startSpan(ctx, "List")
+	defer func() { endSpan(span, err) }()
+
+	var (
+		conditions []string
+		args       []interface{}
+	)
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.AfterID > 0 {
+		where("u.id < $%d", filter.AfterID)
+	}
+	if filter.CreatedAfter != nil {
+		where("u.created_at >= $%d", filter.CreatedAfter.UTC())
+	}
+	if filter.CreatedBefore != nil {
+		where("u.created_at < $%d", filter.CreatedBefore.UTC())
+	}
+	if filter.Query != "" {
+		where(`u.original_url ILIKE '%%' || $%d || '%%' ESCAPE '\'`, likeEscaper.Replace(filter.Query))
+	}
+	switch filter.Status {
+	case models.ListStatusActive:
+		conditions = append(conditions, "u.status = 'active'", "(u.expires_at IS NULL OR u.expires_at > NOW())")
+	case models.ListStatusExpired:
+		conditions = append(conditions, "u.status <> 'deleted'", "u.expires_at <= NOW()")
+	case models.ListStatusDisabled:
+		conditions = append(conditions, "u.status = 'disabled'")
+	case models.ListStatusDeleted:
+		conditions = append(conditions, "u.status = 'deleted'")
+	default:
+		conditions = append(conditions, "u.status <> 'deleted'")
+	}
+
+	query := `
+		SELECT u.id, u.short_code, u.original_url, u.status, u.created_at, u.expires_at,
+			COALESCE(s.click_count, 0), s.last_clicked_at
+		FROM urls u
+		LEFT JOIN url_stats s ON s.url_id = u.id
+	`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY u.id DESC LIMIT $%d", len(args))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	urls := make([]models.URLDetails, 0, filter.Limit)
+	for rows.Next() {
+		var details models.URLDetails
+		if err := rows.Scan(
+			&details.ID,
+			&details.ShortCode,
+			&details.OriginalURL,
+			&details.Status,
+			&details.CreatedAt,
+			&details.ExpiresAt,
+			&details.ClickCount,
+			&details.LastClickedAt,
+		); err != nil {
+			return nil, err
+		}
+		urls = append(urls, details)
+	}
+
+	return urls, rows.Err()
+}
+
+// exportFetchSize is how many rows ExportURLs pulls from its cursor at a time.
+const exportFetchSize = 500
+
+// ExportURLs calls fn for every link, including deleted ones, ordered by id.
+// Rows are read through a server-side cursor so memory use stays flat.
+func (r *Repository) ExportURLs(ctx context.Context, fn func(*models.URLDetails) error) (err error) {
+	ctx, span := startSpan(ctx, "ExportURLs")
+	defer func() { endSpan(span, err) }()
+
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		DECLARE export_cursor NO SCROLL CURSOR FOR
+		SELECT u.id, u.short_code, u.original_url, u.status, u.created_at, u.expires_at,
+			COALESCE(s.click_count, 0), s.last_clicked_at
+		FROM urls u
+		LEFT JOIN url_stats s ON s.url_id = u.id
+		ORDER BY u.id
+	`); err != nil {
+		return err
+	}
+
+	fetch := fmt.Sprintf("FETCH FORWARD %d FROM export_cursor", exportFetchSize)
+	for {
+		fetched, err := fetchExportRows(ctx, tx, fetch, fn)
+		if err != nil {
+			return err
+		}
+		if fetched < exportFetchSize {
+			break
+		}
+	}
+
+	return tx.Commit()
+}
+
+func fetchExportRows(ctx context.Context, tx *sql.Tx, fetch string, fn func(*models.URLDetails) error) (int, error) {
+	rows, err := tx.QueryContext(ctx, fetch)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	fetched := 0
+	for rows.Next() {
+		var details models.URLDetails
+		if err := rows.Scan(
+			&details.ID,
+			&details.ShortCode,
+			&details.OriginalURL,
+			&details.Status,
+			&details.CreatedAt,
+			&details.ExpiresAt,
+			&details.ClickCount,
+			&details.LastClickedAt,
+		); err != nil {
+			return fetched, err
+		}
+		fetched++
+		if err := fn(&details); err != nil {
+			return fetched, err
+		}
+	}
+
+	return fetched, rows.Err()
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// RecordClicks writes a flushed batch of clicks in one transaction: the
+// coalesced counts go to url_stats and the url_clicks_hourly rollup, and each
+// event gets a url_clicks row. Clicks on links deleted since the redirect are
+// skipped. An event the database cannot store fails with
+// service.ErrInvalidClicks.
+func (r *Repository) RecordClicks(ctx context.Context, counts []models.ClickCount, events []models.Click) (err error) {
+	ctx, span := startSpan(ctx, "RecordClicks")
+	defer func() { endSpan(span, err) }()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	countStmt, err := tx.PrepareContext(ctx, `
 		UPDATE url_stats
 		SET
-			click_count = click_count + 1,
-			last_clicked_at = NOW()
+			click_count = click_count + $2,
+			last_clicked_at = GREATEST(last_clicked_at, $3)
 		WHERE url_id = $1
+	`)
+	if err != nil {
+		return err
+	}
+	defer countStmt.Close()
+
+	rollupStmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO url_clicks_hourly (url_id, bucket, clicks)
+		SELECT $1, $2, $3
+		WHERE EXISTS (SELECT 1 FROM urls WHERE id = $1)
+		ON CONFLICT (url_id, bucket) DO UPDATE
+		SET clicks = url_clicks_hourly.clicks + EXCLUDED.clicks
+	`)
+	if err != nil {
+		return err
+	}
+	defer rollupStmt.Close()
+
+	for _, count := range counts {
+		if _, err := countStmt.ExecContext(ctx, count.URLID, count.Count, count.LastClickedAt); err != nil {
+			return err
+		}
+		if _, err := rollupStmt.ExecContext(ctx, count.URLID, count.Hour.UTC(), count.Count); err != nil {
+			return err
+		}
+	}
+
+	eventStmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO url_clicks (url_id, clicked_at, referrer, user_agent, ip_hash, request_id)
+		SELECT $1, $2, NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, '')
+		WHERE EXISTS (SELECT 1 FROM urls WHERE id = $1)
+	`)
+	if err != nil {
+		return err
+	}
+	defer eventStmt.Close()
+
+	for _, event := range events {
+		if _, err := eventStmt.ExecContext(ctx,
+			event.URLID,
+			event.ClickedAt,
+			event.Referrer,
+			event.UserAgent,
+			event.IPHash,
+			event.RequestID,
+		); err != nil {
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Code.Class() == "22" {
+				return fmt.Errorf("%w: %w", service.ErrInvalidClicks, err)
+			}
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetHourlyClicks returns the non-empty hourly click buckets of a link in
+// [from, to), oldest first.
+func (r *Repository) GetHourlyClicks(ctx context.Context, urlID int, from, to time.Time) (_ []models.ClickBucket, err error) {
+	ctx, span := startSpan(ctx, "GetHourlyClicks")
+	defer func() { endSpan(span, err) }()
+
+	query := `
+		SELECT bucket, clicks
+		FROM url_clicks_hourly
+		WHERE url_id = $1 AND bucket >= $2 AND bucket < $3
+		ORDER BY bucket
 	`
 
-	_, err := r.db.ExecContext(ctx, query, urlID)
-	return err
+	rows, err := r.db.QueryContext(ctx, query, urlID, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var buckets []models.ClickBucket
+	for rows.Next() {
+		var bucket models.ClickBucket
+		if err := rows.Scan(&bucket.Start, &bucket.Clicks); err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	return buckets, rows.Err()
 }
 
-func (r *Repository) DeleteExpiredURLs(ctx context.Context) error {
+// DeleteExpiredURLs removes the oldest expired links first. Rows locked by
+// another transaction are skipped rather than waited for; stats and clicks go
+// with the links through ON DELETE CASCADE. With archive set, the links stay
+// locked while archive runs and are only deleted if it succeeds.
+func (r *Repository) DeleteExpiredURLs(ctx context.Context, limit int, archive service.ArchiveFunc) (_ int64, err error) {
+	ctx, span := startSpan(ctx, "DeleteExpiredURLs")
+	defer func() { endSpan(span, err) }()
+
+	if archive != nil {
+		return r.archiveExpiredURLs(ctx, limit, archive)
+	}
+
 	query := `
-		WITH deleted_urls AS (
-			DELETE FROM urls
+		DELETE FROM urls
+		WHERE id IN (
+			SELECT id FROM urls
 			WHERE expires_at < NOW()
-			RETURNING id
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
 		)
-		DELETE FROM url_stats
-		WHERE url_id IN (SELECT id FROM deleted_urls)
 	`
 
-	_, err := r.db.ExecContext(ctx, query)
-	return err
+	result, err := r.db.ExecContext(ctx, query, limit)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (r *Repository) archiveExpiredURLs(ctx context.Context, limit int, archive service.ArchiveFunc) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT u.id, u.short_code, u.original_url, u.status, u.created_at, u.expires_at,
+			COALESCE(s.click_count, 0), s.last_clicked_at
+		FROM urls u
+		LEFT JOIN url_stats s ON s.url_id = u.id
+		WHERE u.expires_at < NOW()
+		ORDER BY u.id
+		LIMIT $1
+		FOR UPDATE OF u SKIP LOCKED
+	`, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		urls []models.URLDetails
+		ids  []int64
+	)
+	for rows.Next() {
+		var details models.URLDetails
+		if err := rows.Scan(
+			&details.ID,
+			&details.ShortCode,
+			&details.OriginalURL,
+			&details.Status,
+			&details.CreatedAt,
+			&details.ExpiresAt,
+			&details.ClickCount,
+			&details.LastClickedAt,
+		); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		urls = append(urls, details)
+		ids = append(ids, int64(details.ID))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(urls) == 0 {
+		return 0, nil
+	}
+
+	if err := archive(ctx, urls); err != nil {
+		return 0, fmt.Errorf("failed to archive expired links: %w", err)
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM urls WHERE id = ANY($1)", pq.Array(ids))
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return deleted, tx.Commit()
+}
+
+// Ping checks that the database answers.
+func (r *Repository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
+
+// CheckSchema fails if the applied migration version is older than
+// SchemaVersion or the last migration did not finish cleanly. A newer version
+// is accepted: migrations run before a rollout, while the old pods are still
+// serving.
+func (r *Repository) CheckSchema(ctx context.Context) error {
+	var version int64
+	var dirty bool
+	err := r.db.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("no migrations applied, expected version %d", SchemaVersion)
+	}
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("migration %d is dirty", version)
+	}
+	if version < SchemaVersion {
+		return fmt.Errorf("schema version is %d, expected at least %d", version, SchemaVersion)
+	}
+	return nil
+}
+
+// Stats reports the connection pool usage.
+func (r *Repository) Stats() sql.DBStats {
+	return r.db.Stats()
 }
 
 func (r *Repository) Close() error {
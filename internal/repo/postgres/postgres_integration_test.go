@@ -3,6 +3,7 @@ package postgres
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os"
 	"testing"
 	"time"
@@ -10,6 +11,7 @@ import (
 	"url-shortener-go/config"
 	"url-shortener-go/internal/models"
 	"url-shortener-go/internal/service"
+	"url-shortener-go/internal/storagetest"
 )
 
 func TestPostgresRepository_GetByShortCode_NotFound(t *testing.T) {
@@ -94,7 +96,318 @@ func TestPostgresRepository_CreateAndGet(t *testing.T) {
 		t.Fatalf("expected %s, got %s", shortCode, byOriginal.ShortCode)
 	}
 
-	if err := repo.IncrementClickCount(context.Background(), got.ID); err != nil {
-		t.Fatalf("failed to increment click count: %v", err)
+	clickedAt := time.Now().UTC()
+	counts := []models.ClickCount{{URLID: got.ID, Hour: clickedAt.Truncate(time.Hour), Count: 1, LastClickedAt: clickedAt}}
+	events := []models.Click{{
+		URLID:     got.ID,
+		ClickedAt: clickedAt,
+		Referrer:  "https://referrer.example.com",
+		UserAgent: "integration-test",
+		IPHash:    "abc",
+	}}
+	if err := repo.RecordClicks(context.Background(), counts, events); err != nil {
+		t.Fatalf("failed to record clicks: %v", err)
 	}
+
+	hourly, err := repo.GetHourlyClicks(context.Background(), got.ID, clickedAt.Truncate(time.Hour), clickedAt.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to get hourly clicks: %v", err)
+	}
+	if len(hourly) != 1 || hourly[0].Clicks != 1 {
+		t.Fatalf("expected one hourly bucket with 1 click, got %+v", hourly)
+	}
+
+	details, err := repo.GetDetailsByShortCode(context.Background(), shortCode)
+	if err != nil {
+		t.Fatalf("failed to get details: %v", err)
+	}
+	if details.ClickCount != 1 {
+		t.Fatalf("expected click count 1, got %d", details.ClickCount)
+	}
+	if details.LastClickedAt == nil {
+		t.Fatalf("expected last clicked at to be set")
+	}
+}
+
+func TestPostgresRepository_StatusLifecycle(t *testing.T) {
+	if os.Getenv("INTEGRATION_TESTS") == "" {
+		t.Skip("INTEGRATION_TESTS not set")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	repo, err := NewRepository(cfg.GetPostgresConnString(), PoolConfig{
+		MaxOpenConns:    cfg.DBMaxOpenConns,
+		MaxIdleConns:    cfg.DBMaxIdleConns,
+		ConnMaxLifetime: cfg.DBConnMaxLifetime,
+		ConnMaxIdleTime: cfg.DBConnMaxIdleTime,
+	})
+	if err != nil {
+		t.Fatalf("failed to init repo: %v", err)
+	}
+	defer repo.Close()
+
+	ctx := context.Background()
+	shortCode := "statuscode"
+	expiredCode := "expiredcode"
+	past := time.Now().Add(-time.Hour)
+
+	t.Cleanup(func() {
+		_, _ = repo.db.Exec("DELETE FROM urls WHERE short_code IN ($1, $2)", shortCode, expiredCode)
+	})
+
+	if err := repo.Create(ctx, &models.URL{ShortCode: shortCode, OriginalURL: "https://example.com/status"}); err != nil {
+		t.Fatalf("failed to create url: %v", err)
+	}
+	if err := repo.Create(ctx, &models.URL{ShortCode: expiredCode, OriginalURL: "https://example.com/expired", ExpiresAt: &past}); err != nil {
+		t.Fatalf("failed to create url: %v", err)
+	}
+
+	if _, err := repo.GetByShortCode(ctx, expiredCode); !errors.Is(err, service.ErrExpired) {
+		t.Fatalf("expected ErrExpired, got %v", err)
+	}
+
+	if _, err := repo.SetStatus(ctx, shortCode, models.URLStatusDisabled); err != nil {
+		t.Fatalf("failed to disable: %v", err)
+	}
+	if _, err := repo.GetByShortCode(ctx, shortCode); !errors.Is(err, service.ErrDisabled) {
+		t.Fatalf("expected ErrDisabled, got %v", err)
+	}
+
+	if _, err := repo.SetStatus(ctx, shortCode, models.URLStatusDeleted); err != nil {
+		t.Fatalf("failed to delete: %v", err)
+	}
+	if _, err := repo.GetByShortCode(ctx, shortCode); !errors.Is(err, service.ErrDeleted) {
+		t.Fatalf("expected ErrDeleted, got %v", err)
+	}
+
+	if _, err := repo.SetStatus(ctx, shortCode, models.URLStatusActive); err != nil {
+		t.Fatalf("failed to restore: %v", err)
+	}
+	if _, err := repo.GetByShortCode(ctx, shortCode); err != nil {
+		t.Fatalf("expected restored link, got %v", err)
+	}
+}
+
+func TestPostgresRepository_CreateBatch(t *testing.T) {
+	if os.Getenv("INTEGRATION_TESTS") == "" {
+		t.Skip("INTEGRATION_TESTS not set")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	repo, err := NewRepository(cfg.GetPostgresConnString(), PoolConfig{
+		MaxOpenConns:    cfg.DBMaxOpenConns,
+		MaxIdleConns:    cfg.DBMaxIdleConns,
+		ConnMaxLifetime: cfg.DBConnMaxLifetime,
+		ConnMaxIdleTime: cfg.DBConnMaxIdleTime,
+	})
+	if err != nil {
+		t.Fatalf("failed to init repo: %v", err)
+	}
+	defer repo.Close()
+
+	ctx := context.Background()
+	t.Cleanup(func() {
+		_, _ = repo.db.Exec("DELETE FROM urls WHERE short_code IN ('batch1', 'batch2')")
+	})
+
+	urls := []*models.URL{
+		{ShortCode: "batch1", OriginalURL: "https://example.com/batch1"},
+		{ShortCode: "batch2", OriginalURL: "https://example.com/batch2"},
+		{ShortCode: "batch1", OriginalURL: "https://example.com/duplicate"},
+	}
+	errs, err := repo.CreateBatch(ctx, urls)
+	if err != nil {
+		t.Fatalf("failed to create batch: %v", err)
+	}
+	if errs[0] != nil || errs[1] != nil {
+		t.Fatalf("expected first two items to succeed, got %v", errs)
+	}
+	if !errors.Is(errs[2], service.ErrConflict) {
+		t.Fatalf("expected duplicate to conflict, got %v", errs[2])
+	}
+	if urls[0].ID == 0 || urls[1].ID == 0 {
+		t.Fatalf("expected ids to be assigned")
+	}
+
+	found, err := repo.GetByOriginalURLs(ctx, []string{"https://example.com/batch1", "https://example.com/missing"})
+	if err != nil {
+		t.Fatalf("failed to get by original urls: %v", err)
+	}
+	if len(found) != 1 || found["https://example.com/batch1"].ShortCode != "batch1" {
+		t.Fatalf("unexpected lookup result: %v", found)
+	}
+}
+
+func TestPostgresRepository_Import(t *testing.T) {
+	if os.Getenv("INTEGRATION_TESTS") == "" {
+		t.Skip("INTEGRATION_TESTS not set")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	repo, err := NewRepository(cfg.GetPostgresConnString(), PoolConfig{
+		MaxOpenConns:    cfg.DBMaxOpenConns,
+		MaxIdleConns:    cfg.DBMaxIdleConns,
+		ConnMaxLifetime: cfg.DBConnMaxLifetime,
+		ConnMaxIdleTime: cfg.DBConnMaxIdleTime,
+	})
+	if err != nil {
+		t.Fatalf("failed to init repo: %v", err)
+	}
+	defer repo.Close()
+
+	ctx := context.Background()
+	shortCode := "imported"
+	t.Cleanup(func() {
+		_, _ = repo.db.Exec("DELETE FROM urls WHERE short_code = $1", shortCode)
+	})
+
+	// Legacy timestamps with an offset keep their instant.
+	createdAt := time.Date(2020, 1, 2, 5, 4, 5, 0, time.FixedZone("UTC+2", 2*60*60))
+	details := &models.URLDetails{
+		URL:        models.URL{ShortCode: shortCode, OriginalURL: "https://example.com/legacy", CreatedAt: createdAt},
+		ClickCount: 99,
+	}
+	if err := repo.Import(ctx, details, false); err != nil {
+		t.Fatalf("failed to import: %v", err)
+	}
+	if err := repo.Import(ctx, details, false); !errors.Is(err, service.ErrConflict) {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+
+	details.OriginalURL = "https://example.com/legacy-updated"
+	details.ClickCount = 100
+	if err := repo.Import(ctx, details, true); err != nil {
+		t.Fatalf("failed to overwrite: %v", err)
+	}
+
+	got, err := repo.GetDetailsByShortCode(ctx, shortCode)
+	if err != nil {
+		t.Fatalf("failed to get details: %v", err)
+	}
+	if got.OriginalURL != "https://example.com/legacy-updated" || got.ClickCount != 100 {
+		t.Fatalf("unexpected imported link: %+v", got)
+	}
+	if !got.CreatedAt.Equal(createdAt) {
+		t.Fatalf("expected created_at %s, got %s", createdAt, got.CreatedAt)
+	}
+}
+
+func TestPostgresRepository_Conformance(t *testing.T) {
+	if os.Getenv("INTEGRATION_TESTS") == "" {
+		t.Skip("INTEGRATION_TESTS not set")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	repo, err := NewRepository(cfg.GetPostgresConnString(), PoolConfig{
+		MaxOpenConns:    cfg.DBMaxOpenConns,
+		MaxIdleConns:    cfg.DBMaxIdleConns,
+		ConnMaxLifetime: cfg.DBConnMaxLifetime,
+		ConnMaxIdleTime: cfg.DBConnMaxIdleTime,
+	})
+	if err != nil {
+		t.Fatalf("failed to init repo: %v", err)
+	}
+	defer repo.Close()
+
+	storagetest.RunRepositoryTests(t, func(t *testing.T) service.Repository {
+		return repo
+	})
+}
+
+func TestPostgresRepository_RecordClicksRejectsInvalidText(t *testing.T) {
+	if os.Getenv("INTEGRATION_TESTS") == "" {
+		t.Skip("INTEGRATION_TESTS not set")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	repo, err := NewRepository(cfg.GetPostgresConnString(), PoolConfig{
+		MaxOpenConns:    cfg.DBMaxOpenConns,
+		MaxIdleConns:    cfg.DBMaxIdleConns,
+		ConnMaxLifetime: cfg.DBConnMaxLifetime,
+		ConnMaxIdleTime: cfg.DBConnMaxIdleTime,
+	})
+	if err != nil {
+		t.Fatalf("failed to init repo: %v", err)
+	}
+	defer repo.Close()
+
+	ctx := context.Background()
+	url := &models.URL{ShortCode: fmt.Sprintf("bad%d", time.Now().UnixNano()%1e9), OriginalURL: "https://example.com/bad-clicks"}
+	if err := repo.Create(ctx, url); err != nil {
+		t.Fatalf("failed to create url: %v", err)
+	}
+	t.Cleanup(func() {
+		_, _ = repo.db.Exec("DELETE FROM urls WHERE id = $1", url.ID)
+	})
+
+	events := []models.Click{{URLID: url.ID, ClickedAt: time.Now(), Referrer: "https://example.com/\x00"}}
+	if err := repo.RecordClicks(ctx, nil, events); !errors.Is(err, service.ErrInvalidClicks) {
+		t.Fatalf("expected ErrInvalidClicks, got %v", err)
+	}
+}
+
+func TestPostgresRepository_AdvisoryLock(t *testing.T) {
+	if os.Getenv("INTEGRATION_TESTS") == "" {
+		t.Skip("INTEGRATION_TESTS not set")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	repo, err := NewRepository(cfg.GetPostgresConnString(), PoolConfig{
+		MaxOpenConns:    cfg.DBMaxOpenConns,
+		MaxIdleConns:    cfg.DBMaxIdleConns,
+		ConnMaxLifetime: cfg.DBConnMaxLifetime,
+		ConnMaxIdleTime: cfg.DBConnMaxIdleTime,
+	})
+	if err != nil {
+		t.Fatalf("failed to init repo: %v", err)
+	}
+	defer repo.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	name := "test-lock-" + time.Now().Format(time.RFC3339Nano)
+	lock, err := repo.TryAdvisoryLock(ctx, name)
+	if err != nil || lock == nil {
+		t.Fatalf("expected to take the lock, got %v", err)
+	}
+	if other, err := repo.TryAdvisoryLock(ctx, name); err != nil || other != nil {
+		t.Fatalf("expected the lock to be held, got %v and %v", other, err)
+	}
+	if err := lock.Check(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := lock.Release(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	again, err := repo.TryAdvisoryLock(ctx, name)
+	if err != nil || again == nil {
+		t.Fatalf("expected to take the released lock, got %v", err)
+	}
+	again.Release(ctx)
 }
@@ -0,0 +1,29 @@
+package postgres
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSchemaVersion_MatchesMigrations(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "..", "..", "migrations", "*.up.sql"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("failed to list migrations: %v", err)
+	}
+
+	latest := 0
+	for _, file := range files {
+		prefix, _, _ := strings.Cut(filepath.Base(file), "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			t.Fatalf("unexpected migration name %s", file)
+		}
+		latest = max(latest, version)
+	}
+
+	if latest != SchemaVersion {
+		t.Fatalf("SchemaVersion is %d but the latest migration is %d", SchemaVersion, latest)
+	}
+}
@@ -0,0 +1,116 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"url-shortener-go/internal/models"
+)
+
+// ArchiveURLs copies urls and their stats into urls_archive and
+// url_stats_archive. Link IDs are never reused, so archiving a link again
+// overwrites its earlier copy.
+func (r *Repository) ArchiveURLs(ctx context.Context, urls []models.URLDetails) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	archivedAt := toUnix(time.Now())
+	for _, url := range urls {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO urls_archive (id, short_code, original_url, status, created_at, expires_at, archived_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (id) DO UPDATE
+			SET
+				short_code = excluded.short_code,
+				original_url = excluded.original_url,
+				status = excluded.status,
+				expires_at = excluded.expires_at,
+				archived_at = excluded.archived_at
+		`,
+			url.ID,
+			url.ShortCode,
+			url.OriginalURL,
+			url.Status,
+			toUnix(url.CreatedAt),
+			toNullUnix(url.ExpiresAt),
+			archivedAt,
+		); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO url_stats_archive (url_id, click_count, last_clicked_at)
+			VALUES (?, ?, ?)
+			ON CONFLICT (url_id) DO UPDATE
+			SET
+				click_count = excluded.click_count,
+				last_clicked_at = excluded.last_clicked_at
+		`, url.ID, url.ClickCount, toNullUnix(url.LastClickedAt)); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetArchivedURLs returns the archived links that used shortCode, most
+// recently archived first.
+func (r *Repository) GetArchivedURLs(ctx context.Context, shortCode string) ([]models.ArchivedURL, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT a.id, a.short_code, a.original_url, a.status, a.created_at, a.expires_at,
+			COALESCE(s.click_count, 0), s.last_clicked_at, a.archived_at
+		FROM urls_archive a
+		LEFT JOIN url_stats_archive s ON s.url_id = a.id
+		WHERE a.short_code = ?
+		ORDER BY a.archived_at DESC, a.id DESC
+	`, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var urls []models.ArchivedURL
+	for rows.Next() {
+		var (
+			archived      models.ArchivedURL
+			createdAt     int64
+			expiresAt     sql.NullInt64
+			lastClickedAt sql.NullInt64
+			archivedAt    int64
+		)
+		if err := rows.Scan(
+			&archived.ID,
+			&archived.ShortCode,
+			&archived.OriginalURL,
+			&archived.Status,
+			&createdAt,
+			&expiresAt,
+			&archived.ClickCount,
+			&lastClickedAt,
+			&archivedAt,
+		); err != nil {
+			return nil, err
+		}
+		archived.CreatedAt = fromUnix(createdAt)
+		archived.ExpiresAt = fromNullUnix(expiresAt)
+		archived.LastClickedAt = fromNullUnix(lastClickedAt)
+		archived.ArchivedAt = fromUnix(archivedAt)
+		urls = append(urls, archived)
+	}
+
+	return urls, rows.Err()
+}
+
+// PurgeArchivedURLs removes links archived before cutoff; their archived
+// stats go with them through ON DELETE CASCADE.
+func (r *Repository) PurgeArchivedURLs(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM urls_archive WHERE archived_at < ?", toUnix(cutoff))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
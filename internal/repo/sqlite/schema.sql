@@ -0,0 +1,58 @@
+-- Schema for the embedded SQLite backend. Every statement is idempotent and
+-- runs on startup. Timestamps are stored as UTC unix microseconds.
+CREATE TABLE IF NOT EXISTS urls (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  short_code TEXT NOT NULL UNIQUE,
+  original_url TEXT NOT NULL,
+  status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'disabled', 'deleted')),
+  created_at INTEGER NOT NULL,
+  expires_at INTEGER DEFAULT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_original_url ON urls (original_url);
+CREATE INDEX IF NOT EXISTS idx_expires_at ON urls (expires_at);
+CREATE INDEX IF NOT EXISTS idx_created_at ON urls (created_at);
+
+CREATE TABLE IF NOT EXISTS url_stats (
+  url_id INTEGER PRIMARY KEY REFERENCES urls (id) ON DELETE CASCADE,
+  click_count INTEGER NOT NULL DEFAULT 0,
+  last_clicked_at INTEGER DEFAULT NULL
+);
+
+CREATE TABLE IF NOT EXISTS url_clicks (
+  id INTEGER PRIMARY KEY,
+  url_id INTEGER NOT NULL REFERENCES urls (id) ON DELETE CASCADE,
+  clicked_at INTEGER NOT NULL,
+  referrer TEXT DEFAULT NULL,
+  user_agent TEXT DEFAULT NULL,
+  ip_hash TEXT DEFAULT NULL,
+  request_id TEXT DEFAULT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_url_clicks_url_id_clicked_at ON url_clicks (url_id, clicked_at);
+
+CREATE TABLE IF NOT EXISTS url_clicks_hourly (
+  url_id INTEGER NOT NULL REFERENCES urls (id) ON DELETE CASCADE,
+  bucket INTEGER NOT NULL,
+  clicks INTEGER NOT NULL DEFAULT 0,
+  PRIMARY KEY (url_id, bucket)
+);
+
+CREATE TABLE IF NOT EXISTS urls_archive (
+  id INTEGER PRIMARY KEY,
+  short_code TEXT NOT NULL,
+  original_url TEXT NOT NULL,
+  status TEXT NOT NULL,
+  created_at INTEGER NOT NULL,
+  expires_at INTEGER DEFAULT NULL,
+  archived_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_urls_archive_short_code ON urls_archive (short_code);
+CREATE INDEX IF NOT EXISTS idx_urls_archive_archived_at ON urls_archive (archived_at);
+
+CREATE TABLE IF NOT EXISTS url_stats_archive (
+  url_id INTEGER PRIMARY KEY REFERENCES urls_archive (id) ON DELETE CASCADE,
+  click_count INTEGER NOT NULL DEFAULT 0,
+  last_clicked_at INTEGER DEFAULT NULL
+);
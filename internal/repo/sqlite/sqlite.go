@@ -0,0 +1,700 @@
+// Package sqlite implements service.Repository on an embedded SQLite file for
+// deployments that do not run PostgreSQL. The schema is created on startup.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"url-shortener-go/internal/models"
+	"url-shortener-go/internal/service"
+
+	_ "modernc.org/sqlite"
+)
+
+//go:embed schema.sql
+var schema string
+
+// schemaVersion is kept in PRAGMA user_version. Bump it together with an
+// idempotent change to schema.sql.
+const schemaVersion = 2
+
+type Repository struct {
+	db *sql.DB
+}
+
+// NewRepository opens (or creates) the database file at path and brings its
+// schema up to date.
+func NewRepository(path string) (*Repository, error) {
+	params := url.Values{}
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Set("_txlock", "immediate")
+
+	db, err := sql.Open("sqlite", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("error opening database: %w", err)
+	}
+
+	if err := bootstrap(context.Background(), db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error creating schema: %w", err)
+	}
+
+	return &Repository{db: db}, nil
+}
+
+func bootstrap(ctx context.Context, db *sql.DB) error {
+	var version int
+	if err := db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	if version > schemaVersion {
+		return fmt.Errorf("database schema version %d is newer than supported version %d", version, schemaVersion)
+	}
+
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		return err
+	}
+
+	_, err := db.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", schemaVersion))
+	return err
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func insertURL(ctx context.Context, q queryRower, url *models.URL) error {
+	createdAt := time.Now().UTC()
+	err := q.QueryRowContext(ctx, `
+		INSERT INTO urls (short_code, original_url, created_at, expires_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (short_code) DO NOTHING
+		RETURNING id
+	`,
+		url.ShortCode,
+		url.OriginalURL,
+		toUnix(createdAt),
+		toNullUnix(url.ExpiresAt),
+	).Scan(&url.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return service.ErrConflict
+	}
+	if err != nil {
+		return err
+	}
+	url.CreatedAt = createdAt
+
+	_, err = q.ExecContext(ctx, "INSERT INTO url_stats (url_id, click_count) VALUES (?, 0)", url.ID)
+	return err
+}
+
+func (r *Repository) Create(ctx context.Context, url *models.URL) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertURL(ctx, tx, url); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CreateBatch inserts all urls in one transaction. Short code conflicts are
+// reported per item; any other error aborts the whole batch.
+func (r *Repository) CreateBatch(ctx context.Context, urls []*models.URL) ([]error, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	errs := make([]error, len(urls))
+	for i, url := range urls {
+		err := insertURL(ctx, tx, url)
+		if errors.Is(err, service.ErrConflict) {
+			errs[i] = err
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return errs, nil
+}
+
+// Import stores a link from another system, keeping its short code,
+// timestamps and click count. An existing short code is a conflict unless
+// overwrite is set, in which case the existing link and its stats are
+// replaced and its click history is deleted.
+func (r *Repository) Import(ctx context.Context, details *models.URLDetails, overwrite bool) error {
+	onConflict := "DO NOTHING"
+	if overwrite {
+		onConflict = `DO UPDATE
+			SET
+				original_url = excluded.original_url,
+				status = excluded.status,
+				created_at = excluded.created_at,
+				expires_at = excluded.expires_at`
+	}
+
+	status := details.Status
+	if status == "" {
+		status = models.URLStatusActive
+	}
+	createdAt := details.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now().UTC()
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO urls (short_code, original_url, status, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (short_code) `+onConflict+`
+		RETURNING id
+	`,
+		details.ShortCode,
+		details.OriginalURL,
+		status,
+		toUnix(createdAt),
+		toNullUnix(details.ExpiresAt),
+	).Scan(&details.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return service.ErrConflict
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO url_stats (url_id, click_count, last_clicked_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT (url_id) DO UPDATE
+		SET click_count = excluded.click_count, last_clicked_at = excluded.last_clicked_at
+	`, details.ID, details.ClickCount, toNullUnix(details.LastClickedAt)); err != nil {
+		return err
+	}
+
+	// An overwritten link keeps its id, so the clicks of the link it replaced
+	// would otherwise show up in its stats.
+	if overwrite {
+		for _, query := range []string{
+			"DELETE FROM url_clicks WHERE url_id = ?",
+			"DELETE FROM url_clicks_hourly WHERE url_id = ?",
+		} {
+			if _, err := tx.ExecContext(ctx, query, details.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *Repository) GetByShortCode(ctx context.Context, shortCode string) (*models.URL, error) {
+	query := `
+		SELECT id, original_url, status, created_at, expires_at
+		FROM urls
+		WHERE short_code = ?
+	`
+
+	var (
+		url       models.URL
+		createdAt int64
+		expiresAt sql.NullInt64
+	)
+	url.ShortCode = shortCode
+
+	err := r.db.QueryRowContext(ctx, query, shortCode).Scan(
+		&url.ID,
+		&url.OriginalURL,
+		&url.Status,
+		&createdAt,
+		&expiresAt,
+	)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, service.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	url.CreatedAt = fromUnix(createdAt)
+	url.ExpiresAt = fromNullUnix(expiresAt)
+
+	switch {
+	case url.Status == models.URLStatusDeleted:
+		return nil, service.ErrDeleted
+	case url.Status == models.URLStatusDisabled:
+		return nil, service.ErrDisabled
+	case url.ExpiresAt != nil && !url.ExpiresAt.After(time.Now()):
+		return nil, service.ErrExpired
+	}
+
+	return &url, nil
+}
+
+func (r *Repository) GetByOriginalURL(ctx context.Context, originalURL string) (*models.URL, error) {
+	urls, err := r.GetByOriginalURLs(ctx, []string{originalURL})
+	if err != nil {
+		return nil, err
+	}
+
+	url, ok := urls[originalURL]
+	if !ok {
+		return nil, service.ErrNotFound
+	}
+	return url, nil
+}
+
+// GetByOriginalURLs is the bulk form of GetByOriginalURL. URLs without an
+// active link are absent from the result.
+func (r *Repository) GetByOriginalURLs(ctx context.Context, originalURLs []string) (map[string]*models.URL, error) {
+	urls := make(map[string]*models.URL)
+	if len(originalURLs) == 0 {
+		return urls, nil
+	}
+
+	args := make([]interface{}, 0, len(originalURLs)+1)
+	for _, originalURL := range originalURLs {
+		args = append(args, originalURL)
+	}
+	args = append(args, toUnix(time.Now()))
+
+	query := `
+		SELECT id, short_code, original_url, created_at, expires_at
+		FROM urls
+		WHERE original_url IN (?` + strings.Repeat(", ?", len(originalURLs)-1) + `)
+			AND status = 'active'
+			AND (expires_at IS NULL OR expires_at > ?)
+		ORDER BY created_at DESC, id DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			url       = &models.URL{Status: models.URLStatusActive}
+			createdAt int64
+			expiresAt sql.NullInt64
+		)
+		if err := rows.Scan(
+			&url.ID,
+			&url.ShortCode,
+			&url.OriginalURL,
+			&createdAt,
+			&expiresAt,
+		); err != nil {
+			return nil, err
+		}
+		// Rows are newest first, so the first one per URL wins.
+		if _, ok := urls[url.OriginalURL]; ok {
+			continue
+		}
+		url.CreatedAt = fromUnix(createdAt)
+		url.ExpiresAt = fromNullUnix(expiresAt)
+		urls[url.OriginalURL] = url
+	}
+
+	return urls, rows.Err()
+}
+
+const detailsQuery = `
+	SELECT u.id, u.short_code, u.original_url, u.status, u.created_at, u.expires_at,
+		COALESCE(s.click_count, 0), s.last_clicked_at
+	FROM urls u
+	LEFT JOIN url_stats s ON s.url_id = u.id
+`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanDetails(row scanner) (*models.URLDetails, error) {
+	var (
+		details       models.URLDetails
+		createdAt     int64
+		expiresAt     sql.NullInt64
+		lastClickedAt sql.NullInt64
+	)
+
+	if err := row.Scan(
+		&details.ID,
+		&details.ShortCode,
+		&details.OriginalURL,
+		&details.Status,
+		&createdAt,
+		&expiresAt,
+		&details.ClickCount,
+		&lastClickedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	details.CreatedAt = fromUnix(createdAt)
+	details.ExpiresAt = fromNullUnix(expiresAt)
+	details.LastClickedAt = fromNullUnix(lastClickedAt)
+	return &details, nil
+}
+
+func getDetails(ctx context.Context, q queryRower, shortCode string) (*models.URLDetails, error) {
+	details, err := scanDetails(q.QueryRowContext(ctx, detailsQuery+" WHERE u.short_code = ?", shortCode))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, service.ErrNotFound
+	}
+	return details, err
+}
+
+func (r *Repository) GetDetailsByShortCode(ctx context.Context, shortCode string) (*models.URLDetails, error) {
+	return getDetails(ctx, r.db, shortCode)
+}
+
+func (r *Repository) Update(ctx context.Context, shortCode string, opts models.UpdateURLOptions) (*models.URLDetails, error) {
+	query := `
+		UPDATE urls
+		SET
+			original_url = COALESCE(?, original_url),
+			expires_at = CASE
+				WHEN ? THEN NULL
+				ELSE COALESCE(?, expires_at)
+			END
+		WHERE short_code = ? AND status <> 'deleted'
+	`
+
+	var originalURL sql.NullString
+	if opts.OriginalURL != nil {
+		originalURL = sql.NullString{String: *opts.OriginalURL, Valid: true}
+	}
+
+	return r.updateAndGet(ctx, shortCode, query, originalURL, opts.ClearExpiresAt, toNullUnix(opts.ExpiresAt), shortCode)
+}
+
+func (r *Repository) SetStatus(ctx context.Context, shortCode string, status models.URLStatus) (*models.URLDetails, error) {
+	return r.updateAndGet(ctx, shortCode, "UPDATE urls SET status = ? WHERE short_code = ?", status, shortCode)
+}
+
+// updateAndGet runs an UPDATE on one link and returns the updated details in
+// the same transaction. No matched row means the link is not found.
+func (r *Repository) updateAndGet(ctx context.Context, shortCode, query string, args ...interface{}) (*models.URLDetails, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if affected == 0 {
+		return nil, service.ErrNotFound
+	}
+
+	details, err := getDetails(ctx, tx, shortCode)
+	if err != nil {
+		return nil, err
+	}
+
+	return details, tx.Commit()
+}
+
+func (r *Repository) List(ctx context.Context, filter models.ListURLsFilter) ([]models.URLDetails, error) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, condition)
+	}
+	now := toUnix(time.Now())
+
+	if filter.AfterID > 0 {
+		where("u.id < ?", filter.AfterID)
+	}
+	if filter.CreatedAfter != nil {
+		where("u.created_at >= ?", toUnix(*filter.CreatedAfter))
+	}
+	if filter.CreatedBefore != nil {
+		where("u.created_at < ?", toUnix(*filter.CreatedBefore))
+	}
+	if filter.Query != "" {
+		// LIKE is case-insensitive for ASCII in SQLite.
+		where(`u.original_url LIKE '%' || ? || '%' ESCAPE '\'`, likeEscaper.Replace(filter.Query))
+	}
+	switch filter.Status {
+	case models.ListStatusActive:
+		conditions = append(conditions, "u.status = 'active'")
+		where("(u.expires_at IS NULL OR u.expires_at > ?)", now)
+	case models.ListStatusExpired:
+		conditions = append(conditions, "u.status <> 'deleted'")
+		where("u.expires_at <= ?", now)
+	case models.ListStatusDisabled:
+		conditions = append(conditions, "u.status = 'disabled'")
+	case models.ListStatusDeleted:
+		conditions = append(conditions, "u.status = 'deleted'")
+	default:
+		conditions = append(conditions, "u.status <> 'deleted'")
+	}
+
+	query := detailsQuery
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += " ORDER BY u.id DESC LIMIT ?"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	urls := make([]models.URLDetails, 0, filter.Limit)
+	for rows.Next() {
+		details, err := scanDetails(rows)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, *details)
+	}
+
+	return urls, rows.Err()
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// ExportURLs calls fn for every link, including deleted ones, ordered by id.
+// SQLite steps through the result as it is read, so rows are not buffered.
+func (r *Repository) ExportURLs(ctx context.Context, fn func(*models.URLDetails) error) error {
+	rows, err := r.db.QueryContext(ctx, detailsQuery+" ORDER BY u.id")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		details, err := scanDetails(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(details); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// RecordClicks writes a flushed batch of clicks in one transaction: the
+// coalesced counts go to url_stats and url_clicks_hourly, and each event gets
+// a url_clicks row. Clicks on links deleted since the redirect are skipped.
+func (r *Repository) RecordClicks(ctx context.Context, counts []models.ClickCount, events []models.Click) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, count := range counts {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE url_stats
+			SET
+				click_count = click_count + ?,
+				last_clicked_at = MAX(COALESCE(last_clicked_at, 0), ?)
+			WHERE url_id = ?
+		`, count.Count, toUnix(count.LastClickedAt), count.URLID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO url_clicks_hourly (url_id, bucket, clicks)
+			SELECT ?1, ?2, ?3
+			WHERE EXISTS (SELECT 1 FROM urls WHERE id = ?1)
+			ON CONFLICT (url_id, bucket) DO UPDATE
+			SET clicks = clicks + excluded.clicks
+		`, count.URLID, toUnix(count.Hour), count.Count); err != nil {
+			return err
+		}
+	}
+
+	for _, event := range events {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO url_clicks (url_id, clicked_at, referrer, user_agent, ip_hash, request_id)
+			SELECT ?1, ?2, NULLIF(?3, ''), NULLIF(?4, ''), NULLIF(?5, ''), NULLIF(?6, '')
+			WHERE EXISTS (SELECT 1 FROM urls WHERE id = ?1)
+		`,
+			event.URLID,
+			toUnix(event.ClickedAt),
+			event.Referrer,
+			event.UserAgent,
+			event.IPHash,
+			event.RequestID,
+		); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetHourlyClicks returns the non-empty hourly click buckets of a link in
+// [from, to), oldest first.
+func (r *Repository) GetHourlyClicks(ctx context.Context, urlID int, from, to time.Time) ([]models.ClickBucket, error) {
+	query := `
+		SELECT bucket, clicks
+		FROM url_clicks_hourly
+		WHERE url_id = ? AND bucket >= ? AND bucket < ?
+		ORDER BY bucket
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, urlID, toUnix(from), toUnix(to))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var buckets []models.ClickBucket
+	for rows.Next() {
+		var (
+			bucket models.ClickBucket
+			start  int64
+		)
+		if err := rows.Scan(&start, &bucket.Clicks); err != nil {
+			return nil, err
+		}
+		bucket.Start = fromUnix(start)
+		buckets = append(buckets, bucket)
+	}
+
+	return buckets, rows.Err()
+}
+
+// DeleteExpiredURLs removes expired links; their stats and clicks go with them
+// through ON DELETE CASCADE.
+func (r *Repository) DeleteExpiredURLs(ctx context.Context, limit int, archive service.ArchiveFunc) (int64, error) {
+	if archive != nil {
+		return r.archiveExpiredURLs(ctx, limit, archive)
+	}
+
+	query := `
+		DELETE FROM urls
+		WHERE id IN (SELECT id FROM urls WHERE expires_at < ? ORDER BY id LIMIT ?)
+	`
+	result, err := r.db.ExecContext(ctx, query, toUnix(time.Now()), limit)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// archiveExpiredURLs reads, archives and deletes expired links in separate
+// steps: SQLite has one writer at a time, so holding a write transaction
+// while archive writes would block it. A link whose expiry is extended in
+// between is archived but kept, and archived again once it does expire.
+func (r *Repository) archiveExpiredURLs(ctx context.Context, limit int, archive service.ArchiveFunc) (int64, error) {
+	now := toUnix(time.Now())
+	rows, err := r.db.QueryContext(ctx, detailsQuery+" WHERE u.expires_at < ? ORDER BY u.id LIMIT ?", now, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		urls []models.URLDetails
+		args []interface{}
+	)
+	for rows.Next() {
+		details, err := scanDetails(rows)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		urls = append(urls, *details)
+		args = append(args, details.ID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(urls) == 0 {
+		return 0, nil
+	}
+
+	if err := archive(ctx, urls); err != nil {
+		return 0, fmt.Errorf("failed to archive expired links: %w", err)
+	}
+
+	query := `
+		DELETE FROM urls
+		WHERE id IN (?` + strings.Repeat(", ?", len(urls)-1) + `) AND expires_at < ?
+	`
+	result, err := r.db.ExecContext(ctx, query, append(args, now)...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Ping checks that the database file can be queried.
+func (r *Repository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
+
+// Stats reports the connection pool usage.
+func (r *Repository) Stats() sql.DBStats {
+	return r.db.Stats()
+}
+
+func (r *Repository) Close() error {
+	return r.db.Close()
+}
+
+func toUnix(t time.Time) int64 {
+	return t.UnixMicro()
+}
+
+func toNullUnix(t *time.Time) sql.NullInt64 {
+	if t == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: toUnix(*t), Valid: true}
+}
+
+func fromUnix(v int64) time.Time {
+	return time.UnixMicro(v).UTC()
+}
+
+func fromNullUnix(v sql.NullInt64) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+	return new(fromUnix(v.Int64))
+}
@@ -0,0 +1,70 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"url-shortener-go/internal/models"
+	"url-shortener-go/internal/service"
+	"url-shortener-go/internal/storagetest"
+)
+
+func newTestRepository(t *testing.T) *Repository {
+	t.Helper()
+
+	repo, err := NewRepository(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open repository: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	return repo
+}
+
+func TestRepository_Conformance(t *testing.T) {
+	storagetest.RunRepositoryTests(t, func(t *testing.T) service.Repository {
+		return newTestRepository(t)
+	})
+}
+
+func TestRepository_ImportAndReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	repo, err := NewRepository(path)
+	if err != nil {
+		t.Fatalf("failed to open repository: %v", err)
+	}
+	ctx := context.Background()
+
+	createdAt := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	details := &models.URLDetails{
+		URL:        models.URL{ShortCode: "legacy", OriginalURL: "https://example.com/old", CreatedAt: createdAt},
+		ClickCount: 42,
+	}
+	if err := repo.Import(ctx, details, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := repo.Import(ctx, details, false); !errors.Is(err, service.ErrConflict) {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+	details.OriginalURL = "https://example.com/new"
+	if err := repo.Import(ctx, details, true); err != nil {
+		t.Fatalf("unexpected overwrite error: %v", err)
+	}
+	repo.Close()
+
+	repo, err = NewRepository(path)
+	if err != nil {
+		t.Fatalf("failed to reopen repository: %v", err)
+	}
+	defer repo.Close()
+
+	got, err := repo.GetDetailsByShortCode(ctx, "legacy")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.OriginalURL != "https://example.com/new" || got.ClickCount != 42 || !got.CreatedAt.Equal(createdAt) {
+		t.Fatalf("unexpected imported link: %+v", got)
+	}
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"url-shortener-go/internal/models"
+)
+
+// GetArchivedURLs returns the archived links that used shortCode, most
+// recently archived first. A code can appear more than once if it was reused
+// after an earlier link with it expired.
+func (s *Service) GetArchivedURLs(ctx context.Context, shortCode string) ([]models.ArchivedURL, error) {
+	if s.archive == nil {
+		return nil, ErrArchiveDisabled
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.requestTimeout)
+	defer cancel()
+
+	urls, err := s.archive.GetArchivedURLs(ctx, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	if len(urls) == 0 {
+		return nil, ErrNotFound
+	}
+	return urls, nil
+}
+
+// PurgeArchive removes links archived longer ago than the archive retention
+// and reports how many it removed. It does nothing without an archive or a
+// retention.
+func (s *Service) PurgeArchive(ctx context.Context) (int64, error) {
+	if s.archive == nil || s.archiveRetention <= 0 {
+		return 0, nil
+	}
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+
+	return s.archive.PurgeArchivedURLs(ctx, time.Now().Add(-s.archiveRetention))
+}
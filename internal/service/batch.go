@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"url-shortener-go/internal/models"
+	"url-shortener-go/pkg/utils"
+)
+
+// MaxBatchSize caps the number of items accepted by CreateShortURLBatch.
+const MaxBatchSize = 1000
+
+// BatchResult is the outcome of one batch item. Exactly one of URL and Err is set.
+type BatchResult struct {
+	URL *models.URL
+	Err error
+}
+
+type pendingURL struct {
+	index     int
+	url       *models.URL
+	generated bool
+}
+
+// CreateShortURLBatch creates many links at once. Each item behaves like
+// CreateShortURL and fails on its own; the returned error is only set when
+// the whole batch could not be processed.
+func (s *Service) CreateShortURLBatch(ctx context.Context, items []models.CreateURLOptions) ([]BatchResult, error) {
+	if s.readOnly {
+		return nil, ErrReadOnly
+	}
+	results := make([]BatchResult, len(items))
+
+	ctx, cancel := context.WithTimeout(ctx, s.requestTimeout)
+	defer cancel()
+
+	// Items are resolved in a copy, keeping what each one submitted.
+	items = slices.Clone(items)
+	resolvedFrom := make([]string, len(items))
+	var lookups []string
+	for i, item := range items {
+		if IsReservedCode(item.CustomCode) {
+			results[i].Err = ErrReservedCode
+			continue
+		}
+		destination, resolved, err := s.checkDestination(ctx, item.OriginalURL)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		if resolved {
+			resolvedFrom[i] = item.OriginalURL
+			items[i].OriginalURL = destination
+		}
+		if item.CustomCode == "" {
+			lookups = append(lookups, destination)
+		}
+	}
+
+	existing := map[string]*models.URL{}
+	if len(lookups) > 0 {
+		found, err := s.repo.GetByOriginalURLs(ctx, lookups)
+		if err != nil {
+			return nil, err
+		}
+		existing = found
+	}
+
+	reused := make([]bool, len(items))
+	var pending []pendingURL
+	// Items without a custom code that share a destination reuse one new link.
+	leaders := make(map[string]int)
+	followers := make(map[int][]int)
+	now := time.Now()
+	for i, item := range items {
+		if results[i].Err != nil {
+			continue
+		}
+
+		generated := item.CustomCode == ""
+		if generated {
+			if url, ok := existing[item.OriginalURL]; ok {
+				results[i].URL = url
+				reused[i] = true
+				continue
+			}
+			if leader, ok := leaders[item.OriginalURL]; ok {
+				followers[leader] = append(followers[leader], i)
+				reused[i] = true
+				continue
+			}
+			leaders[item.OriginalURL] = i
+		}
+
+		shortCode := item.CustomCode
+		if generated {
+			shortCode = utils.GenerateShortCode(shortCodeLength)
+		}
+
+		var expiresAt *time.Time
+		if item.ExpiresIn > 0 {
+			expiresAt = new(now.Add(item.ExpiresIn))
+		}
+
+		pending = append(pending, pendingURL{
+			index: i,
+			url: &models.URL{
+				ShortCode:   shortCode,
+				OriginalURL: item.OriginalURL,
+				Status:      models.URLStatusActive,
+				CreatedAt:   now,
+				ExpiresAt:   expiresAt,
+			},
+			generated: generated,
+		})
+	}
+
+	for attempt := 1; len(pending) > 0; attempt++ {
+		urls := make([]*models.URL, len(pending))
+		for i, p := range pending {
+			urls[i] = p.url
+		}
+
+		errs, err := s.repo.CreateBatch(ctx, urls)
+		if err != nil {
+			return nil, err
+		}
+
+		var retry []pendingURL
+		for i, p := range pending {
+			switch {
+			case errs[i] == nil:
+				results[p.index].URL = p.url
+				s.cacheNewURL(ctx, p.url)
+			case errors.Is(errs[i], ErrConflict) && p.generated:
+				s.metrics.CodeConflict()
+				if attempt >= maxCodeAttempts {
+					results[p.index].Err = errs[i]
+					continue
+				}
+				p.url.ShortCode = utils.GenerateShortCode(shortCodeLength)
+				retry = append(retry, p)
+			default:
+				results[p.index].Err = errs[i]
+			}
+		}
+		pending = retry
+	}
+
+	for leader, indexes := range followers {
+		for _, i := range indexes {
+			results[i] = results[leader]
+		}
+	}
+
+	for i, from := range resolvedFrom {
+		if from != "" && results[i].URL != nil {
+			reported := *results[i].URL
+			reported.ResolvedFrom = from
+			results[i].URL = &reported
+		}
+	}
+
+	for i, result := range results {
+		if reused[i] && result.Err == nil {
+			s.metrics.LinkCreated(OutcomeExisting)
+		} else {
+			s.metrics.LinkCreated(createOutcome(result.Err))
+		}
+	}
+
+	return results, nil
+}
@@ -0,0 +1,268 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"url-shortener-go/internal/models"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	DefaultClickFlushInterval = time.Second
+	DefaultClickFlushSize     = 500
+
+	// maxPendingClickEvents caps buffered events, as a multiple of the flush
+	// size, while the database is slow or unavailable. Counts are still kept
+	// once the cap is hit; only the per-click details are dropped.
+	maxPendingClickEvents = 20
+
+	// maxFlushLinks caps how many redirect spans one flush span links to.
+	maxFlushLinks = 128
+)
+
+// clickAggregator buffers redirects in memory and writes them in batches:
+// counts are coalesced per url_id and hour and events are inserted together,
+// so a hot link costs one stats update per flush instead of one per redirect.
+type clickAggregator struct {
+	repo      Repository
+	unguarded Repository
+	interval  time.Duration
+	flushSize int
+	timeout   time.Duration
+	metrics   Metrics
+
+	mu     sync.Mutex
+	counts map[clickKey]*models.ClickCount
+	events []models.Click
+	links  []trace.Link
+
+	flushNow chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+type clickKey struct {
+	urlID int
+	hour  int64
+}
+
+func newClickAggregator(repo, unguarded Repository, interval time.Duration, flushSize int, timeout time.Duration, metrics Metrics) *clickAggregator {
+	if interval <= 0 {
+		interval = DefaultClickFlushInterval
+	}
+	if flushSize <= 0 {
+		flushSize = DefaultClickFlushSize
+	}
+
+	a := &clickAggregator{
+		repo:      repo,
+		unguarded: unguarded,
+		interval:  interval,
+		flushSize: flushSize,
+		timeout:   timeout,
+		metrics:   metrics,
+		counts:    make(map[clickKey]*models.ClickCount),
+		flushNow:  make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+// add buffers a click. origin is the span of the redirect, which the span of
+// the flush that writes the click links to.
+func (a *clickAggregator) add(origin trace.SpanContext, click models.Click) {
+	a.mu.Lock()
+	if origin.IsValid() && len(a.links) < maxFlushLinks {
+		a.links = append(a.links, trace.Link{SpanContext: origin})
+	}
+	a.addLocked(models.ClickCount{
+		URLID:         click.URLID,
+		Hour:          click.ClickedAt.UTC().Truncate(time.Hour),
+		Count:         1,
+		LastClickedAt: click.ClickedAt,
+	})
+	buffered := len(a.events) < a.flushSize*maxPendingClickEvents
+	if buffered {
+		a.events = append(a.events, click)
+	}
+	full := len(a.events) >= a.flushSize
+	a.mu.Unlock()
+
+	if !buffered {
+		a.metrics.ClickEventsDropped(1)
+	}
+
+	if full {
+		select {
+		case a.flushNow <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (a *clickAggregator) addLocked(count models.ClickCount) {
+	key := clickKey{urlID: count.URLID, hour: count.Hour.Unix()}
+	current, ok := a.counts[key]
+	if !ok {
+		a.counts[key] = &count
+		return
+	}
+	current.Count += count.Count
+	if count.LastClickedAt.After(current.LastClickedAt) {
+		current.LastClickedAt = count.LastClickedAt
+	}
+}
+
+func (a *clickAggregator) run() {
+	defer close(a.done)
+
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-a.flushNow:
+		case <-a.stop:
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), a.timeout)
+		_ = a.flush(ctx)
+		cancel()
+	}
+}
+
+// flush writes everything buffered so far. If the batch fails but its counts
+// can be written on their own, the events are retried in halves, so an event
+// the database rejects is dropped instead of failing every later flush.
+// Otherwise the batch is put back so the next flush retries it.
+func (a *clickAggregator) flush(ctx context.Context) error {
+	a.mu.Lock()
+	if len(a.counts) == 0 {
+		a.mu.Unlock()
+		return nil
+	}
+	counts := make([]models.ClickCount, 0, len(a.counts))
+	for _, count := range a.counts {
+		counts = append(counts, *count)
+	}
+	events := a.events
+	links := a.links
+	a.counts = make(map[clickKey]*models.ClickCount)
+	a.events = nil
+	a.links = nil
+	a.mu.Unlock()
+
+	ctx, span := tracer.Start(ctx, "clicks.flush",
+		trace.WithNewRoot(),
+		trace.WithLinks(links...),
+		trace.WithAttributes(
+			attribute.Int("clicks.counts", len(counts)),
+			attribute.Int("clicks.events", len(events)),
+		),
+	)
+	start := time.Now()
+	err := a.repo.RecordClicks(ctx, counts, events)
+	if err != nil && len(events) > 0 && rejected(err) && a.repo.RecordClicks(ctx, counts, nil) == nil {
+		dropped, unwritten := a.writeEvents(ctx, events)
+		span.SetAttributes(attribute.Int("clicks.events_dropped", dropped))
+		a.metrics.ClickEventsDropped(dropped)
+		counts, events, err = nil, unwritten, nil
+		if len(unwritten) > 0 {
+			err = ctx.Err()
+		}
+	}
+	a.metrics.JobFinished(JobClickFlush, time.Since(start), err)
+	endSpan(span, err)
+	if err == nil {
+		return nil
+	}
+
+	a.requeue(counts, events)
+	return err
+}
+
+// rejected reports whether a failed write may be down to the rows rather
+// than to the database being unavailable or the flush running out of time.
+// Only then is it worth writing the batch in parts.
+func rejected(err error) bool {
+	return !errors.Is(err, ErrUnavailable) &&
+		!errors.Is(err, context.Canceled) &&
+		!errors.Is(err, context.DeadlineExceeded)
+}
+
+// writeEvents writes events, splitting every batch the database rejects in
+// half. It returns how many single events were rejected and dropped, and the
+// events left unwritten because ctx ended. It only runs once the database
+// has accepted the counts, and bypasses the circuit breaker so that rejected
+// events are not taken for an outage.
+func (a *clickAggregator) writeEvents(ctx context.Context, events []models.Click) (int, []models.Click) {
+	if ctx.Err() != nil {
+		return 0, events
+	}
+	if a.unguarded.RecordClicks(ctx, nil, events) == nil {
+		return 0, nil
+	}
+	if len(events) == 1 {
+		if ctx.Err() != nil {
+			return 0, events
+		}
+		return 1, nil
+	}
+
+	mid := len(events) / 2
+	droppedFirst, unwrittenFirst := a.writeEvents(ctx, events[:mid])
+	droppedSecond, unwrittenSecond := a.writeEvents(ctx, events[mid:])
+	return droppedFirst + droppedSecond, append(unwrittenFirst, unwrittenSecond...)
+}
+
+// requeue puts unwritten clicks back in front of those buffered since. Events
+// beyond the buffer cap are dropped.
+func (a *clickAggregator) requeue(counts []models.ClickCount, events []models.Click) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, count := range counts {
+		a.addLocked(count)
+	}
+	room := a.flushSize*maxPendingClickEvents - len(a.events)
+	if room > len(events) {
+		room = len(events)
+	}
+	if room > 0 {
+		a.events = append(events[:room:room], a.events...)
+	}
+	if dropped := len(events) - max(room, 0); dropped > 0 {
+		a.metrics.ClickEventsDropped(dropped)
+	}
+}
+
+// clickField cleans a client-supplied value before it is buffered: NUL bytes
+// and invalid UTF-8, which Postgres text columns reject, are removed and the
+// value is cut to max bytes.
+func clickField(value string, max int) string {
+	return truncate(strings.ReplaceAll(value, "\x00", ""), max)
+}
+
+// close stops the background loop and flushes whatever is still buffered.
+func (a *clickAggregator) close(ctx context.Context) error {
+	a.stopOnce.Do(func() { close(a.stop) })
+
+	select {
+	case <-a.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return a.flush(ctx)
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"url-shortener-go/internal/models"
+)
+
+const (
+	defaultCodeFilterRetry = time.Second
+	maxCodeFilterRetry     = time.Minute
+)
+
+// errCodeFilterMissed fails a load during which a code could not be added.
+var errCodeFilterMissed = errors.New("code filter missed a code while loading")
+
+// LoadCodeFilter adds every stored short code to the code filter. Lookups
+// skip the filter until it has loaded once, so a partly filled filter never
+// rejects an existing code.
+func (s *Service) LoadCodeFilter(ctx context.Context) error {
+	if s.codeFilter == nil {
+		return nil
+	}
+
+	misses := s.codeFilterMisses.Load()
+	start := time.Now()
+	err := s.repo.ExportURLs(ctx, func(details *models.URLDetails) error {
+		return s.codeFilter.Add(ctx, details.ShortCode)
+	})
+	if err == nil && s.codeFilterMisses.Load() != misses {
+		err = errCodeFilterMissed
+	}
+	s.metrics.JobFinished(JobCodeFilterLoad, time.Since(start), err)
+	if err != nil {
+		return err
+	}
+
+	s.codeFilterReady.Store(true)
+	return nil
+}
+
+// mayExist reports whether shortCode can be in the repository. It fails open
+// while the filter is loading or unreachable, and reloads it if it was lost.
+func (s *Service) mayExist(ctx context.Context, shortCode string) bool {
+	if s.codeFilter == nil || !s.codeFilterReady.Load() {
+		return true
+	}
+	ok, err := s.codeFilter.MayContain(ctx, shortCode)
+	if errors.Is(err, ErrCodeFilterLost) {
+		s.reloadCodeFilter()
+	}
+	return ok || err != nil
+}
+
+// addCode records a new short code in the filter. If that fails the filter
+// would reject the code, so it is switched off and reloaded.
+func (s *Service) addCode(ctx context.Context, shortCode string) {
+	if s.codeFilter == nil {
+		return
+	}
+	if err := s.codeFilter.Add(ctx, shortCode); err != nil {
+		s.reloadCodeFilter()
+	}
+}
+
+// reloadCodeFilter switches the filter off and reloads it in the background,
+// retrying with backoff until a load succeeds or the service is closed. A
+// load that overlaps another failure is retried too, so the filter is never
+// served without a code that was committed.
+func (s *Service) reloadCodeFilter() {
+	s.codeFilterMisses.Add(1)
+	s.codeFilterReady.Store(false)
+	if !s.codeFilterReloading.CompareAndSwap(false, true) {
+		return
+	}
+
+	go func() {
+		for {
+			wait := s.codeFilterRetry
+			for s.LoadCodeFilter(s.background) != nil {
+				select {
+				case <-time.After(wait):
+				case <-s.background.Done():
+					s.codeFilterReloading.Store(false)
+					return
+				}
+				wait = min(2*wait, maxCodeFilterRetry)
+			}
+			s.codeFilterReloading.Store(false)
+			// A failure right after the load finished found the reload
+			// still running and left the filter off.
+			if s.codeFilterReady.Load() || !s.codeFilterReloading.CompareAndSwap(false, true) {
+				return
+			}
+		}
+	}()
+}
@@ -1,9 +1,52 @@
 package service
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	ErrNotFound   = errors.New("not found")
 	ErrConflict   = errors.New("conflict")
 	ErrInvalidURL = errors.New("invalid url")
+
+	// ErrReservedCode rejects a custom code that names an API route.
+	ErrReservedCode = errors.New("reserved code")
+
+	// Destinations rejected by the DestinationPolicy. They wrap ErrInvalidURL.
+	ErrSchemeNotAllowed = fmt.Errorf("scheme not allowed: %w", ErrInvalidURL)
+	ErrDomainNotAllowed = fmt.Errorf("domain not allowed: %w", ErrInvalidURL)
+	ErrDomainBlocked    = fmt.Errorf("domain blocked: %w", ErrInvalidURL)
+	ErrPrivateAddress   = fmt.Errorf("private address: %w", ErrInvalidURL)
+
+	// ErrSelfLink rejects a destination on our own domain that is not, or
+	// may not be resolved to, a servable short link. It wraps ErrInvalidURL.
+	ErrSelfLink = fmt.Errorf("self link: %w", ErrInvalidURL)
+
+	// Links that exist but can no longer be served. They wrap ErrNotFound so
+	// callers that only care about "not servable" keep working.
+	ErrExpired  = fmt.Errorf("expired: %w", ErrNotFound)
+	ErrDisabled = fmt.Errorf("disabled: %w", ErrNotFound)
+	ErrDeleted  = fmt.Errorf("deleted: %w", ErrNotFound)
+
+	ErrInvalidExpiry = errors.New("invalid expiry")
+
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidFilter = errors.New("invalid filter")
+
+	ErrInvalidStatsRange = errors.New("invalid stats range")
+
+	ErrArchiveDisabled = errors.New("archive disabled")
+
+	// ErrInvalidClicks is returned by RecordClicks when the database rejects
+	// the rows themselves, such as text it cannot store. It is not an outage.
+	ErrInvalidClicks = errors.New("invalid clicks")
+
+	// ErrCodeFilterLost is returned by a CodeFilter whose contents are gone,
+	// for example after Redis restarted empty. The filter is then reloaded.
+	ErrCodeFilterLost = errors.New("code filter lost")
+
+	// ErrUnavailable means storage is down or its circuit breaker is open.
+	ErrUnavailable = errors.New("unavailable")
+	ErrReadOnly    = fmt.Errorf("read-only mode: %w", ErrUnavailable)
 )
@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"url-shortener-go/internal/breaker"
+	"url-shortener-go/internal/models"
+)
+
+// isOutage reports whether err means a backend is failing, as opposed to a
+// normal answer such as "not found" or a caller that went away.
+func isOutage(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	for _, expected := range []error{
+		ErrNotFound, ErrConflict, ErrInvalidURL, ErrInvalidExpiry,
+		ErrInvalidCursor, ErrInvalidFilter, ErrInvalidStatsRange, ErrInvalidClicks,
+	} {
+		if errors.Is(err, expected) {
+			return false
+		}
+	}
+	return true
+}
+
+// allow checks the breaker before a call; a nil breaker always allows.
+func allow(b *breaker.Breaker) error {
+	if b == nil {
+		return nil
+	}
+	if err := b.Allow(); err != nil {
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	return nil
+}
+
+// finish records the outcome of an allowed call and marks outages as
+// ErrUnavailable.
+func finish(b *breaker.Breaker, err error) error {
+	outage := isOutage(err)
+	if b != nil {
+		b.Done(outage)
+	}
+	if outage {
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	return err
+}
+
+func guard[T any](b *breaker.Breaker, fn func() (T, error)) (T, error) {
+	if err := allow(b); err != nil {
+		var zero T
+		return zero, err
+	}
+	value, err := fn()
+	return value, finish(b, err)
+}
+
+func guardErr(b *breaker.Breaker, fn func() error) error {
+	if err := allow(b); err != nil {
+		return err
+	}
+	return finish(b, fn())
+}
+
+// guardedRepo fails fast with ErrUnavailable while the repository is down
+// instead of letting every request wait for its timeout.
+type guardedRepo struct {
+	repo    Repository
+	breaker *breaker.Breaker
+}
+
+func (g *guardedRepo) Create(ctx context.Context, url *models.URL) error {
+	return guardErr(g.breaker, func() error { return g.repo.Create(ctx, url) })
+}
+
+func (g *guardedRepo) CreateBatch(ctx context.Context, urls []*models.URL) ([]error, error) {
+	return guard(g.breaker, func() ([]error, error) { return g.repo.CreateBatch(ctx, urls) })
+}
+
+func (g *guardedRepo) GetByShortCode(ctx context.Context, shortCode string) (*models.URL, error) {
+	return guard(g.breaker, func() (*models.URL, error) { return g.repo.GetByShortCode(ctx, shortCode) })
+}
+
+func (g *guardedRepo) GetByOriginalURL(ctx context.Context, originalURL string) (*models.URL, error) {
+	return guard(g.breaker, func() (*models.URL, error) { return g.repo.GetByOriginalURL(ctx, originalURL) })
+}
+
+func (g *guardedRepo) GetByOriginalURLs(ctx context.Context, originalURLs []string) (map[string]*models.URL, error) {
+	return guard(g.breaker, func() (map[string]*models.URL, error) { return g.repo.GetByOriginalURLs(ctx, originalURLs) })
+}
+
+func (g *guardedRepo) GetDetailsByShortCode(ctx context.Context, shortCode string) (*models.URLDetails, error) {
+	return guard(g.breaker, func() (*models.URLDetails, error) { return g.repo.GetDetailsByShortCode(ctx, shortCode) })
+}
+
+func (g *guardedRepo) List(ctx context.Context, filter models.ListURLsFilter) ([]models.URLDetails, error) {
+	return guard(g.breaker, func() ([]models.URLDetails, error) { return g.repo.List(ctx, filter) })
+}
+
+func (g *guardedRepo) Update(ctx context.Context, shortCode string, opts models.UpdateURLOptions) (*models.URLDetails, error) {
+	return guard(g.breaker, func() (*models.URLDetails, error) { return g.repo.Update(ctx, shortCode, opts) })
+}
+
+func (g *guardedRepo) SetStatus(ctx context.Context, shortCode string, status models.URLStatus) (*models.URLDetails, error) {
+	return guard(g.breaker, func() (*models.URLDetails, error) { return g.repo.SetStatus(ctx, shortCode, status) })
+}
+
+// ExportURLs does not count errors returned by fn, such as a client that
+// stopped reading, against the repository.
+func (g *guardedRepo) ExportURLs(ctx context.Context, fn func(*models.URLDetails) error) error {
+	if err := allow(g.breaker); err != nil {
+		return err
+	}
+
+	var fnErr error
+	err := g.repo.ExportURLs(ctx, func(details *models.URLDetails) error {
+		fnErr = fn(details)
+		return fnErr
+	})
+	if fnErr != nil {
+		if g.breaker != nil {
+			g.breaker.Done(false)
+		}
+		return err
+	}
+	return finish(g.breaker, err)
+}
+
+func (g *guardedRepo) RecordClicks(ctx context.Context, counts []models.ClickCount, events []models.Click) error {
+	return guardErr(g.breaker, func() error { return g.repo.RecordClicks(ctx, counts, events) })
+}
+
+func (g *guardedRepo) GetHourlyClicks(ctx context.Context, urlID int, from, to time.Time) ([]models.ClickBucket, error) {
+	return guard(g.breaker, func() ([]models.ClickBucket, error) { return g.repo.GetHourlyClicks(ctx, urlID, from, to) })
+}
+
+func (g *guardedRepo) DeleteExpiredURLs(ctx context.Context, limit int, archive ArchiveFunc) (int64, error) {
+	return guard(g.breaker, func() (int64, error) { return g.repo.DeleteExpiredURLs(ctx, limit, archive) })
+}
+
+// guardedCache bounds each cache call by timeout and skips the cache while
+// it is down, so a slow cache costs at most timeout per request.
+type guardedCache struct {
+	cache   Cache
+	breaker *breaker.Breaker
+	timeout time.Duration
+}
+
+func (g *guardedCache) Set(ctx context.Context, key string, value *models.URL, expiration time.Duration) error {
+	return guardErr(g.breaker, func() error {
+		ctx, cancel := g.withTimeout(ctx)
+		defer cancel()
+		return g.cache.Set(ctx, key, value, expiration)
+	})
+}
+
+func (g *guardedCache) Get(ctx context.Context, key string) (*models.URL, error) {
+	return guard(g.breaker, func() (*models.URL, error) {
+		ctx, cancel := g.withTimeout(ctx)
+		defer cancel()
+		return g.cache.Get(ctx, key)
+	})
+}
+
+func (g *guardedCache) GetWithTTL(ctx context.Context, key string) (*models.URL, time.Duration, error) {
+	var ttl time.Duration
+	url, err := guard(g.breaker, func() (*models.URL, error) {
+		ctx, cancel := g.withTimeout(ctx)
+		defer cancel()
+
+		cache, ok := g.cache.(TTLCache)
+		if !ok {
+			return g.cache.Get(ctx, key)
+		}
+		url, remaining, err := cache.GetWithTTL(ctx, key)
+		ttl = remaining
+		return url, err
+	})
+	return url, ttl, err
+}
+
+func (g *guardedCache) Delete(ctx context.Context, key string) error {
+	return guardErr(g.breaker, func() error {
+		ctx, cancel := g.withTimeout(ctx)
+		defer cancel()
+		return g.cache.Delete(ctx, key)
+	})
+}
+
+// DeletePrefix walks the whole cache, so it is not bound by timeout.
+func (g *guardedCache) DeletePrefix(ctx context.Context, prefix string) error {
+	return guardErr(g.breaker, func() error { return g.cache.DeletePrefix(ctx, prefix) })
+}
+
+func (g *guardedCache) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if g.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, g.timeout)
+}
+
+// guardedFilter applies the cache's breaker and timeout to the code filter,
+// which may live in the same Redis.
+type guardedFilter struct {
+	filter CodeFilter
+	cache  *guardedCache
+}
+
+func (g *guardedFilter) Add(ctx context.Context, code string) error {
+	return guardErr(g.cache.breaker, func() error {
+		ctx, cancel := g.cache.withTimeout(ctx)
+		defer cancel()
+		return g.filter.Add(ctx, code)
+	})
+}
+
+func (g *guardedFilter) MayContain(ctx context.Context, code string) (bool, error) {
+	return guard(g.cache.breaker, func() (bool, error) {
+		ctx, cancel := g.cache.withTimeout(ctx)
+		defer cancel()
+		return g.filter.MayContain(ctx, code)
+	})
+}
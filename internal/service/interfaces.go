@@ -9,13 +9,57 @@ import (
 
 type Repository interface {
 	Create(ctx context.Context, url *models.URL) error
+	CreateBatch(ctx context.Context, urls []*models.URL) ([]error, error)
 	GetByShortCode(ctx context.Context, shortCode string) (*models.URL, error)
 	GetByOriginalURL(ctx context.Context, originalURL string) (*models.URL, error)
-	IncrementClickCount(ctx context.Context, urlID int) error
-	DeleteExpiredURLs(ctx context.Context) error
+	GetByOriginalURLs(ctx context.Context, originalURLs []string) (map[string]*models.URL, error)
+	GetDetailsByShortCode(ctx context.Context, shortCode string) (*models.URLDetails, error)
+	List(ctx context.Context, filter models.ListURLsFilter) ([]models.URLDetails, error)
+	Update(ctx context.Context, shortCode string, opts models.UpdateURLOptions) (*models.URLDetails, error)
+	SetStatus(ctx context.Context, shortCode string, status models.URLStatus) (*models.URLDetails, error)
+	ExportURLs(ctx context.Context, fn func(*models.URLDetails) error) error
+	RecordClicks(ctx context.Context, counts []models.ClickCount, events []models.Click) error
+	GetHourlyClicks(ctx context.Context, urlID int, from, to time.Time) ([]models.ClickBucket, error)
+	// DeleteExpiredURLs removes up to limit expired links, with their stats
+	// and clicks, and reports how many it removed. A non-nil archive is
+	// called with the links before they are removed; if it fails, they are
+	// kept.
+	DeleteExpiredURLs(ctx context.Context, limit int, archive ArchiveFunc) (int64, error)
+}
+
+// ArchiveFunc receives expired links before they are deleted.
+type ArchiveFunc func(ctx context.Context, urls []models.URLDetails) error
+
+// Archive keeps a record of expired links after cleanup deletes them.
+type Archive interface {
+	// ArchiveURLs stores urls. Storing a link that is already archived
+	// replaces it, so a retried cleanup batch is not recorded twice.
+	ArchiveURLs(ctx context.Context, urls []models.URLDetails) error
+	// GetArchivedURLs returns the archived links that used shortCode,
+	// most recently archived first.
+	GetArchivedURLs(ctx context.Context, shortCode string) ([]models.ArchivedURL, error)
+	// PurgeArchivedURLs removes links archived before cutoff and reports how
+	// many it removed.
+	PurgeArchivedURLs(ctx context.Context, cutoff time.Time) (int64, error)
 }
 
 type Cache interface {
 	Set(ctx context.Context, key string, value *models.URL, expiration time.Duration) error
 	Get(ctx context.Context, key string) (*models.URL, error)
+	Delete(ctx context.Context, key string) error
+	DeletePrefix(ctx context.Context, prefix string) error
+}
+
+// TTLCache is implemented by caches that can report how long an entry has
+// left, which lets hot links be reloaded before they expire.
+type TTLCache interface {
+	GetWithTTL(ctx context.Context, key string) (*models.URL, time.Duration, error)
+}
+
+// CodeFilter tells whether a short code may exist. It may report codes that
+// were never added, but never misses one that was. MayContain returns
+// ErrCodeFilterLost when the added codes are no longer there.
+type CodeFilter interface {
+	Add(ctx context.Context, code string) error
+	MayContain(ctx context.Context, code string) (bool, error)
 }
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"url-shortener-go/internal/models"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// earlyRefreshFraction sets the early refresh window to the last tenth of the
+// cache TTL.
+const earlyRefreshFraction = 10
+
+// getCached reads a link from the cache along with its remaining TTL, or zero
+// when the cache cannot report it.
+func (s *Service) getCached(ctx context.Context, shortCode string) (*models.URL, time.Duration, error) {
+	if cache, ok := s.cache.(TTLCache); ok {
+		return cache.GetWithTTL(ctx, CacheKey(shortCode))
+	}
+	url, err := s.cache.Get(ctx, CacheKey(shortCode))
+	return url, 0, err
+}
+
+// loadURL reads a link from the repository and caches it. Concurrent misses
+// for the same code share one query; each caller still gives up when its own
+// context is done.
+func (s *Service) loadURL(ctx context.Context, shortCode string) (*models.URL, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result := <-s.fetchURL(ctx, shortCode):
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		url := *result.Val.(*models.URL)
+		return &url, nil
+	}
+}
+
+// refreshURL reloads a cached link in the background. If a load for the code
+// is already running, it is reused.
+func (s *Service) refreshURL(ctx context.Context, shortCode string) {
+	s.fetchURL(ctx, shortCode)
+}
+
+// fetchURL starts or joins the shared load for shortCode. The load runs with
+// its own timeout so a caller that goes away does not fail the others.
+func (s *Service) fetchURL(ctx context.Context, shortCode string) <-chan singleflight.Result {
+	ctx = context.WithoutCancel(ctx)
+	return s.lookups.DoChan(shortCode, func() (any, error) {
+		ctx, cancel := context.WithTimeout(ctx, s.requestTimeout)
+		defer cancel()
+
+		url, err := s.repo.GetByShortCode(ctx, shortCode)
+		if err != nil {
+			if isUnknown(err) && s.negativeCacheTTL > 0 {
+				s.cache.Set(ctx, CacheKey(shortCode), &models.URL{ShortCode: shortCode}, s.negativeCacheTTL)
+			}
+			return nil, err
+		}
+		s.cacheURL(ctx, url)
+		return url, nil
+	})
+}
+
+// shouldRefresh decides whether a cache hit with ttl left should also reload
+// the link. The chance grows from zero at the start of the refresh window to
+// one at expiry, so a hot link is reloaded once shortly before it expires
+// instead of by every request that misses right after. Links that expire
+// within the window are left alone, since reloading would not extend them.
+func (s *Service) shouldRefresh(url *models.URL, ttl time.Duration) bool {
+	window := s.cacheTTL / earlyRefreshFraction
+	if ttl <= 0 || ttl >= window {
+		return false
+	}
+	if url.ExpiresAt != nil && time.Until(*url.ExpiresAt) < window {
+		return false
+	}
+	return s.refreshRand() >= float64(ttl)/float64(window)
+}
+
+// IsMissing reports whether a cached entry is a negative one, stored for a
+// code the repository does not have. Real links always have an ID. Cache
+// metrics count such entries as misses.
+func IsMissing(url *models.URL) bool {
+	return url.ID == 0
+}
+
+// isUnknown reports whether err means the code was never stored, as opposed
+// to a link that exists but is expired, disabled or deleted.
+func isUnknown(err error) bool {
+	return errors.Is(err, ErrNotFound) &&
+		!errors.Is(err, ErrExpired) && !errors.Is(err, ErrDisabled) && !errors.Is(err, ErrDeleted)
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"errors"
+	"time"
+)
+
+// Outcomes reported to Metrics.LinkCreated.
+const (
+	OutcomeCreated     = "created"
+	OutcomeExisting    = "existing"
+	OutcomeConflict    = "conflict"
+	OutcomeInvalid     = "invalid"
+	OutcomeUnavailable = "unavailable"
+	OutcomeError       = "error"
+)
+
+// Background jobs reported to Metrics.JobFinished.
+const (
+	JobClickFlush     = "click_flush"
+	JobCodeFilterLoad = "code_filter_load"
+)
+
+// Metrics receives counts and timings from the service.
+type Metrics interface {
+	// LinkCreated is called once per link a create request asked for.
+	LinkCreated(outcome string)
+	// CodeConflict is called each time a generated short code turns out to be
+	// taken.
+	CodeConflict()
+	JobFinished(job string, duration time.Duration, err error)
+	// ClickEventsDropped is called with click events that were not stored:
+	// rejected by the database, or over the buffer cap while it was down.
+	// Their counts are kept.
+	ClickEventsDropped(n int)
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) LinkCreated(string)                       {}
+func (nopMetrics) CodeConflict()                            {}
+func (nopMetrics) JobFinished(string, time.Duration, error) {}
+func (nopMetrics) ClickEventsDropped(int)                   {}
+
+func createOutcome(err error) string {
+	switch {
+	case err == nil:
+		return OutcomeCreated
+	case errors.Is(err, ErrConflict):
+		return OutcomeConflict
+	case errors.Is(err, ErrInvalidURL), errors.Is(err, ErrInvalidExpiry):
+		return OutcomeInvalid
+	case errors.Is(err, ErrUnavailable):
+		return OutcomeUnavailable
+	default:
+		return OutcomeError
+	}
+}
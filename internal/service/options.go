@@ -0,0 +1,113 @@
+package service
+
+import "time"
+
+// Option customizes a Service.
+type Option func(*Service)
+
+// WithIPHashSalt sets the salt mixed into client IPs before they are hashed
+// and stored with click events.
+func WithIPHashSalt(salt string) Option {
+	return func(s *Service) {
+		s.ipHashSalt = salt
+	}
+}
+
+// WithClickFlushInterval sets how often buffered clicks are written.
+func WithClickFlushInterval(interval time.Duration) Option {
+	return func(s *Service) {
+		s.clickFlushInterval = interval
+	}
+}
+
+// WithClickFlushSize sets how many buffered click events trigger an early flush.
+func WithClickFlushSize(size int) Option {
+	return func(s *Service) {
+		s.clickFlushSize = size
+	}
+}
+
+// WithNegativeCacheTTL caches lookups of unknown short codes for ttl, so
+// repeated requests for them do not reach the repository. Zero disables it.
+func WithNegativeCacheTTL(ttl time.Duration) Option {
+	return func(s *Service) {
+		s.negativeCacheTTL = ttl
+	}
+}
+
+// WithCodeFilter rejects lookups of short codes the filter has never seen.
+// The filter is consulted once LoadCodeFilter has filled it.
+func WithCodeFilter(filter CodeFilter) Option {
+	return func(s *Service) {
+		s.codeFilter = filter
+	}
+}
+
+// WithCircuitBreakers stops calling the repository or the cache for cooldown
+// after threshold consecutive failures. Zero threshold disables them.
+func WithCircuitBreakers(threshold int, cooldown time.Duration) Option {
+	return func(s *Service) {
+		s.breakerThreshold = threshold
+		s.breakerCooldown = cooldown
+	}
+}
+
+// WithCacheTimeout bounds each cache call, so a slow cache cannot use up the
+// request timeout. Zero leaves cache calls bound by the request timeout only.
+func WithCacheTimeout(timeout time.Duration) Option {
+	return func(s *Service) {
+		s.cacheTimeout = timeout
+	}
+}
+
+// WithReadOnly rejects every write with ErrReadOnly while redirects and reads
+// keep working.
+func WithReadOnly(readOnly bool) Option {
+	return func(s *Service) {
+		s.readOnly = readOnly
+	}
+}
+
+// WithCleanupBatchSize sets how many expired links CleanupExpiredURLs
+// deletes per statement.
+func WithCleanupBatchSize(size int) Option {
+	return func(s *Service) {
+		s.cleanupBatchSize = size
+	}
+}
+
+// WithArchive makes cleanup archive expired links before deleting them, and
+// purge archived links older than retention. Zero retention keeps them
+// forever.
+func WithArchive(archive Archive, retention time.Duration) Option {
+	return func(s *Service) {
+		s.archive = archive
+		s.archiveRetention = retention
+	}
+}
+
+// WithDestinationPolicy sets which URLs new and edited links may point to.
+// Without it, http and https destinations on any public host are allowed.
+func WithDestinationPolicy(policy DestinationPolicy) Option {
+	return func(s *Service) {
+		s.policy = policy
+	}
+}
+
+// WithSelfLinks sets how destinations on our own domain are handled: the
+// base URL's host and aliasDomains. By default every such destination is
+// refused; with resolve, one naming our short link is replaced by a copy of
+// that link's target.
+func WithSelfLinks(aliasDomains []string, resolve bool) Option {
+	return func(s *Service) {
+		s.aliasDomains = aliasDomains
+		s.resolveSelfLinks = resolve
+	}
+}
+
+// WithMetrics reports link creation outcomes and background job timings to m.
+func WithMetrics(m Metrics) Option {
+	return func(s *Service) {
+		s.metrics = m
+	}
+}
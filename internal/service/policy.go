@@ -0,0 +1,157 @@
+package service
+
+import (
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DefaultSchemes are the destination schemes allowed when a policy lists none.
+var DefaultSchemes = []string{"http", "https"}
+
+// DestinationPolicy decides which URLs links may redirect to. The zero value
+// allows http and https destinations on any public host.
+type DestinationPolicy struct {
+	// Schemes lists the allowed URL schemes. Empty means DefaultSchemes.
+	Schemes []string
+	// AllowedDomains, if set, restricts destinations to hosts matching one of
+	// them. "*.example.com" matches every subdomain of example.com but not
+	// example.com itself, and "*" matches any host.
+	AllowedDomains []string
+	// BlockedDomains rejects hosts matching any of them, even allowed ones.
+	BlockedDomains []string
+	// AllowPrivateAddresses accepts localhost and loopback, private,
+	// link-local and unspecified IP literals.
+	AllowPrivateAddresses bool
+}
+
+// Check reports why rawURL may not be used as a destination, if anything.
+// Every error it returns wraps ErrInvalidURL.
+func (p DestinationPolicy) Check(rawURL string) error {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil || parsedURL.Scheme == "" {
+		return ErrInvalidURL
+	}
+
+	schemes := p.Schemes
+	if len(schemes) == 0 {
+		schemes = DefaultSchemes
+	}
+	if !slices.ContainsFunc(schemes, func(scheme string) bool {
+		return strings.EqualFold(scheme, parsedURL.Scheme)
+	}) {
+		return ErrSchemeNotAllowed
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(parsedURL.Hostname()), ".")
+	if host == "" {
+		return ErrInvalidURL
+	}
+
+	if matchesAnyDomain(host, p.BlockedDomains) {
+		return ErrDomainBlocked
+	}
+	if len(p.AllowedDomains) > 0 && !matchesAnyDomain(host, p.AllowedDomains) {
+		return ErrDomainNotAllowed
+	}
+	if !p.AllowPrivateAddresses && isPrivateHost(host) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+func matchesAnyDomain(host string, patterns []string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(pattern)), ".")
+		switch {
+		case pattern == "*":
+			return true
+		case strings.HasPrefix(pattern, "*."):
+			if strings.HasSuffix(host, pattern[1:]) {
+				return true
+			}
+		case host == pattern:
+			return true
+		}
+	}
+	return false
+}
+
+// isPrivateHost reports whether host names this machine or a private network
+// outright, without a DNS lookup: localhost, or an IP literal in a loopback,
+// private, link-local or unspecified range.
+func isPrivateHost(host string) bool {
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+
+	addr, ok := parseIPLiteral(host)
+	if !ok {
+		return false
+	}
+	addr = addr.Unmap()
+	return addr.IsLoopback() ||
+		addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() ||
+		addr.IsUnspecified()
+}
+
+// parseIPLiteral parses host as an IP address, including the shorthand IPv4
+// forms browsers accept, such as "127.1", "2130706433" and "0x7f.0.0.1".
+func parseIPLiteral(host string) (netip.Addr, bool) {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return addr, true
+	}
+
+	parts := strings.Split(host, ".")
+	if len(parts) > 4 {
+		return netip.Addr{}, false
+	}
+
+	var value uint64
+	for i, part := range parts {
+		n, ok := parseIPv4Part(part)
+		if !ok {
+			return netip.Addr{}, false
+		}
+		if i < len(parts)-1 {
+			if n > 255 {
+				return netip.Addr{}, false
+			}
+			value |= n << (8 * (3 - i))
+			continue
+		}
+		// The last part fills the remaining bytes.
+		if n >= 1<<(8*(4-i)) {
+			return netip.Addr{}, false
+		}
+		value |= n
+	}
+
+	return netip.AddrFrom4([4]byte{byte(value >> 24), byte(value >> 16), byte(value >> 8), byte(value)}), true
+}
+
+// parseIPv4Part parses one dot-separated IPv4 part in decimal, octal (leading
+// 0) or hex (leading 0x).
+func parseIPv4Part(part string) (uint64, bool) {
+	base := 10
+	switch {
+	case strings.HasPrefix(part, "0x"):
+		part, base = part[2:], 16
+		if part == "" {
+			return 0, true
+		}
+	case len(part) > 1 && part[0] == '0':
+		part, base = part[1:], 8
+	}
+	if part == "" || strings.ContainsAny(part, "+-") {
+		return 0, false
+	}
+
+	n, err := strconv.ParseUint(part, base, 32)
+	return n, err == nil
+}
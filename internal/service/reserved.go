@@ -0,0 +1,21 @@
+package service
+
+// reservedCodes are names the router serves itself at /{name} or
+// /v1/{name}. A link with one of them as its code could never be reached.
+var reservedCodes = map[string]bool{
+	"v1":      true,
+	"swagger": true,
+	"metrics": true,
+	"shorten": true,
+	"urls":    true,
+	"export":  true,
+	"cache":   true,
+	"archive": true,
+	"health":  true,
+}
+
+// IsReservedCode reports whether code is taken by an API route and so cannot
+// be used as a custom short code.
+func IsReservedCode(code string) bool {
+	return reservedCodes[code]
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+
+	"url-shortener-go/internal/models"
+)
+
+// maxSelfLinkHops bounds how many of our own short links a destination is
+// followed through. New links never store one, but older links may.
+const maxSelfLinkHops = 5
+
+// selfLinks recognizes destinations served by this shortener.
+type selfLinks struct {
+	hosts    map[string]bool
+	basePath string
+	resolve  bool
+}
+
+func newSelfLinks(baseURL string, aliases []string, resolve bool) selfLinks {
+	links := selfLinks{hosts: make(map[string]bool), resolve: resolve}
+	if parsed, err := url.Parse(baseURL); err == nil {
+		if host := normalizeHost(parsed.Hostname()); host != "" {
+			links.hosts[host] = true
+		}
+		links.basePath = strings.TrimSuffix(parsed.Path, "/")
+	}
+	for _, alias := range aliases {
+		if host := normalizeHost(alias); host != "" {
+			links.hosts[host] = true
+		}
+	}
+	return links
+}
+
+// shortCode reports whether rawURL is on one of our hosts and, if it is a
+// redirect URL, the code it redirects through. Ports are ignored.
+func (l selfLinks) shortCode(rawURL string) (string, bool) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || !l.hosts[normalizeHost(parsed.Hostname())] {
+		return "", false
+	}
+
+	path, ok := strings.CutPrefix(parsed.Path, l.basePath+"/")
+	if !ok {
+		return "", true
+	}
+	// Redirects are served at /{code} and /v1/{code}.
+	if code, ok := strings.CutPrefix(path, "v1/"); ok {
+		path = code
+	}
+	if path == "" || strings.Contains(path, "/") {
+		return "", true
+	}
+	return path, true
+}
+
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
+
+// checkDestination returns the destination a new or edited link should
+// store for rawURL, and whether it differs from rawURL. A destination on our
+// own hosts fails with ErrSelfLink unless self links are resolved; then it is
+// replaced by the final target of the short link it names, so links never
+// redirect through each other, and fails only if it names no servable link.
+// The destination policy applies to the final target.
+//
+// Resolving copies the target: disabling, deleting or expiring the link it
+// came from later does not affect the new link.
+func (s *Service) checkDestination(ctx context.Context, rawURL string) (string, bool, error) {
+	destination := rawURL
+	for hop := 0; ; hop++ {
+		code, self := s.selfLinks.shortCode(destination)
+		if !self {
+			break
+		}
+		if !s.selfLinks.resolve || code == "" || hop == maxSelfLinkHops {
+			return "", false, ErrSelfLink
+		}
+
+		target, err := s.lookupLink(ctx, code)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return "", false, ErrSelfLink
+			}
+			return "", false, err
+		}
+		destination = target.OriginalURL
+	}
+
+	if err := s.policy.Check(destination); err != nil {
+		return "", false, err
+	}
+	return destination, destination != rawURL, nil
+}
+
+// lookupLink returns the servable link for shortCode, from the cache when it
+// has it. It counts no click.
+func (s *Service) lookupLink(ctx context.Context, shortCode string) (*models.URL, error) {
+	url, _, err := s.getCached(ctx, shortCode)
+	if err != nil {
+		url, err = s.loadURL(ctx, shortCode)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if IsMissing(url) {
+		return nil, ErrNotFound
+	}
+	if err := checkServable(url); err != nil {
+		return nil, err
+	}
+	return url, nil
+}
+
+// ImportCheck checks the destinations of links written without the service,
+// as cmd/import does. It applies the destination policy and rejects every
+// destination on our own hosts with ErrSelfLink, since the links those name
+// cannot be resolved outside the service.
+type ImportCheck struct {
+	policy    DestinationPolicy
+	selfLinks selfLinks
+}
+
+// NewImportCheck returns a check for the policy and the hosts of baseURL and
+// aliasDomains.
+func NewImportCheck(policy DestinationPolicy, baseURL string, aliasDomains []string) *ImportCheck {
+	return &ImportCheck{policy: policy, selfLinks: newSelfLinks(baseURL, aliasDomains, false)}
+}
+
+// Check reports why rawURL may not be imported as a destination, if anything.
+// Every error it returns wraps ErrInvalidURL.
+func (c *ImportCheck) Check(rawURL string) error {
+	if err := ValidateURL(rawURL); err != nil {
+		return err
+	}
+	if _, self := c.selfLinks.shortCode(rawURL); self {
+		return ErrSelfLink
+	}
+	return c.policy.Check(rawURL)
+}
@@ -2,13 +2,42 @@ package service
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"math/rand/v2"
 	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
+	"url-shortener-go/internal/breaker"
 	"url-shortener-go/internal/models"
 	"url-shortener-go/pkg/utils"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	DefaultListLimit = 20
+	MaxListLimit     = 100
+
+	DefaultCleanupBatchSize = 1000
+
+	shortCodeLength = 6
+	maxCodeAttempts = 5
+
+	// maxClickFieldLength bounds client-controlled headers stored with clicks.
+	maxClickFieldLength = 1024
+
+	cacheKeyPrefix = "url:"
 )
 
 type Service struct {
@@ -17,33 +46,151 @@ type Service struct {
 	baseURL        string
 	cacheTTL       time.Duration
 	requestTimeout time.Duration
+	ipHashSalt     string
+
+	clickFlushInterval time.Duration
+	clickFlushSize     int
+	clicks             *clickAggregator
+
+	lookups          singleflight.Group
+	refreshRand      func() float64
+	negativeCacheTTL time.Duration
+	codeFilter       CodeFilter
+	codeFilterReady  atomic.Bool
+
+	// codeFilterMisses counts codes the filter failed to record; a reload
+	// that saw it change must run again.
+	codeFilterMisses    atomic.Int64
+	codeFilterReloading atomic.Bool
+	codeFilterRetry     time.Duration
+
+	// background is cancelled by Close and stops background work.
+	background     context.Context
+	stopBackground context.CancelFunc
+
+	breakerThreshold int
+	breakerCooldown  time.Duration
+	cacheTimeout     time.Duration
+	repoBreaker      *breaker.Breaker
+	cacheBreaker     *breaker.Breaker
+	readOnly         bool
+
+	policy           DestinationPolicy
+	aliasDomains     []string
+	resolveSelfLinks bool
+	selfLinks        selfLinks
+
+	cleanupBatchSize int
+	archive          Archive
+	archiveRetention time.Duration
+
+	metrics Metrics
 }
 
-func New(repo Repository, cache Cache, baseURL string, cacheTTL time.Duration, requestTimeout time.Duration) *Service {
-	return &Service{
+func New(repo Repository, cache Cache, baseURL string, cacheTTL time.Duration, requestTimeout time.Duration, opts ...Option) *Service {
+	s := &Service{
 		repo:           repo,
 		cache:          cache,
 		baseURL:        baseURL,
 		cacheTTL:       cacheTTL,
 		requestTimeout: requestTimeout,
+		refreshRand:    rand.Float64,
+		metrics:        nopMetrics{},
+
+		codeFilterRetry: defaultCodeFilterRetry,
+	}
+	s.background, s.stopBackground = context.WithCancel(context.Background())
+	for _, opt := range opts {
+		opt(s)
 	}
+	s.selfLinks = newSelfLinks(baseURL, s.aliasDomains, s.resolveSelfLinks)
+	if s.cleanupBatchSize <= 0 {
+		s.cleanupBatchSize = DefaultCleanupBatchSize
+	}
+	s.guardStorage()
+	s.clicks = newClickAggregator(s.repo, repo, s.clickFlushInterval, s.clickFlushSize, requestTimeout, s.metrics)
+	return s
+}
+
+// guardStorage wraps the repository, cache and code filter in circuit
+// breakers and the cache timeout, as configured.
+func (s *Service) guardStorage() {
+	if s.breakerThreshold > 0 {
+		s.repoBreaker = breaker.New(s.breakerThreshold, s.breakerCooldown)
+		s.cacheBreaker = breaker.New(s.breakerThreshold, s.breakerCooldown)
+		s.repo = &guardedRepo{repo: s.repo, breaker: s.repoBreaker}
+	}
+	if s.cacheBreaker == nil && s.cacheTimeout <= 0 {
+		return
+	}
+
+	cache := &guardedCache{cache: s.cache, breaker: s.cacheBreaker, timeout: s.cacheTimeout}
+	s.cache = cache
+	if s.codeFilter != nil {
+		s.codeFilter = &guardedFilter{filter: s.codeFilter, cache: cache}
+	}
+}
+
+// Close stops background work and flushes buffered clicks. Call it after the
+// HTTP server has stopped accepting requests.
+func (s *Service) Close(ctx context.Context) error {
+	s.stopBackground()
+	return s.clicks.close(ctx)
 }
 
 func (s *Service) CreateShortURL(ctx context.Context, opts models.CreateURLOptions) (*models.URL, error) {
-	if err := validateURL(opts.OriginalURL); err != nil {
-		return nil, ErrInvalidURL
+	ctx, span := tracer.Start(ctx, "Service.CreateShortURL")
+	url, existing, err := s.createShortURL(ctx, opts)
+	span.SetAttributes(attribute.Bool("link.existing", existing))
+	endSpan(span, err)
+	if existing {
+		s.metrics.LinkCreated(OutcomeExisting)
+	} else {
+		s.metrics.LinkCreated(createOutcome(err))
+	}
+	return url, err
+}
+
+// createShortURL also reports whether an existing link for the destination
+// was returned instead of a new one.
+func (s *Service) createShortURL(ctx context.Context, opts models.CreateURLOptions) (*models.URL, bool, error) {
+	if s.readOnly {
+		return nil, false, ErrReadOnly
+	}
+	if IsReservedCode(opts.CustomCode) {
+		return nil, false, ErrReservedCode
 	}
 
 	ctx, cancel := context.WithTimeout(ctx, s.requestTimeout)
 	defer cancel()
 
+	destination, resolved, err := s.checkDestination(ctx, opts.OriginalURL)
+	if err != nil {
+		return nil, false, err
+	}
+	submitted := opts.OriginalURL
+	opts.OriginalURL = destination
+
+	url, existing, err := s.insertShortURL(ctx, opts)
+	if err != nil || !resolved {
+		return url, existing, err
+	}
+	// Report the resolution on a copy; the cache may hold url itself.
+	reported := *url
+	reported.ResolvedFrom = submitted
+	return &reported, existing, nil
+}
+
+// insertShortURL creates a link for a checked destination, or returns the
+// existing one for it when no custom code is requested.
+func (s *Service) insertShortURL(ctx context.Context, opts models.CreateURLOptions) (*models.URL, bool, error) {
 	if opts.CustomCode == "" {
 		existing, err := s.repo.GetByOriginalURL(ctx, opts.OriginalURL)
 		if err == nil {
-			return existing, nil
+			return existing, true, nil
 		}
 		if err != nil && !errors.Is(err, ErrNotFound) {
-			return nil, err
+			return nil, false, err
 		}
 	}
 
@@ -54,74 +201,274 @@ func (s *Service) CreateShortURL(ctx context.Context, opts models.CreateURLOptio
 
 	shortCode := opts.CustomCode
 	if shortCode == "" {
-		for i := 0; i < 5; i++ {
-			shortCode = utils.GenerateShortCode(6)
+		for i := 0; i < maxCodeAttempts; i++ {
+			shortCode = utils.GenerateShortCode(shortCodeLength)
 			url := &models.URL{
 				ShortCode:   shortCode,
 				OriginalURL: opts.OriginalURL,
+				Status:      models.URLStatusActive,
 				CreatedAt:   time.Now(),
 				ExpiresAt:   expiresAt,
 			}
 			if err := s.repo.Create(ctx, url); err != nil {
 				if errors.Is(err, ErrConflict) {
+					s.metrics.CodeConflict()
 					continue
 				}
-				return nil, err
+				return nil, false, err
 			}
-			s.cache.Set(ctx, cacheKey(shortCode), url, s.cacheTTL)
-			return url, nil
+			s.cacheNewURL(ctx, url)
+			return url, false, nil
 		}
-		return nil, ErrConflict
+		return nil, false, ErrConflict
 	}
 
 	newURL := &models.URL{
 		ShortCode:   shortCode,
 		OriginalURL: opts.OriginalURL,
+		Status:      models.URLStatusActive,
 		CreatedAt:   time.Now(),
 		ExpiresAt:   expiresAt,
 	}
 
 	if err := s.repo.Create(ctx, newURL); err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
-	s.cache.Set(ctx, cacheKey(shortCode), newURL, s.cacheTTL)
+	s.cacheNewURL(ctx, newURL)
 
-	return newURL, nil
+	return newURL, false, nil
 }
 
-func (s *Service) GetFullURL(ctx context.Context, shortCode string) (*models.URL, error) {
+// GetFullURL resolves a short code for a redirect and records the click.
+func (s *Service) GetFullURL(ctx context.Context, shortCode string, info models.ClickInfo) (_ *models.URL, err error) {
+	ctx, span := tracer.Start(ctx, "Service.GetFullURL", trace.WithAttributes(attribute.String("link.short_code", shortCode)))
+	defer func() { endSpan(span, err) }()
+
 	ctx, cancel := context.WithTimeout(ctx, s.requestTimeout)
 	defer cancel()
 
-	if cached, err := s.cache.Get(ctx, cacheKey(shortCode)); err == nil {
+	if cached, ttl, err := s.getCached(ctx, shortCode); err == nil {
+		span.SetAttributes(attribute.Bool("cache.hit", true))
+		if IsMissing(cached) {
+			return nil, ErrNotFound
+		}
+		if s.shouldRefresh(cached, ttl) {
+			s.refreshURL(ctx, shortCode)
+		}
+		if err := checkServable(cached); err != nil {
+			return nil, err
+		}
+		s.recordClick(ctx, cached.ID, info)
 		return cached, nil
 	}
+	span.SetAttributes(attribute.Bool("cache.hit", false))
+
+	if !s.mayExist(ctx, shortCode) {
+		return nil, ErrNotFound
+	}
 
-	url, err := s.repo.GetByShortCode(ctx, shortCode)
+	url, err := s.loadURL(ctx, shortCode)
 	if err != nil {
 		return nil, err
 	}
 
-	s.cache.Set(ctx, cacheKey(shortCode), url, s.cacheTTL)
-
-	go func(urlID int) {
-		bgCtx, bgCancel := context.WithTimeout(context.Background(), s.requestTimeout)
-		defer bgCancel()
-		_ = s.repo.IncrementClickCount(bgCtx, urlID)
-	}(url.ID)
+	s.recordClick(ctx, url.ID, info)
 
 	return url, nil
 }
 
+// recordClick buffers a click. The span that writes it links back to the
+// redirect's span in ctx.
+func (s *Service) recordClick(ctx context.Context, urlID int, info models.ClickInfo) {
+	s.clicks.add(trace.SpanContextFromContext(ctx), models.Click{
+		URLID:     urlID,
+		ClickedAt: time.Now().UTC(),
+		Referrer:  clickField(info.Referrer, maxClickFieldLength),
+		UserAgent: clickField(info.UserAgent, maxClickFieldLength),
+		IPHash:    s.hashIP(info.ClientIP),
+		RequestID: clickField(info.RequestID, 64),
+	})
+}
+
+func (s *Service) hashIP(ip string) string {
+	if ip == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, []byte(s.ipHashSalt))
+	mac.Write([]byte(ip))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// GetURLDetails returns a link and its click statistics without counting a click.
+func (s *Service) GetURLDetails(ctx context.Context, shortCode string) (*models.URLDetails, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.requestTimeout)
+	defer cancel()
+	return s.repo.GetDetailsByShortCode(ctx, shortCode)
+}
+
+// UpdateURL changes a link's destination or expiry in place and drops its cached copy.
+func (s *Service) UpdateURL(ctx context.Context, shortCode string, opts models.UpdateURLOptions) (*models.URLDetails, error) {
+	if s.readOnly {
+		return nil, ErrReadOnly
+	}
+	if opts.ExpiresAt != nil && !opts.ExpiresAt.After(time.Now()) {
+		return nil, ErrInvalidExpiry
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.requestTimeout)
+	defer cancel()
+
+	if opts.OriginalURL != nil {
+		destination, _, err := s.checkDestination(ctx, *opts.OriginalURL)
+		if err != nil {
+			return nil, err
+		}
+		opts.OriginalURL = &destination
+	}
+
+	details, err := s.repo.Update(ctx, shortCode, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	s.cache.Delete(ctx, CacheKey(shortCode))
+
+	return details, nil
+}
+
+// SetURLStatus disables, soft-deletes or restores a link and drops its cached copy.
+func (s *Service) SetURLStatus(ctx context.Context, shortCode string, status models.URLStatus) (*models.URLDetails, error) {
+	if s.readOnly {
+		return nil, ErrReadOnly
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.requestTimeout)
+	defer cancel()
+
+	details, err := s.repo.SetStatus(ctx, shortCode, status)
+	if err != nil {
+		return nil, err
+	}
+
+	s.cache.Delete(ctx, CacheKey(shortCode))
+
+	return details, nil
+}
+
+// PurgeCachedURL drops a code's cached entry, including a cached "not found".
+func (s *Service) PurgeCachedURL(ctx context.Context, shortCode string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.requestTimeout)
+	defer cancel()
+	return s.cache.Delete(ctx, CacheKey(shortCode))
+}
+
+// PurgeCache drops every cached link; each is reloaded from the repository on
+// its next lookup. It walks the whole cache, so it is bounded by ctx only.
+func (s *Service) PurgeCache(ctx context.Context) error {
+	return s.cache.DeletePrefix(ctx, cacheKeyPrefix)
+}
+
+// ListURLs returns a page of links, newest first, using keyset pagination on id.
+func (s *Service) ListURLs(ctx context.Context, opts models.ListURLsOptions) (*models.URLPage, error) {
+	filter := models.ListURLsFilter{
+		Limit:         opts.Limit,
+		CreatedAfter:  opts.CreatedAfter,
+		CreatedBefore: opts.CreatedBefore,
+		Status:        opts.Status,
+		Query:         opts.Query,
+	}
+	if filter.Limit == 0 {
+		filter.Limit = DefaultListLimit
+	}
+	if filter.Limit < 0 || filter.Limit > MaxListLimit {
+		return nil, ErrInvalidFilter
+	}
+	switch filter.Status {
+	case "", models.ListStatusActive, models.ListStatusExpired, models.ListStatusDisabled, models.ListStatusDeleted:
+	default:
+		return nil, ErrInvalidFilter
+	}
+	if filter.CreatedAfter != nil && filter.CreatedBefore != nil && filter.CreatedAfter.After(*filter.CreatedBefore) {
+		return nil, ErrInvalidFilter
+	}
+	if opts.Cursor != "" {
+		afterID, err := decodeCursor(opts.Cursor)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		filter.AfterID = afterID
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.requestTimeout)
+	defer cancel()
+
+	// Fetch one extra row to learn whether another page exists.
+	limit := filter.Limit
+	filter.Limit++
+	urls, err := s.repo.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.URLPage{URLs: urls}
+	if len(urls) > limit {
+		page.URLs = urls[:limit]
+		page.NextCursor = encodeCursor(page.URLs[limit-1].ID)
+	}
+
+	return page, nil
+}
+
+// ExportURLs streams every link with its stats to fn. It is not bound by the
+// request timeout because a full export can take much longer than a request.
+func (s *Service) ExportURLs(ctx context.Context, fn func(*models.URLDetails) error) error {
+	return s.repo.ExportURLs(ctx, fn)
+}
+
 func (s *Service) GenerateShortURL(shortCode string) string {
 	return fmt.Sprintf("%s/%s", s.baseURL, shortCode)
 }
 
-func (s *Service) CleanupExpiredURLs(ctx context.Context) error {
+// CleanupExpiredURLs deletes expired links in batches of the cleanup batch
+// size until none are left, and reports how many it deleted. Each batch is
+// bound by the request timeout, so no single statement holds locks on a large
+// part of the table. With an archive, each batch is archived before it is
+// deleted.
+func (s *Service) CleanupExpiredURLs(ctx context.Context) (int64, error) {
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+
+	var total int64
+	for {
+		deleted, err := s.deleteExpiredBatch(ctx)
+		total += deleted
+		if err != nil {
+			return total, err
+		}
+		if deleted < int64(s.cleanupBatchSize) {
+			return total, nil
+		}
+	}
+}
+
+func (s *Service) deleteExpiredBatch(ctx context.Context) (int64, error) {
 	ctx, cancel := context.WithTimeout(ctx, s.requestTimeout)
 	defer cancel()
-	return s.repo.DeleteExpiredURLs(ctx)
+	var archive ArchiveFunc
+	if s.archive != nil {
+		archive = s.archive.ArchiveURLs
+	}
+	return s.repo.DeleteExpiredURLs(ctx, s.cleanupBatchSize, archive)
+}
+
+// ValidateURL reports whether rawURL is well-formed enough to be a link
+// destination. Unlike link creation, it applies no DestinationPolicy.
+func ValidateURL(rawURL string) error {
+	if err := validateURL(rawURL); err != nil {
+		return ErrInvalidURL
+	}
+	return nil
 }
 
 func validateURL(rawURL string) error {
@@ -141,6 +488,80 @@ func validateURL(rawURL string) error {
 	return nil
 }
 
-func cacheKey(shortCode string) string {
-	return "url:" + shortCode
+// checkServable reports why a link must not be redirected to, if anything.
+// Cached copies can outlive expires_at by a moment, so expiry is checked too.
+func checkServable(url *models.URL) error {
+	switch url.Status {
+	case models.URLStatusDisabled:
+		return ErrDisabled
+	case models.URLStatusDeleted:
+		return ErrDeleted
+	}
+	if url.ExpiresAt != nil && !url.ExpiresAt.After(time.Now()) {
+		return ErrExpired
+	}
+	return nil
+}
+
+// cacheNewURL makes a created link visible. Its code is added to the code
+// filter, and a negative entry left by an earlier lookup is deleted before
+// the link is cached. Deleting tells the other instances to drop their local
+// copy of that entry; overwriting it with Set would not.
+func (s *Service) cacheNewURL(ctx context.Context, url *models.URL) {
+	s.addCode(ctx, url.ShortCode)
+	s.cache.Delete(ctx, CacheKey(url.ShortCode))
+	s.cacheURL(ctx, url)
+}
+
+// cacheURL caches a link until the cache TTL passes or the link expires,
+// whichever comes first.
+func (s *Service) cacheURL(ctx context.Context, url *models.URL) {
+	ttl := s.cacheTTL
+	if url.ExpiresAt != nil {
+		untilExpiry := time.Until(*url.ExpiresAt)
+		if untilExpiry <= 0 {
+			return
+		}
+		if ttl <= 0 || untilExpiry < ttl {
+			ttl = untilExpiry
+		}
+	}
+	s.cache.Set(ctx, CacheKey(url.ShortCode), url, ttl)
+}
+
+// truncate shortens value to at most max bytes without splitting a rune.
+// Invalid UTF-8, which Postgres text columns reject, is replaced first.
+func truncate(value string, max int) string {
+	value = strings.ToValidUTF8(value, "\uFFFD")
+	if len(value) <= max {
+		return value
+	}
+	for max > 0 && !utf8.RuneStart(value[max]) {
+		max--
+	}
+	return value[:max]
+}
+
+func encodeCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(id)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.Atoi(string(raw))
+	if err != nil {
+		return 0, err
+	}
+	if id <= 0 {
+		return 0, ErrInvalidCursor
+	}
+	return id, nil
+}
+
+// CacheKey is the cache key of the link, or negative entry, for shortCode.
+func CacheKey(shortCode string) string {
+	return cacheKeyPrefix + shortCode
 }
@@ -3,10 +3,23 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+	"unicode/utf8"
 
+	"url-shortener-go/internal/bloom"
+	"url-shortener-go/internal/breaker"
 	"url-shortener-go/internal/models"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 type mockRepo struct {
@@ -16,6 +29,20 @@ type mockRepo struct {
 	urlByOriginal       *models.URL
 	urlByShortCode      *models.URL
 	createErr           error
+	listed              []models.URLDetails
+	listFilter          models.ListURLsFilter
+	updateCalls         int
+	takenCodes          map[string]bool
+	batchCalls          int
+	clicksMu            sync.Mutex
+	recordClicksCalls   int
+	clickCounts         []models.ClickCount
+	clickEvents         []models.Click
+	clickErr            error
+	rejectReferrer      string
+	hourly              []models.ClickBucket
+	expired             int
+	deleteCalls         int
 }
 
 func (m *mockRepo) Create(_ context.Context, _ *models.URL) error {
@@ -23,6 +50,17 @@ func (m *mockRepo) Create(_ context.Context, _ *models.URL) error {
 	return m.createErr
 }
 
+func (m *mockRepo) CreateBatch(_ context.Context, urls []*models.URL) ([]error, error) {
+	m.batchCalls++
+	errs := make([]error, len(urls))
+	for i, url := range urls {
+		if m.takenCodes[url.ShortCode] {
+			errs[i] = ErrConflict
+		}
+	}
+	return errs, nil
+}
+
 func (m *mockRepo) GetByShortCode(_ context.Context, _ string) (*models.URL, error) {
 	m.getByShortCodeCalls++
 	if m.urlByShortCode == nil {
@@ -39,20 +77,111 @@ func (m *mockRepo) GetByOriginalURL(_ context.Context, _ string) (*models.URL, e
 	return m.urlByOriginal, nil
 }
 
-func (m *mockRepo) IncrementClickCount(_ context.Context, _ int) error {
+func (m *mockRepo) GetByOriginalURLs(_ context.Context, originalURLs []string) (map[string]*models.URL, error) {
+	found := make(map[string]*models.URL)
+	for _, originalURL := range originalURLs {
+		if m.urlByOriginal != nil && m.urlByOriginal.OriginalURL == originalURL {
+			found[originalURL] = m.urlByOriginal
+		}
+	}
+	return found, nil
+}
+
+func (m *mockRepo) GetDetailsByShortCode(_ context.Context, _ string) (*models.URLDetails, error) {
+	m.getByShortCodeCalls++
+	if m.urlByShortCode == nil {
+		return nil, ErrNotFound
+	}
+	return &models.URLDetails{URL: *m.urlByShortCode}, nil
+}
+
+func (m *mockRepo) List(_ context.Context, filter models.ListURLsFilter) ([]models.URLDetails, error) {
+	m.listFilter = filter
+	if len(m.listed) > filter.Limit {
+		return m.listed[:filter.Limit], nil
+	}
+	return m.listed, nil
+}
+
+func (m *mockRepo) Update(_ context.Context, shortCode string, opts models.UpdateURLOptions) (*models.URLDetails, error) {
+	m.updateCalls++
+	if m.urlByShortCode == nil {
+		return nil, ErrNotFound
+	}
+	updated := *m.urlByShortCode
+	if opts.OriginalURL != nil {
+		updated.OriginalURL = *opts.OriginalURL
+	}
+	return &models.URLDetails{URL: updated}, nil
+}
+
+func (m *mockRepo) SetStatus(_ context.Context, _ string, status models.URLStatus) (*models.URLDetails, error) {
+	if m.urlByShortCode == nil {
+		return nil, ErrNotFound
+	}
+	updated := *m.urlByShortCode
+	updated.Status = status
+	return &models.URLDetails{URL: updated}, nil
+}
+
+func (m *mockRepo) ExportURLs(_ context.Context, fn func(*models.URLDetails) error) error {
+	for i := range m.listed {
+		if err := fn(&m.listed[i]); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func (m *mockRepo) DeleteExpiredURLs(_ context.Context) error {
+func (m *mockRepo) RecordClicks(_ context.Context, counts []models.ClickCount, events []models.Click) error {
+	m.clicksMu.Lock()
+	defer m.clicksMu.Unlock()
+	m.recordClicksCalls++
+	if m.clickErr != nil {
+		return m.clickErr
+	}
+	for _, event := range events {
+		if m.rejectReferrer != "" && event.Referrer == m.rejectReferrer {
+			return fmt.Errorf("%w: invalid byte sequence for encoding", ErrInvalidClicks)
+		}
+	}
+	m.clickCounts = append(m.clickCounts, counts...)
+	m.clickEvents = append(m.clickEvents, events...)
 	return nil
 }
 
+func (m *mockRepo) GetHourlyClicks(_ context.Context, _ int, from, to time.Time) ([]models.ClickBucket, error) {
+	var buckets []models.ClickBucket
+	for _, bucket := range m.hourly {
+		if !bucket.Start.Before(from) && bucket.Start.Before(to) {
+			buckets = append(buckets, bucket)
+		}
+	}
+	return buckets, nil
+}
+
+func (m *mockRepo) DeleteExpiredURLs(ctx context.Context, limit int, archive ArchiveFunc) (int64, error) {
+	deleted := min(m.expired, limit)
+	if archive != nil && deleted > 0 {
+		if err := archive(ctx, make([]models.URLDetails, deleted)); err != nil {
+			return 0, err
+		}
+	}
+	m.expired -= deleted
+	m.deleteCalls++
+	return int64(deleted), nil
+}
+
 type mockCache struct {
-	getCalls int
-	url      *models.URL
+	getCalls        int
+	url             *models.URL
+	deletedKeys     []string
+	deletedPrefixes []string
+	setTTLs         []time.Duration
 }
 
-func (m *mockCache) Set(_ context.Context, _ string, _ *models.URL, _ time.Duration) error {
+func (m *mockCache) Set(_ context.Context, _ string, _ *models.URL, expiration time.Duration) error {
+	m.setTTLs = append(m.setTTLs, expiration)
 	return nil
 }
 
@@ -64,6 +193,16 @@ func (m *mockCache) Get(_ context.Context, _ string) (*models.URL, error) {
 	return m.url, nil
 }
 
+func (m *mockCache) Delete(_ context.Context, key string) error {
+	m.deletedKeys = append(m.deletedKeys, key)
+	return nil
+}
+
+func (m *mockCache) DeletePrefix(_ context.Context, prefix string) error {
+	m.deletedPrefixes = append(m.deletedPrefixes, prefix)
+	return nil
+}
+
 func TestCreateShortURL_InvalidURL(t *testing.T) {
 	repo := &mockRepo{}
 	cache := &mockCache{}
@@ -111,7 +250,7 @@ func TestGetFullURL_CacheHit(t *testing.T) {
 	cache := &mockCache{url: cached}
 	svc := New(repo, cache, "http://localhost:8080", time.Hour, 2*time.Second)
 
-	url, err := svc.GetFullURL(context.Background(), "cached")
+	url, err := svc.GetFullURL(context.Background(), "cached", models.ClickInfo{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -139,3 +278,1228 @@ func TestCreateShortURL_ConflictOnCustomCode(t *testing.T) {
 		t.Fatalf("expected ErrConflict, got %v", err)
 	}
 }
+
+func TestCreateShortURL_RejectsReservedCodes(t *testing.T) {
+	repo := &mockRepo{}
+	svc := New(repo, &mockCache{}, "http://localhost:8080", time.Hour, 2*time.Second)
+
+	_, err := svc.CreateShortURL(context.Background(), models.CreateURLOptions{
+		OriginalURL: "https://example.com",
+		CustomCode:  "export",
+	})
+	if !errors.Is(err, ErrReservedCode) {
+		t.Fatalf("expected ErrReservedCode, got %v", err)
+	}
+
+	results, err := svc.CreateShortURLBatch(context.Background(), []models.CreateURLOptions{
+		{OriginalURL: "https://example.com", CustomCode: "metrics"},
+		{OriginalURL: "https://example.com", CustomCode: "promo"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !errors.Is(results[0].Err, ErrReservedCode) || results[1].Err != nil {
+		t.Fatalf("unexpected results: %v, %v", results[0].Err, results[1].Err)
+	}
+	if repo.createCalls != 0 {
+		t.Fatal("expected no single create for a reserved code")
+	}
+}
+
+func TestListURLs_ReturnsNextCursor(t *testing.T) {
+	repo := &mockRepo{listed: []models.URLDetails{
+		{URL: models.URL{ID: 30}},
+		{URL: models.URL{ID: 20}},
+		{URL: models.URL{ID: 10}},
+	}}
+	svc := New(repo, &mockCache{}, "http://localhost:8080", time.Hour, 2*time.Second)
+
+	page, err := svc.ListURLs(context.Background(), models.ListURLsOptions{Limit: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(page.URLs) != 2 {
+		t.Fatalf("expected 2 urls, got %d", len(page.URLs))
+	}
+	if page.NextCursor == "" {
+		t.Fatalf("expected next cursor")
+	}
+
+	if _, err := svc.ListURLs(context.Background(), models.ListURLsOptions{Limit: 2, Cursor: page.NextCursor}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if repo.listFilter.AfterID != 20 {
+		t.Fatalf("expected cursor to resume after id 20, got %d", repo.listFilter.AfterID)
+	}
+}
+
+func TestListURLs_LastPageHasNoCursor(t *testing.T) {
+	repo := &mockRepo{listed: []models.URLDetails{{URL: models.URL{ID: 1}}}}
+	svc := New(repo, &mockCache{}, "http://localhost:8080", time.Hour, 2*time.Second)
+
+	page, err := svc.ListURLs(context.Background(), models.ListURLsOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if page.NextCursor != "" {
+		t.Fatalf("expected no next cursor, got %q", page.NextCursor)
+	}
+}
+
+func TestListURLs_InvalidCursorAndStatus(t *testing.T) {
+	svc := New(&mockRepo{}, &mockCache{}, "http://localhost:8080", time.Hour, 2*time.Second)
+
+	if _, err := svc.ListURLs(context.Background(), models.ListURLsOptions{Cursor: "!!"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("expected ErrInvalidCursor, got %v", err)
+	}
+	if _, err := svc.ListURLs(context.Background(), models.ListURLsOptions{Status: "bogus"}); !errors.Is(err, ErrInvalidFilter) {
+		t.Fatalf("expected ErrInvalidFilter, got %v", err)
+	}
+}
+
+func TestUpdateURL_InvalidatesCache(t *testing.T) {
+	repo := &mockRepo{urlByShortCode: &models.URL{ID: 3, ShortCode: "abc123", OriginalURL: "https://example.com"}}
+	cache := &mockCache{}
+	svc := New(repo, cache, "http://localhost:8080", time.Hour, 2*time.Second)
+
+	destination := "https://example.org/fixed"
+	details, err := svc.UpdateURL(context.Background(), "abc123", models.UpdateURLOptions{OriginalURL: &destination})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if details.OriginalURL != destination {
+		t.Fatalf("expected %s, got %s", destination, details.OriginalURL)
+	}
+	if len(cache.deletedKeys) != 1 || cache.deletedKeys[0] != "url:abc123" {
+		t.Fatalf("expected cache key to be invalidated, got %v", cache.deletedKeys)
+	}
+}
+
+func TestUpdateURL_RejectsInvalidInput(t *testing.T) {
+	repo := &mockRepo{urlByShortCode: &models.URL{ID: 3, ShortCode: "abc123"}}
+	svc := New(repo, &mockCache{}, "http://localhost:8080", time.Hour, 2*time.Second)
+
+	invalid := "example.com"
+	if _, err := svc.UpdateURL(context.Background(), "abc123", models.UpdateURLOptions{OriginalURL: &invalid}); !errors.Is(err, ErrInvalidURL) {
+		t.Fatalf("expected ErrInvalidURL, got %v", err)
+	}
+	past := time.Now().Add(-time.Minute)
+	if _, err := svc.UpdateURL(context.Background(), "abc123", models.UpdateURLOptions{ExpiresAt: &past}); !errors.Is(err, ErrInvalidExpiry) {
+		t.Fatalf("expected ErrInvalidExpiry, got %v", err)
+	}
+	if repo.updateCalls != 0 {
+		t.Fatalf("expected no update call, got %d", repo.updateCalls)
+	}
+}
+
+func TestSetURLStatus_InvalidatesCache(t *testing.T) {
+	repo := &mockRepo{urlByShortCode: &models.URL{ID: 4, ShortCode: "abc123", Status: models.URLStatusActive}}
+	cache := &mockCache{}
+	svc := New(repo, cache, "http://localhost:8080", time.Hour, 2*time.Second)
+
+	details, err := svc.SetURLStatus(context.Background(), "abc123", models.URLStatusDisabled)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if details.Status != models.URLStatusDisabled {
+		t.Fatalf("expected disabled, got %s", details.Status)
+	}
+	if len(cache.deletedKeys) != 1 || cache.deletedKeys[0] != "url:abc123" {
+		t.Fatalf("expected cache key to be invalidated, got %v", cache.deletedKeys)
+	}
+}
+
+func TestGetFullURL_CachedDisabledLinkIsGone(t *testing.T) {
+	cache := &mockCache{url: &models.URL{ID: 5, ShortCode: "off", Status: models.URLStatusDisabled}}
+	svc := New(&mockRepo{}, cache, "http://localhost:8080", time.Hour, 2*time.Second)
+
+	_, err := svc.GetFullURL(context.Background(), "off", models.ClickInfo{})
+	if !errors.Is(err, ErrDisabled) {
+		t.Fatalf("expected ErrDisabled, got %v", err)
+	}
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrDisabled to wrap ErrNotFound")
+	}
+}
+
+func TestCreateShortURLBatch_PerItemResults(t *testing.T) {
+	existing := &models.URL{ID: 9, ShortCode: "exists", OriginalURL: "https://example.com/existing"}
+	repo := &mockRepo{urlByOriginal: existing, takenCodes: map[string]bool{"taken": true}}
+	svc := New(repo, &mockCache{}, "http://localhost:8080", time.Hour, 2*time.Second)
+
+	results, err := svc.CreateShortURLBatch(context.Background(), []models.CreateURLOptions{
+		{OriginalURL: "https://example.com/new"},
+		{OriginalURL: "not a url"},
+		{OriginalURL: "https://example.com/existing"},
+		{OriginalURL: "https://example.com/other", CustomCode: "taken"},
+		{OriginalURL: "https://example.com/new"},
+		{OriginalURL: "https://example.com/custom", CustomCode: "custom"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 6 {
+		t.Fatalf("expected 6 results, got %d", len(results))
+	}
+	if results[0].Err != nil || results[0].URL == nil {
+		t.Fatalf("expected item 0 to be created, got %v", results[0].Err)
+	}
+	if !errors.Is(results[1].Err, ErrInvalidURL) {
+		t.Fatalf("expected item 1 to be invalid, got %v", results[1].Err)
+	}
+	if results[2].URL == nil || results[2].URL.ShortCode != "exists" {
+		t.Fatalf("expected item 2 to reuse existing link, got %+v", results[2])
+	}
+	if !errors.Is(results[3].Err, ErrConflict) {
+		t.Fatalf("expected item 3 to conflict, got %v", results[3].Err)
+	}
+	if results[4].URL == nil || results[4].URL.ShortCode != results[0].URL.ShortCode {
+		t.Fatalf("expected item 4 to share item 0's link, got %+v", results[4])
+	}
+	if results[5].URL == nil || results[5].URL.ShortCode != "custom" {
+		t.Fatalf("expected item 5 to use custom code, got %+v", results[5])
+	}
+	if repo.batchCalls != 1 {
+		t.Fatalf("expected a single batch insert, got %d", repo.batchCalls)
+	}
+}
+
+func TestGetFullURL_RecordsClickWithHashedIP(t *testing.T) {
+	cached := &models.URL{
+		ID:          2,
+		ShortCode:   "cached",
+		OriginalURL: "https://example.com",
+	}
+	repo := &mockRepo{}
+	cache := &mockCache{url: cached}
+	svc := New(repo, cache, "http://localhost:8080", time.Hour, 2*time.Second, WithIPHashSalt("pepper"))
+
+	_, err := svc.GetFullURL(context.Background(), "cached", models.ClickInfo{
+		Referrer:  "https://news.example.com",
+		UserAgent: "test-agent",
+		ClientIP:  "203.0.113.7",
+		RequestID: "req-1",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := svc.Close(context.Background()); err != nil {
+		t.Fatalf("unexpected close error: %v", err)
+	}
+
+	if len(repo.clickEvents) != 1 {
+		t.Fatalf("expected 1 click event, got %d", len(repo.clickEvents))
+	}
+	click := repo.clickEvents[0]
+	if click.URLID != 2 {
+		t.Fatalf("expected url id 2, got %d", click.URLID)
+	}
+	if click.Referrer != "https://news.example.com" || click.UserAgent != "test-agent" || click.RequestID != "req-1" {
+		t.Fatalf("unexpected click metadata: %+v", click)
+	}
+	if click.IPHash == "" || click.IPHash == "203.0.113.7" || len(click.IPHash) != 64 {
+		t.Fatalf("expected hashed ip, got %q", click.IPHash)
+	}
+	if click.IPHash == (&Service{}).hashIP("203.0.113.7") {
+		t.Fatalf("expected salt to change the ip hash")
+	}
+}
+
+func TestTruncate_KeepsValidUTF8(t *testing.T) {
+	// "é" is two bytes, so a byte cut at 5 would split the third one.
+	if got := truncate("ééé", 5); got != "éé" {
+		t.Fatalf("expected the cut to fall on a rune boundary, got %q", got)
+	}
+	if got := truncate("bad\xffagent", 100); got != "bad\uFFFDagent" {
+		t.Fatalf("expected invalid bytes to be replaced, got %q", got)
+	}
+	for _, value := range []string{"ééé", "日本語のヘッダー", "a\xe6\x97"} {
+		for max := range len(value) + 1 {
+			if got := truncate(value, max); !utf8.ValidString(got) || len(got) > max {
+				t.Fatalf("truncate(%q, %d) = %q", value, max, got)
+			}
+		}
+	}
+}
+
+func TestGetFullURL_CoalescesClickCounts(t *testing.T) {
+	cached := &models.URL{ID: 7, ShortCode: "hot", OriginalURL: "https://example.com"}
+	repo := &mockRepo{}
+	cache := &mockCache{url: cached}
+	svc := New(repo, cache, "http://localhost:8080", time.Hour, 2*time.Second, WithClickFlushInterval(time.Hour))
+
+	for range 3 {
+		if _, err := svc.GetFullURL(context.Background(), "hot", models.ClickInfo{}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := svc.Close(context.Background()); err != nil {
+		t.Fatalf("unexpected close error: %v", err)
+	}
+
+	if len(repo.clickCounts) != 1 {
+		t.Fatalf("expected 1 coalesced count, got %d", len(repo.clickCounts))
+	}
+	if repo.clickCounts[0].URLID != 7 || repo.clickCounts[0].Count != 3 {
+		t.Fatalf("unexpected count: %+v", repo.clickCounts[0])
+	}
+	if len(repo.clickEvents) != 3 {
+		t.Fatalf("expected 3 click events, got %d", len(repo.clickEvents))
+	}
+}
+
+func TestClickAggregator_KeepsClicksWhenFlushFails(t *testing.T) {
+	repo := &mockRepo{clickErr: errors.New("db down")}
+	aggregator := newClickAggregator(repo, repo, time.Hour, 10, time.Second, nopMetrics{})
+	aggregator.add(trace.SpanContext{}, models.Click{URLID: 1, ClickedAt: time.Now()})
+	aggregator.add(trace.SpanContext{}, models.Click{URLID: 1, ClickedAt: time.Now()})
+
+	if err := aggregator.close(context.Background()); err == nil {
+		t.Fatal("expected flush error")
+	}
+
+	repo.clickErr = nil
+	if err := aggregator.flush(context.Background()); err != nil {
+		t.Fatalf("unexpected flush error: %v", err)
+	}
+	if len(repo.clickCounts) != 1 || repo.clickCounts[0].Count != 2 {
+		t.Fatalf("expected retried count of 2, got %+v", repo.clickCounts)
+	}
+	if len(repo.clickEvents) != 2 {
+		t.Fatalf("expected 2 retried events, got %d", len(repo.clickEvents))
+	}
+}
+
+func TestClickAggregator_DropsRejectedEvents(t *testing.T) {
+	repo := &mockRepo{rejectReferrer: "poison"}
+	metrics := &recordingMetrics{}
+	aggregator := newClickAggregator(repo, repo, time.Hour, 10, time.Second, metrics)
+	for _, referrer := range []string{"a", "b", "poison", "c", "d"} {
+		aggregator.add(trace.SpanContext{}, models.Click{URLID: 1, ClickedAt: time.Now(), Referrer: referrer})
+	}
+
+	if err := aggregator.close(context.Background()); err != nil {
+		t.Fatalf("unexpected flush error: %v", err)
+	}
+	if len(repo.clickCounts) != 1 || repo.clickCounts[0].Count != 5 {
+		t.Fatalf("expected every click to be counted, got %+v", repo.clickCounts)
+	}
+	if len(repo.clickEvents) != 4 || metrics.droppedClicks != 1 {
+		t.Fatalf("expected only the rejected event to be dropped, got %d events, %d dropped", len(repo.clickEvents), metrics.droppedClicks)
+	}
+
+	// Nothing is left behind to fail the next flush.
+	aggregator.add(trace.SpanContext{}, models.Click{URLID: 1, ClickedAt: time.Now(), Referrer: "e"})
+	if err := aggregator.flush(context.Background()); err != nil {
+		t.Fatalf("unexpected flush error: %v", err)
+	}
+	if len(repo.clickEvents) != 5 {
+		t.Fatalf("expected the next flush to write its event, got %d events", len(repo.clickEvents))
+	}
+}
+
+func TestClickAggregator_RequeuesRightAwayDuringOutages(t *testing.T) {
+	for _, clickErr := range []error{
+		fmt.Errorf("%w: connection refused", ErrUnavailable),
+		context.DeadlineExceeded,
+	} {
+		repo := &mockRepo{clickErr: clickErr}
+		aggregator := newClickAggregator(repo, repo, time.Hour, 10, time.Second, nopMetrics{})
+		aggregator.add(trace.SpanContext{}, models.Click{URLID: 1, ClickedAt: time.Now()})
+
+		if err := aggregator.close(context.Background()); !errors.Is(err, clickErr) {
+			t.Fatalf("expected %v, got %v", clickErr, err)
+		}
+		if repo.recordClicksCalls != 1 {
+			t.Fatalf("%v: expected no counts-only retry, got %d writes", clickErr, repo.recordClicksCalls)
+		}
+		if len(aggregator.events) != 1 || len(aggregator.counts) != 1 {
+			t.Fatalf("%v: expected the clicks to be requeued", clickErr)
+		}
+	}
+}
+
+func TestClickAggregator_FlushesThroughBreaker(t *testing.T) {
+	repo := &mockRepo{clickErr: errors.New("db down")}
+	svc := New(repo, &mockCache{}, "http://localhost:8080", time.Hour, 2*time.Second, WithCircuitBreakers(2, time.Minute))
+	defer svc.Close(context.Background())
+
+	svc.clicks.add(trace.SpanContext{}, models.Click{URLID: 1, ClickedAt: time.Now()})
+	for range 2 {
+		svc.clicks.flush(context.Background())
+	}
+	if state := svc.repoBreaker.State(); state != breaker.Open {
+		t.Fatalf("expected failed flushes to open the breaker, got %s", state)
+	}
+	if err := svc.clicks.flush(context.Background()); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("expected the open breaker to stop the flush, got %v", err)
+	}
+}
+
+func TestClickAggregator_RejectedEventsDoNotOpenBreaker(t *testing.T) {
+	repo := &mockRepo{rejectReferrer: "poison"}
+	svc := New(repo, &mockCache{}, "http://localhost:8080", time.Hour, 2*time.Second, WithCircuitBreakers(2, time.Minute))
+	defer svc.Close(context.Background())
+
+	for _, referrer := range []string{"a", "b", "c", "poison", "d", "e", "f", "g"} {
+		svc.clicks.add(trace.SpanContext{}, models.Click{URLID: 1, ClickedAt: time.Now(), Referrer: referrer})
+	}
+	if err := svc.clicks.flush(context.Background()); err != nil {
+		t.Fatalf("unexpected flush error: %v", err)
+	}
+	if state := svc.repoBreaker.State(); state != breaker.Closed {
+		t.Fatalf("expected the breaker to stay closed, got %s", state)
+	}
+	if len(repo.clickEvents) != 7 {
+		t.Fatalf("expected every other event to be written, got %d", len(repo.clickEvents))
+	}
+}
+
+func TestRecordClick_CleansHeaders(t *testing.T) {
+	cached := &models.URL{ID: 2, ShortCode: "cached", OriginalURL: "https://example.com"}
+	repo := &mockRepo{}
+	svc := New(repo, &mockCache{url: cached}, "http://localhost:8080", time.Hour, 2*time.Second)
+
+	_, err := svc.GetFullURL(context.Background(), "cached", models.ClickInfo{
+		Referrer:  "https://example.com/\x00\xff",
+		UserAgent: strings.Repeat("é", maxClickFieldLength),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	svc.Close(context.Background())
+
+	click := repo.clickEvents[0]
+	if click.Referrer != "https://example.com/\uFFFD" {
+		t.Fatalf("unexpected referrer %q", click.Referrer)
+	}
+	if !utf8.ValidString(click.UserAgent) || len(click.UserAgent) > maxClickFieldLength {
+		t.Fatalf("expected a valid user agent of at most %d bytes, got %d bytes", maxClickFieldLength, len(click.UserAgent))
+	}
+}
+
+func TestGetClickStats_HourlyBucketsAreZeroFilled(t *testing.T) {
+	repo := &mockRepo{
+		urlByShortCode: &models.URL{ID: 3, ShortCode: "abc123"},
+		hourly: []models.ClickBucket{
+			{Start: time.Date(2026, 2, 15, 10, 0, 0, 0, time.UTC), Clicks: 4},
+			{Start: time.Date(2026, 2, 15, 13, 0, 0, 0, time.UTC), Clicks: 9},
+		},
+	}
+	svc := New(repo, &mockCache{}, "http://localhost:8080", time.Hour, 2*time.Second)
+
+	from := time.Date(2026, 2, 15, 10, 30, 0, 0, time.UTC)
+	to := time.Date(2026, 2, 15, 12, 15, 0, 0, time.UTC)
+	stats, err := svc.GetClickStats(context.Background(), "abc123", models.ClickStatsOptions{From: &from, To: &to})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if stats.Interval != models.StatsIntervalHour {
+		t.Fatalf("expected hour interval, got %s", stats.Interval)
+	}
+	if !stats.From.Equal(time.Date(2026, 2, 15, 10, 0, 0, 0, time.UTC)) || !stats.To.Equal(time.Date(2026, 2, 15, 13, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected range widened to whole hours, got %s - %s", stats.From, stats.To)
+	}
+	if len(stats.Buckets) != 3 {
+		t.Fatalf("expected 3 buckets, got %d", len(stats.Buckets))
+	}
+	if stats.Buckets[0].Clicks != 4 || stats.Buckets[1].Clicks != 0 || stats.Buckets[2].Clicks != 0 {
+		t.Fatalf("unexpected buckets: %+v", stats.Buckets)
+	}
+	if stats.Total != 4 {
+		t.Fatalf("expected total 4, got %d", stats.Total)
+	}
+}
+
+func TestGetClickStats_InvalidRange(t *testing.T) {
+	repo := &mockRepo{urlByShortCode: &models.URL{ID: 3, ShortCode: "abc123"}}
+	svc := New(repo, &mockCache{}, "http://localhost:8080", time.Hour, 2*time.Second)
+
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(MaxHourlyStatsRange + time.Hour)
+	_, err := svc.GetClickStats(context.Background(), "abc123", models.ClickStatsOptions{From: &from, To: &to})
+	if !errors.Is(err, ErrInvalidStatsRange) {
+		t.Fatalf("expected ErrInvalidStatsRange, got %v", err)
+	}
+
+	_, err = svc.GetClickStats(context.Background(), "abc123", models.ClickStatsOptions{Interval: "week"})
+	if !errors.Is(err, ErrInvalidFilter) {
+		t.Fatalf("expected ErrInvalidFilter, got %v", err)
+	}
+}
+
+// blockingRepo holds GetByShortCode until release is closed.
+type blockingRepo struct {
+	*mockRepo
+	calls   atomic.Int32
+	started chan struct{}
+	release chan struct{}
+}
+
+func (r *blockingRepo) GetByShortCode(_ context.Context, shortCode string) (*models.URL, error) {
+	if r.calls.Add(1) == 1 {
+		close(r.started)
+	}
+	<-r.release
+	return &models.URL{ID: 11, ShortCode: shortCode, OriginalURL: "https://example.com/viral"}, nil
+}
+
+// missCache never has an entry and is safe for concurrent use.
+type missCache struct{}
+
+func (missCache) Set(_ context.Context, _ string, _ *models.URL, _ time.Duration) error { return nil }
+func (missCache) Get(_ context.Context, _ string) (*models.URL, error)                  { return nil, ErrNotFound }
+func (missCache) Delete(_ context.Context, _ string) error                              { return nil }
+func (missCache) DeletePrefix(_ context.Context, _ string) error                        { return nil }
+
+// ttlCache is a mockCache that reports a fixed remaining TTL.
+type ttlCache struct {
+	mockCache
+	ttl  time.Duration
+	sets chan string
+}
+
+func (c *ttlCache) GetWithTTL(ctx context.Context, key string) (*models.URL, time.Duration, error) {
+	url, err := c.Get(ctx, key)
+	return url, c.ttl, err
+}
+
+func (c *ttlCache) Set(_ context.Context, key string, _ *models.URL, _ time.Duration) error {
+	c.sets <- key
+	return nil
+}
+
+func TestGetFullURL_CoalescesConcurrentMisses(t *testing.T) {
+	repo := &blockingRepo{mockRepo: &mockRepo{}, started: make(chan struct{}), release: make(chan struct{})}
+	svc := New(repo, missCache{}, "http://localhost:8080", time.Hour, 2*time.Second)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for range 10 {
+		wg.Go(func() {
+			url, err := svc.GetFullURL(context.Background(), "viral", models.ClickInfo{})
+			if err == nil && url.OriginalURL != "https://example.com/viral" {
+				err = errors.New("unexpected url " + url.OriginalURL)
+			}
+			errs <- err
+		})
+	}
+
+	<-repo.started
+	time.Sleep(50 * time.Millisecond)
+	close(repo.release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if calls := repo.calls.Load(); calls != 1 {
+		t.Fatalf("expected one repository lookup, got %d", calls)
+	}
+}
+
+func TestGetFullURL_RefreshesHotLinkBeforeExpiry(t *testing.T) {
+	repo := &blockingRepo{mockRepo: &mockRepo{}, started: make(chan struct{}), release: make(chan struct{})}
+	close(repo.release)
+	cache := &ttlCache{
+		mockCache: mockCache{url: &models.URL{ID: 11, ShortCode: "viral", OriginalURL: "https://example.com/viral"}},
+		ttl:       time.Second,
+		sets:      make(chan string, 1),
+	}
+	svc := New(repo, cache, "http://localhost:8080", time.Hour, 2*time.Second)
+	svc.refreshRand = func() float64 { return 0.5 }
+
+	if _, err := svc.GetFullURL(context.Background(), "viral", models.ClickInfo{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	select {
+	case key := <-cache.sets:
+		if key != "url:viral" {
+			t.Fatalf("expected url:viral to be refreshed, got %s", key)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the cached link to be refreshed")
+	}
+}
+
+func TestShouldRefresh(t *testing.T) {
+	svc := New(&mockRepo{}, &mockCache{}, "http://localhost:8080", time.Hour, 2*time.Second)
+	svc.refreshRand = func() float64 { return 0.5 }
+
+	expiringSoon := &models.URL{ID: 1, ExpiresAt: new(time.Now().Add(2 * time.Minute))}
+	tests := []struct {
+		url  *models.URL
+		ttl  time.Duration
+		want bool
+	}{
+		{&models.URL{ID: 1}, 0, false},
+		{&models.URL{ID: 1}, time.Hour, false},
+		{&models.URL{ID: 1}, 6 * time.Minute, false},
+		{&models.URL{ID: 1}, 4 * time.Minute, false},
+		{&models.URL{ID: 1}, 2 * time.Minute, true},
+		{expiringSoon, 2 * time.Minute, false},
+	}
+	for _, tc := range tests {
+		if got := svc.shouldRefresh(tc.url, tc.ttl); got != tc.want {
+			t.Fatalf("shouldRefresh(%s) = %t, want %t", tc.ttl, got, tc.want)
+		}
+	}
+}
+
+// storeCache keeps entries in a map, ignoring expiry.
+type storeCache struct {
+	mu      sync.Mutex
+	entries map[string]models.URL
+}
+
+func (c *storeCache) Set(_ context.Context, key string, value *models.URL, _ time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = *value
+	return nil
+}
+
+func (c *storeCache) Get(_ context.Context, key string) (*models.URL, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	url, ok := c.entries[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &url, nil
+}
+
+func (c *storeCache) Delete(_ context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+	return nil
+}
+
+func (c *storeCache) DeletePrefix(_ context.Context, prefix string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.entries, key)
+		}
+	}
+	return nil
+}
+
+func TestGetFullURL_CachesUnknownCodes(t *testing.T) {
+	repo := &mockRepo{}
+	cache := &storeCache{entries: map[string]models.URL{}}
+	svc := New(repo, cache, "http://localhost:8080", time.Hour, 2*time.Second, WithNegativeCacheTTL(time.Minute))
+
+	for range 3 {
+		if _, err := svc.GetFullURL(context.Background(), "nope", models.ClickInfo{}); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+	}
+	if repo.getByShortCodeCalls != 1 {
+		t.Fatalf("expected one repository lookup, got %d", repo.getByShortCodeCalls)
+	}
+	if cached, err := cache.Get(context.Background(), "url:nope"); err != nil || !IsMissing(cached) {
+		t.Fatalf("expected a negative cache entry, got %+v, %v", cached, err)
+	}
+}
+
+func TestGetFullURL_CodeFilterRejectsUnknownCodes(t *testing.T) {
+	repo := &mockRepo{
+		urlByShortCode: &models.URL{ID: 1, ShortCode: "known", OriginalURL: "https://example.com"},
+		listed:         []models.URLDetails{{URL: models.URL{ID: 1, ShortCode: "known"}}},
+	}
+	filter := bloom.New(bloom.Estimate(100, bloom.DefaultFalsePositiveRate))
+	svc := New(repo, &mockCache{}, "http://localhost:8080", time.Hour, 2*time.Second, WithCodeFilter(filter))
+
+	svc.GetFullURL(context.Background(), "unknown", models.ClickInfo{})
+	if repo.getByShortCodeCalls != 1 {
+		t.Fatalf("expected the filter to be bypassed before it is loaded, got %d lookups", repo.getByShortCodeCalls)
+	}
+
+	if err := svc.LoadCodeFilter(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := svc.GetFullURL(context.Background(), "unknown", models.ClickInfo{}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := svc.GetFullURL(context.Background(), "known", models.ClickInfo{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if repo.getByShortCodeCalls != 2 {
+		t.Fatalf("expected only the known code to reach the repository, got %d lookups", repo.getByShortCodeCalls)
+	}
+
+	if _, err := svc.CreateShortURL(context.Background(), models.CreateURLOptions{OriginalURL: "https://example.com/new", CustomCode: "fresh"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ok, _ := filter.MayContain(context.Background(), "fresh"); !ok {
+		t.Fatal("expected created codes to be added to the filter")
+	}
+}
+
+func TestCreateShortURL_CacheTTLRespectsExpiry(t *testing.T) {
+	cache := &mockCache{}
+	svc := New(&mockRepo{}, cache, "http://localhost:8080", time.Hour, 2*time.Second)
+
+	if _, err := svc.CreateShortURL(context.Background(), models.CreateURLOptions{
+		OriginalURL: "https://example.com",
+		CustomCode:  "brief",
+		ExpiresIn:   5 * time.Minute,
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(cache.setTTLs) != 1 {
+		t.Fatalf("expected one cache write, got %d", len(cache.setTTLs))
+	}
+	if ttl := cache.setTTLs[0]; ttl > 5*time.Minute || ttl < 4*time.Minute {
+		t.Fatalf("expected the cache TTL to end at expiry, got %s", ttl)
+	}
+}
+
+func TestGetFullURL_CachedExpiredLinkIsGone(t *testing.T) {
+	cache := &mockCache{url: &models.URL{ID: 5, ShortCode: "old", ExpiresAt: new(time.Now().Add(-time.Second))}}
+	svc := New(&mockRepo{}, cache, "http://localhost:8080", time.Hour, 2*time.Second)
+
+	if _, err := svc.GetFullURL(context.Background(), "old", models.ClickInfo{}); !errors.Is(err, ErrExpired) {
+		t.Fatalf("expected ErrExpired, got %v", err)
+	}
+}
+
+func TestPurgeCache(t *testing.T) {
+	cache := &mockCache{}
+	svc := New(&mockRepo{}, cache, "http://localhost:8080", time.Hour, 2*time.Second)
+
+	if err := svc.PurgeCachedURL(context.Background(), "abc"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := svc.PurgeCache(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(cache.deletedKeys) != 1 || cache.deletedKeys[0] != "url:abc" {
+		t.Fatalf("unexpected deleted keys: %v", cache.deletedKeys)
+	}
+	if len(cache.deletedPrefixes) != 1 || cache.deletedPrefixes[0] != "url:" {
+		t.Fatalf("unexpected deleted prefixes: %v", cache.deletedPrefixes)
+	}
+}
+
+// downRepo fails every lookup like an unreachable database.
+type downRepo struct {
+	*mockRepo
+	calls int
+}
+
+func (r *downRepo) GetByShortCode(_ context.Context, _ string) (*models.URL, error) {
+	r.calls++
+	return nil, errors.New("connection refused")
+}
+
+func TestGetFullURL_BreakerFailsFastWhileRepositoryIsDown(t *testing.T) {
+	repo := &downRepo{mockRepo: &mockRepo{}}
+	svc := New(repo, missCache{}, "http://localhost:8080", time.Hour, 2*time.Second, WithCircuitBreakers(2, time.Minute))
+
+	for range 5 {
+		if _, err := svc.GetFullURL(context.Background(), "abc", models.ClickInfo{}); !errors.Is(err, ErrUnavailable) {
+			t.Fatalf("expected ErrUnavailable, got %v", err)
+		}
+	}
+	if repo.calls != 2 {
+		t.Fatalf("expected the breaker to stop calls after 2 failures, got %d calls", repo.calls)
+	}
+}
+
+func TestGetFullURL_ServesCacheWhileRepositoryIsDown(t *testing.T) {
+	repo := &downRepo{mockRepo: &mockRepo{}}
+	cache := &mockCache{url: &models.URL{ID: 4, ShortCode: "abc", OriginalURL: "https://example.com"}}
+	svc := New(repo, cache, "http://localhost:8080", time.Hour, 2*time.Second, WithCircuitBreakers(1, time.Minute))
+
+	svc.repo.GetByShortCode(context.Background(), "warmup")
+
+	if _, err := svc.GetFullURL(context.Background(), "abc", models.ClickInfo{}); err != nil {
+		t.Fatalf("expected the cached link to be served, got %v", err)
+	}
+}
+
+func TestReadOnly_RejectsWrites(t *testing.T) {
+	repo := &mockRepo{urlByShortCode: &models.URL{ID: 1, ShortCode: "abc", OriginalURL: "https://example.com"}}
+	svc := New(repo, &mockCache{}, "http://localhost:8080", time.Hour, 2*time.Second, WithReadOnly(true))
+	ctx := context.Background()
+
+	if _, err := svc.CreateShortURL(ctx, models.CreateURLOptions{OriginalURL: "https://example.com"}); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("expected ErrReadOnly from create, got %v", err)
+	}
+	if _, err := svc.CreateShortURLBatch(ctx, []models.CreateURLOptions{{OriginalURL: "https://example.com"}}); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("expected ErrReadOnly from batch create, got %v", err)
+	}
+	if _, err := svc.SetURLStatus(ctx, "abc", models.URLStatusDisabled); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("expected ErrReadOnly to wrap ErrUnavailable, got %v", err)
+	}
+	if repo.createCalls != 0 || repo.batchCalls != 0 {
+		t.Fatal("expected the repository not to be written")
+	}
+
+	if _, err := svc.GetFullURL(ctx, "abc", models.ClickInfo{}); err != nil {
+		t.Fatalf("expected redirects to keep working, got %v", err)
+	}
+}
+
+type recordingMetrics struct {
+	nopMetrics
+	outcomes      []string
+	conflicts     int
+	droppedClicks int
+}
+
+func (m *recordingMetrics) ClickEventsDropped(n int) {
+	m.droppedClicks += n
+}
+
+func (m *recordingMetrics) LinkCreated(outcome string) {
+	m.outcomes = append(m.outcomes, outcome)
+}
+
+func (m *recordingMetrics) CodeConflict() {
+	m.conflicts++
+}
+
+func TestCreateShortURL_ReportsOutcomes(t *testing.T) {
+	metrics := &recordingMetrics{}
+	repo := &mockRepo{createErr: ErrConflict}
+	svc := New(repo, &mockCache{}, "http://localhost:8080", time.Hour, 2*time.Second, WithMetrics(metrics))
+	ctx := context.Background()
+
+	svc.CreateShortURL(ctx, models.CreateURLOptions{OriginalURL: "https://example.com"})
+	svc.CreateShortURL(ctx, models.CreateURLOptions{OriginalURL: "example.com"})
+	repo.createErr = nil
+	repo.urlByOriginal = &models.URL{ID: 1, ShortCode: "abc123", OriginalURL: "https://example.com"}
+	svc.CreateShortURL(ctx, models.CreateURLOptions{OriginalURL: "https://example.com"})
+	svc.CreateShortURL(ctx, models.CreateURLOptions{OriginalURL: "https://example.com", CustomCode: "custom"})
+
+	want := []string{OutcomeConflict, OutcomeInvalid, OutcomeExisting, OutcomeCreated}
+	if strings.Join(metrics.outcomes, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected outcomes %v, got %v", want, metrics.outcomes)
+	}
+	if metrics.conflicts != maxCodeAttempts {
+		t.Fatalf("expected %d conflicts, got %d", maxCodeAttempts, metrics.conflicts)
+	}
+}
+
+func TestGetFullURL_ClickFlushSpanLinksToRedirect(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	repo := &mockRepo{urlByShortCode: &models.URL{ID: 1, ShortCode: "abc", OriginalURL: "https://example.com"}}
+	svc := New(repo, &missCache{}, "http://localhost:8080", time.Hour, 2*time.Second, WithClickFlushInterval(time.Hour))
+
+	if _, err := svc.GetFullURL(context.Background(), "abc", models.ClickInfo{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := svc.Close(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	redirect, flush := spans["Service.GetFullURL"], spans["clicks.flush"]
+	if redirect == nil || flush == nil {
+		t.Fatalf("expected redirect and flush spans, got %v", spans)
+	}
+	if flush.Parent().IsValid() {
+		t.Fatal("expected the flush span to start its own trace")
+	}
+	if len(flush.Links()) != 1 || flush.Links()[0].SpanContext.SpanID() != redirect.SpanContext().SpanID() {
+		t.Fatalf("expected the flush span to link to the redirect, got %v", flush.Links())
+	}
+}
+
+func TestCleanupExpiredURLs_DeletesInBatches(t *testing.T) {
+	repo := &mockRepo{expired: 25}
+	svc := New(repo, &mockCache{}, "http://localhost:8080", time.Hour, 2*time.Second, WithCleanupBatchSize(10))
+
+	deleted, err := svc.CleanupExpiredURLs(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if deleted != 25 {
+		t.Fatalf("expected 25 deleted, got %d", deleted)
+	}
+	if repo.deleteCalls != 3 {
+		t.Fatalf("expected 3 batches, got %d", repo.deleteCalls)
+	}
+}
+
+type mockArchive struct {
+	archived []models.URLDetails
+	found    []models.ArchivedURL
+	cutoff   time.Time
+}
+
+func (m *mockArchive) ArchiveURLs(_ context.Context, urls []models.URLDetails) error {
+	m.archived = append(m.archived, urls...)
+	return nil
+}
+
+func (m *mockArchive) GetArchivedURLs(_ context.Context, _ string) ([]models.ArchivedURL, error) {
+	return m.found, nil
+}
+
+func (m *mockArchive) PurgeArchivedURLs(_ context.Context, cutoff time.Time) (int64, error) {
+	m.cutoff = cutoff
+	return 1, nil
+}
+
+func TestCleanupExpiredURLs_ArchivesEachBatch(t *testing.T) {
+	repo := &mockRepo{expired: 25}
+	archive := &mockArchive{}
+	svc := New(repo, &mockCache{}, "http://localhost:8080", time.Hour, 2*time.Second,
+		WithCleanupBatchSize(10), WithArchive(archive, 0))
+
+	deleted, err := svc.CleanupExpiredURLs(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if deleted != 25 || len(archive.archived) != 25 {
+		t.Fatalf("expected 25 deleted and archived, got %d and %d", deleted, len(archive.archived))
+	}
+}
+
+func TestGetArchivedURLs(t *testing.T) {
+	svc := New(&mockRepo{}, &mockCache{}, "http://localhost:8080", time.Hour, 2*time.Second)
+	if _, err := svc.GetArchivedURLs(context.Background(), "abc123"); !errors.Is(err, ErrArchiveDisabled) {
+		t.Fatalf("expected ErrArchiveDisabled, got %v", err)
+	}
+
+	archive := &mockArchive{}
+	svc = New(&mockRepo{}, &mockCache{}, "http://localhost:8080", time.Hour, 2*time.Second, WithArchive(archive, 0))
+	if _, err := svc.GetArchivedURLs(context.Background(), "abc123"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	archive.found = []models.ArchivedURL{{URLDetails: models.URLDetails{URL: models.URL{ShortCode: "abc123"}}}}
+	urls, err := svc.GetArchivedURLs(context.Background(), "abc123")
+	if err != nil || len(urls) != 1 {
+		t.Fatalf("expected one archived link, got %v, %v", urls, err)
+	}
+}
+
+func TestPurgeArchive_UsesRetention(t *testing.T) {
+	archive := &mockArchive{}
+	svc := New(&mockRepo{}, &mockCache{}, "http://localhost:8080", time.Hour, 2*time.Second, WithArchive(archive, 0))
+	if purged, err := svc.PurgeArchive(context.Background()); err != nil || purged != 0 || !archive.cutoff.IsZero() {
+		t.Fatalf("expected no purge without retention, got %d, %v", purged, err)
+	}
+
+	svc = New(&mockRepo{}, &mockCache{}, "http://localhost:8080", time.Hour, 2*time.Second, WithArchive(archive, 24*time.Hour))
+	if _, err := svc.PurgeArchive(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if age := time.Since(archive.cutoff); age < 24*time.Hour || age > 24*time.Hour+time.Minute {
+		t.Fatalf("expected a cutoff 24h ago, got %s ago", age)
+	}
+}
+
+func TestDestinationPolicy_Check(t *testing.T) {
+	policy := DestinationPolicy{
+		AllowedDomains: []string{"example.com", "*.example.com", "*.example.org", "127.0.0.1"},
+		BlockedDomains: []string{"bad.example.com", "*.ads.example.org"},
+	}
+
+	tests := []struct {
+		url  string
+		want error
+	}{
+		{"https://example.com/path", nil},
+		{"http://www.example.com:8080", nil},
+		{"https://WWW.Example.COM./", nil},
+		{"https://cdn.example.org", nil},
+		{"not a url", ErrInvalidURL},
+		{"https://", ErrInvalidURL},
+		{"javascript:alert(1)", ErrSchemeNotAllowed},
+		{"data:text/html,hi", ErrSchemeNotAllowed},
+		{"file:///etc/passwd", ErrSchemeNotAllowed},
+		{"ftp://example.com/file", ErrSchemeNotAllowed},
+		{"https://example.org", ErrDomainNotAllowed},
+		{"https://notexample.com", ErrDomainNotAllowed},
+		{"https://bad.example.com", ErrDomainBlocked},
+		{"https://x.ads.example.org", ErrDomainBlocked},
+		{"http://127.0.0.1/admin", ErrPrivateAddress},
+	}
+	for _, tt := range tests {
+		err := policy.Check(tt.url)
+		if tt.want == nil && err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.url, err)
+		}
+		if tt.want != nil && !errors.Is(err, tt.want) {
+			t.Fatalf("%s: expected %v, got %v", tt.url, tt.want, err)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidURL) {
+			t.Fatalf("%s: expected the error to wrap ErrInvalidURL, got %v", tt.url, err)
+		}
+	}
+}
+
+func TestDestinationPolicy_PrivateAddresses(t *testing.T) {
+	private := []string{
+		"http://localhost",
+		"http://api.localhost:3000",
+		"http://127.0.0.1",
+		"http://10.1.2.3",
+		"http://172.16.0.1",
+		"http://192.168.1.1",
+		"http://169.254.169.254/latest/meta-data",
+		"http://0.0.0.0",
+		"http://[::1]:8080",
+		"http://[fd00::1]",
+		"http://[fe80::1]",
+		"http://[::ffff:10.0.0.1]",
+		"http://2130706433",
+		"http://127.1",
+		"http://0x7f.0.0.1",
+		"http://0177.0.0.1",
+		"http://0xa000001",
+	}
+	for _, rawURL := range private {
+		if err := (DestinationPolicy{}).Check(rawURL); !errors.Is(err, ErrPrivateAddress) {
+			t.Fatalf("%s: expected ErrPrivateAddress, got %v", rawURL, err)
+		}
+		if err := (DestinationPolicy{AllowPrivateAddresses: true}).Check(rawURL); err != nil {
+			t.Fatalf("%s: expected private addresses to be allowed, got %v", rawURL, err)
+		}
+	}
+
+	for _, rawURL := range []string{"http://8.8.8.8", "http://[2001:4860:4860::8888]", "http://1.example.com", "http://256.0.0.1.example"} {
+		if err := (DestinationPolicy{}).Check(rawURL); err != nil {
+			t.Fatalf("%s: unexpected error: %v", rawURL, err)
+		}
+	}
+}
+
+func TestCreateShortURL_EnforcesDestinationPolicy(t *testing.T) {
+	repo := &mockRepo{}
+	svc := New(repo, &mockCache{}, "http://localhost:8080", time.Hour, 2*time.Second,
+		WithDestinationPolicy(DestinationPolicy{Schemes: []string{"https"}}))
+
+	_, err := svc.CreateShortURL(context.Background(), models.CreateURLOptions{OriginalURL: "http://example.com"})
+	if !errors.Is(err, ErrSchemeNotAllowed) {
+		t.Fatalf("expected ErrSchemeNotAllowed, got %v", err)
+	}
+
+	results, err := svc.CreateShortURLBatch(context.Background(), []models.CreateURLOptions{
+		{OriginalURL: "https://example.com"},
+		{OriginalURL: "https://192.168.0.1"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if results[0].Err != nil || !errors.Is(results[1].Err, ErrPrivateAddress) {
+		t.Fatalf("unexpected results: %v, %v", results[0].Err, results[1].Err)
+	}
+
+	_, err = svc.UpdateURL(context.Background(), "abc123", models.UpdateURLOptions{OriginalURL: new("https://127.0.0.1")})
+	if !errors.Is(err, ErrPrivateAddress) {
+		t.Fatalf("expected ErrPrivateAddress, got %v", err)
+	}
+}
+
+func TestCreateShortURL_ResolvesSelfLinks(t *testing.T) {
+	repo := &mockRepo{urlByShortCode: &models.URL{ID: 1, ShortCode: "abc123", OriginalURL: "https://example.com/target", Status: models.URLStatusActive}}
+	svc := New(repo, &mockCache{}, "https://sho.rt", time.Hour, 2*time.Second,
+		WithSelfLinks([]string{"Go.Example"}, true))
+
+	for _, submitted := range []string{"https://SHO.RT/abc123", "http://go.example:8443/v1/abc123"} {
+		url, err := svc.CreateShortURL(context.Background(), models.CreateURLOptions{OriginalURL: submitted})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", submitted, err)
+		}
+		if url.OriginalURL != "https://example.com/target" || url.ResolvedFrom != submitted {
+			t.Fatalf("%s: expected the link's target, got %+v", submitted, url)
+		}
+	}
+
+	results, err := svc.CreateShortURLBatch(context.Background(), []models.CreateURLOptions{
+		{OriginalURL: "https://sho.rt/abc123"},
+		{OriginalURL: "https://example.com/other"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if results[0].URL.OriginalURL != "https://example.com/target" || results[0].URL.ResolvedFrom != "https://sho.rt/abc123" {
+		t.Fatalf("unexpected first result: %+v", results[0].URL)
+	}
+	if results[1].URL.ResolvedFrom != "" {
+		t.Fatalf("expected the second item not to be resolved, got %+v", results[1].URL)
+	}
+
+	details, err := svc.UpdateURL(context.Background(), "xyz789", models.UpdateURLOptions{OriginalURL: new("https://sho.rt/abc123")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if details.OriginalURL != "https://example.com/target" {
+		t.Fatalf("expected the edit to store the link's target, got %q", details.OriginalURL)
+	}
+}
+
+func TestCreateShortURL_RefusesUnresolvableSelfLinks(t *testing.T) {
+	tests := []struct {
+		name   string
+		target *models.URL
+		url    string
+	}{
+		{name: "unknown code", url: "https://sho.rt/abc123"},
+		{name: "not a short link", target: &models.URL{ID: 1, OriginalURL: "https://example.com", Status: models.URLStatusActive}, url: "https://sho.rt/v1/urls/abc123"},
+		{name: "disabled link", target: &models.URL{ID: 1, OriginalURL: "https://example.com", Status: models.URLStatusDisabled}, url: "https://sho.rt/abc123"},
+		{name: "loop", target: &models.URL{ID: 1, OriginalURL: "https://sho.rt/abc123", Status: models.URLStatusActive}, url: "https://sho.rt/abc123"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &mockRepo{urlByShortCode: tt.target}
+			svc := New(repo, &mockCache{}, "https://sho.rt", time.Hour, 2*time.Second, WithSelfLinks(nil, true))
+
+			_, err := svc.CreateShortURL(context.Background(), models.CreateURLOptions{OriginalURL: tt.url})
+			if !errors.Is(err, ErrSelfLink) || !errors.Is(err, ErrInvalidURL) {
+				t.Fatalf("expected ErrSelfLink, got %v", err)
+			}
+			if repo.createCalls != 0 {
+				t.Fatal("expected no link to be created")
+			}
+		})
+	}
+}
+
+func TestCreateShortURL_RejectsSelfLinks(t *testing.T) {
+	repo := &mockRepo{urlByShortCode: &models.URL{ID: 1, ShortCode: "abc123", OriginalURL: "https://example.com/target", Status: models.URLStatusActive}}
+	svc := New(repo, &mockCache{}, "https://sho.rt/s", time.Hour, 2*time.Second)
+
+	_, err := svc.CreateShortURL(context.Background(), models.CreateURLOptions{OriginalURL: "https://sho.rt/s/abc123"})
+	if !errors.Is(err, ErrSelfLink) {
+		t.Fatalf("expected ErrSelfLink, got %v", err)
+	}
+	if repo.getByShortCodeCalls != 0 {
+		t.Fatal("expected rejected self links not to be looked up")
+	}
+
+	_, err = svc.UpdateURL(context.Background(), "xyz789", models.UpdateURLOptions{OriginalURL: new("https://sho.rt/s/abc123")})
+	if !errors.Is(err, ErrSelfLink) {
+		t.Fatalf("expected ErrSelfLink, got %v", err)
+	}
+}
+
+func TestImportCheck(t *testing.T) {
+	check := NewImportCheck(DestinationPolicy{BlockedDomains: []string{"evil.example"}}, "https://sho.rt/s", []string{"go.sho.rt"})
+
+	tests := []struct {
+		rawURL string
+		want   error
+	}{
+		{"https://example.com/page", nil},
+		{"not a url", ErrInvalidURL},
+		{"javascript:alert(1)", ErrInvalidURL},
+		{"file:///etc/passwd", ErrInvalidURL},
+		{"https://evil.example/", ErrDomainBlocked},
+		{"http://10.0.0.1/admin", ErrPrivateAddress},
+		{"https://sho.rt/s/abc123", ErrSelfLink},
+		{"https://go.sho.rt/abc123", ErrSelfLink},
+	}
+	for _, tt := range tests {
+		err := check.Check(tt.rawURL)
+		if tt.want == nil && err != nil || tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("Check(%q) = %v, want %v", tt.rawURL, err, tt.want)
+		}
+	}
+}
+
+// flakyFilter is a code filter that fails the next addFailures adds, and
+// reports itself lost while lost is set.
+type flakyFilter struct {
+	*bloom.Filter
+	addFailures atomic.Int32
+	lost        atomic.Bool
+}
+
+func (f *flakyFilter) Add(ctx context.Context, code string) error {
+	if f.addFailures.Add(-1) >= 0 {
+		return errors.New("redis down")
+	}
+	return f.Filter.Add(ctx, code)
+}
+
+func (f *flakyFilter) MayContain(ctx context.Context, code string) (bool, error) {
+	if f.lost.Load() {
+		return false, ErrCodeFilterLost
+	}
+	return f.Filter.MayContain(ctx, code)
+}
+
+func waitForCodeFilter(t *testing.T, svc *Service) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !svc.codeFilterReady.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the code filter to be reloaded")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestAddCode_RetriesReloadUntilItSucceeds(t *testing.T) {
+	repo := &mockRepo{listed: []models.URLDetails{{URL: models.URL{ID: 1, ShortCode: "known"}}}}
+	filter := &flakyFilter{Filter: bloom.New(bloom.Estimate(100, bloom.DefaultFalsePositiveRate))}
+	svc := New(repo, &mockCache{}, "http://localhost:8080", time.Hour, 2*time.Second, WithCodeFilter(filter))
+	defer svc.Close(context.Background())
+	svc.codeFilterRetry = time.Millisecond
+	if err := svc.LoadCodeFilter(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The add and the first reloads fail, as they would while Redis is down.
+	filter.addFailures.Store(4)
+	repo.listed = append(repo.listed, models.URLDetails{URL: models.URL{ID: 2, ShortCode: "fresh"}})
+	if _, err := svc.CreateShortURL(context.Background(), models.CreateURLOptions{OriginalURL: "https://example.com/new", CustomCode: "fresh"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	waitForCodeFilter(t, svc)
+	if filter.addFailures.Load() >= 0 {
+		t.Fatal("expected the reload to be retried past the failed attempts")
+	}
+	if ok, _ := filter.Filter.MayContain(context.Background(), "fresh"); !ok {
+		t.Fatal("expected the reloaded filter to hold the new code")
+	}
+}
+
+func TestGetFullURL_ReloadsLostCodeFilter(t *testing.T) {
+	repo := &mockRepo{
+		urlByShortCode: &models.URL{ID: 1, ShortCode: "known", OriginalURL: "https://example.com"},
+		listed:         []models.URLDetails{{URL: models.URL{ID: 1, ShortCode: "known"}}},
+	}
+	filter := &flakyFilter{Filter: bloom.New(bloom.Estimate(100, bloom.DefaultFalsePositiveRate))}
+	svc := New(repo, &mockCache{}, "http://localhost:8080", time.Hour, 2*time.Second, WithCodeFilter(filter))
+	defer svc.Close(context.Background())
+	svc.codeFilterRetry = time.Millisecond
+	if err := svc.LoadCodeFilter(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	filter.lost.Store(true)
+	if _, err := svc.GetFullURL(context.Background(), "known", models.ClickInfo{}); err != nil {
+		t.Fatalf("expected a lost filter to fail open, got %v", err)
+	}
+	filter.lost.Store(false)
+
+	waitForCodeFilter(t, svc)
+	if _, err := svc.GetFullURL(context.Background(), "unknown", models.ClickInfo{}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"url-shortener-go/internal/models"
+)
+
+const (
+	day = 24 * time.Hour
+
+	DefaultHourlyStatsRange = day
+	DefaultDailyStatsRange  = 30 * day
+	MaxHourlyStatsRange     = 31 * day
+	MaxDailyStatsRange      = 366 * day
+)
+
+// GetClickStats returns the clicks of a link bucketed by hour or day (UTC).
+// The range is widened to whole buckets and buckets without clicks are
+// included with zero so the series can be charted as is.
+func (s *Service) GetClickStats(ctx context.Context, shortCode string, opts models.ClickStatsOptions) (*models.ClickStats, error) {
+	interval := opts.Interval
+	if interval == "" {
+		interval = models.StatsIntervalHour
+	}
+
+	var step, defaultRange, maxRange time.Duration
+	switch interval {
+	case models.StatsIntervalHour:
+		step, defaultRange, maxRange = time.Hour, DefaultHourlyStatsRange, MaxHourlyStatsRange
+	case models.StatsIntervalDay:
+		step, defaultRange, maxRange = day, DefaultDailyStatsRange, MaxDailyStatsRange
+	default:
+		return nil, ErrInvalidFilter
+	}
+
+	to := time.Now().UTC()
+	if opts.To != nil {
+		to = opts.To.UTC()
+	}
+	from := to.Add(-defaultRange)
+	if opts.From != nil {
+		from = opts.From.UTC()
+	}
+
+	from = from.Truncate(step)
+	if aligned := to.Truncate(step); !aligned.Equal(to) {
+		to = aligned.Add(step)
+	}
+	if !from.Before(to) || to.Sub(from) > maxRange {
+		return nil, ErrInvalidStatsRange
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.requestTimeout)
+	defer cancel()
+
+	details, err := s.repo.GetDetailsByShortCode(ctx, shortCode)
+	if err != nil {
+		return nil, err
+	}
+
+	hourly, err := s.repo.GetHourlyClicks(ctx, details.ID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &models.ClickStats{
+		Interval: interval,
+		From:     from,
+		To:       to,
+		Buckets:  make([]models.ClickBucket, 0, int(to.Sub(from)/step)),
+	}
+	for start := from; start.Before(to); start = start.Add(step) {
+		stats.Buckets = append(stats.Buckets, models.ClickBucket{Start: start})
+	}
+	for _, bucket := range hourly {
+		index := int(bucket.Start.UTC().Sub(from) / step)
+		if index < 0 || index >= len(stats.Buckets) {
+			continue
+		}
+		stats.Buckets[index].Clicks += bucket.Clicks
+		stats.Total += bucket.Clicks
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,19 @@
+package service
+
+import (
+	"url-shortener-go/internal/telemetry"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("url-shortener-go/internal/service")
+
+// endSpan marks the span as failed only for outages; not found, conflicts and
+// invalid input are normal answers.
+func endSpan(span trace.Span, err error) {
+	if !isOutage(err) {
+		err = nil
+	}
+	telemetry.EndSpan(span, err)
+}
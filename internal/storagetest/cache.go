@@ -0,0 +1,186 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"url-shortener-go/internal/models"
+	"url-shortener-go/internal/service"
+)
+
+// RunCacheTests runs the cache conformance suite. newCache is called once per
+// subtest; keys are unique so a shared Redis can be used.
+func RunCacheTests(t *testing.T, newCache func(t *testing.T) service.Cache) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, cache service.Cache)
+	}{
+		{"SetGetRoundTrip", testCacheSetGetRoundTrip},
+		{"GetMissing", testCacheGetMissing},
+		{"SetOverwrites", testCacheSetOverwrites},
+		{"Delete", testCacheDelete},
+		{"DeletePrefix", testCacheDeletePrefix},
+		{"Expiration", testCacheExpiration},
+		{"ReportsTTL", testCacheReportsTTL},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.fn(t, newCache(t))
+		})
+	}
+}
+
+func testCacheSetGetRoundTrip(t *testing.T, cache service.Cache) {
+	ctx := context.Background()
+	key := unique("url:")
+	value := &models.URL{
+		ID:          42,
+		ShortCode:   "abc123",
+		OriginalURL: "https://example.com",
+		Status:      models.URLStatusActive,
+		CreatedAt:   time.Date(2026, 2, 15, 9, 0, 0, 0, time.UTC),
+		ExpiresAt:   new(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)),
+	}
+
+	if err := cache.Set(ctx, key, value, time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := cache.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got.ID != value.ID || got.ShortCode != value.ShortCode || got.OriginalURL != value.OriginalURL || got.Status != value.Status {
+		t.Fatalf("unexpected cached link: %+v", got)
+	}
+	if !got.CreatedAt.Equal(value.CreatedAt) || got.ExpiresAt == nil || !got.ExpiresAt.Equal(*value.ExpiresAt) {
+		t.Fatalf("expected timestamps to survive the round trip, got %+v", got)
+	}
+
+	got.OriginalURL = "https://mutated.example.com"
+	again, err := cache.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if again.OriginalURL != value.OriginalURL {
+		t.Fatal("expected Get to return a copy")
+	}
+}
+
+func testCacheGetMissing(t *testing.T, cache service.Cache) {
+	if _, err := cache.Get(context.Background(), unique("url:")); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func testCacheSetOverwrites(t *testing.T, cache service.Cache) {
+	ctx := context.Background()
+	key := unique("url:")
+
+	if err := cache.Set(ctx, key, &models.URL{ID: 1, OriginalURL: "https://example.com/old"}, time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := cache.Set(ctx, key, &models.URL{ID: 1, OriginalURL: "https://example.com/new"}, time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := cache.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.OriginalURL != "https://example.com/new" {
+		t.Fatalf("expected latest value, got %s", got.OriginalURL)
+	}
+}
+
+func testCacheDelete(t *testing.T, cache service.Cache) {
+	ctx := context.Background()
+	key := unique("url:")
+
+	if err := cache.Set(ctx, key, &models.URL{ID: 1}, time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := cache.Delete(ctx, key); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := cache.Get(ctx, key); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound after delete, got %v", err)
+	}
+	if err := cache.Delete(ctx, key); err != nil {
+		t.Fatalf("expected deleting a missing key to succeed, got %v", err)
+	}
+}
+
+func testCacheDeletePrefix(t *testing.T, cache service.Cache) {
+	ctx := context.Background()
+	prefix := unique("purge:") + ":"
+	purged := []string{prefix + "a", prefix + "b"}
+	kept := unique("url:")
+
+	for _, key := range append(purged, kept) {
+		if err := cache.Set(ctx, key, &models.URL{ID: 1}, time.Minute); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := cache.DeletePrefix(ctx, prefix); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, key := range purged {
+		if _, err := cache.Get(ctx, key); !errors.Is(err, service.ErrNotFound) {
+			t.Fatalf("expected %s to be purged, got %v", key, err)
+		}
+	}
+	if _, err := cache.Get(ctx, kept); err != nil {
+		t.Fatalf("expected %s to be kept, got %v", kept, err)
+	}
+}
+
+func testCacheExpiration(t *testing.T, cache service.Cache) {
+	ctx := context.Background()
+	key := unique("url:")
+
+	if err := cache.Set(ctx, key, &models.URL{ID: 1}, 50*time.Millisecond); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	time.Sleep(150 * time.Millisecond)
+
+	if _, err := cache.Get(ctx, key); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("expected entry to expire, got %v", err)
+	}
+}
+
+func testCacheReportsTTL(t *testing.T, cache service.Cache) {
+	ttlCache, ok := cache.(service.TTLCache)
+	if !ok {
+		t.Skip("cache does not report TTLs")
+	}
+	ctx := context.Background()
+
+	expiring := unique("url:")
+	if err := cache.Set(ctx, expiring, &models.URL{ID: 1}, time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, ttl, err := ttlCache.GetWithTTL(ctx, expiring)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.ID != 1 || ttl < 0 || ttl > time.Minute {
+		t.Fatalf("unexpected entry %+v with ttl %s", got, ttl)
+	}
+
+	permanent := unique("url:")
+	if err := cache.Set(ctx, permanent, &models.URL{ID: 2}, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ttl, err := ttlCache.GetWithTTL(ctx, permanent); err != nil || ttl != 0 {
+		t.Fatalf("expected no ttl for an entry without expiry, got %s, %v", ttl, err)
+	}
+
+	if _, _, err := ttlCache.GetWithTTL(ctx, unique("url:")); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
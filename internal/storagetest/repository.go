@@ -0,0 +1,545 @@
+// Package storagetest holds conformance suites that every service.Repository
+// and service.Cache implementation must pass, so the storage backends stay
+// interchangeable. Backends call the Run functions from their own tests.
+//
+// The suites only touch rows they create, using unique short codes and URLs,
+// so they can run against a shared database.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"url-shortener-go/internal/models"
+	"url-shortener-go/internal/service"
+)
+
+var uniqueCounter atomic.Int64
+
+// unique returns a token that is unique across suite runs against the same
+// database.
+func unique(prefix string) string {
+	return prefix + strconv.FormatInt(time.Now().UnixNano(), 36) + strconv.FormatInt(uniqueCounter.Add(1), 36)
+}
+
+// RunRepositoryTests runs the repository conformance suite. newRepo is called
+// once per subtest and must return a ready repository; cleanup is the
+// caller's job (for example via t.Cleanup).
+func RunRepositoryTests(t *testing.T, newRepo func(t *testing.T) service.Repository) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, repo service.Repository)
+	}{
+		{"CreateAssignsIDAndCreatedAt", testCreateAssignsIDAndCreatedAt},
+		{"CreateDuplicateShortCodeConflicts", testCreateDuplicateShortCodeConflicts},
+		{"CreateBatchReportsConflictsPerItem", testCreateBatchReportsConflictsPerItem},
+		{"GetByShortCodeUnknown", testGetByShortCodeUnknown},
+		{"GetByShortCodeExpired", testGetByShortCodeExpired},
+		{"GetByShortCodeDisabledAndDeleted", testGetByShortCodeDisabledAndDeleted},
+		{"GetByOriginalURLReturnsNewestActive", testGetByOriginalURLReturnsNewestActive},
+		{"GetByOriginalURLs", testGetByOriginalURLs},
+		{"Update", testUpdate},
+		{"List", testList},
+		{"RecordClicks", testRecordClicks},
+		{"ExportURLsIncludesDeleted", testExportURLsIncludesDeleted},
+		{"DeleteExpiredURLs", testDeleteExpiredURLs},
+		{"ArchiveExpiredURLs", testArchiveExpiredURLs},
+		{"ImportOverwriteDropsClicks", testImportOverwriteDropsClicks},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.fn(t, newRepo(t))
+		})
+	}
+}
+
+func create(t *testing.T, repo service.Repository, url *models.URL) *models.URL {
+	t.Helper()
+
+	if url.ShortCode == "" {
+		url.ShortCode = unique("c")
+	}
+	if url.OriginalURL == "" {
+		url.OriginalURL = "https://example.com/" + unique("u")
+	}
+	url.Status = models.URLStatusActive
+	if err := repo.Create(context.Background(), url); err != nil {
+		t.Fatalf("failed to create %s: %v", url.ShortCode, err)
+	}
+	return url
+}
+
+func setStatus(t *testing.T, repo service.Repository, shortCode string, status models.URLStatus) {
+	t.Helper()
+
+	if _, err := repo.SetStatus(context.Background(), shortCode, status); err != nil {
+		t.Fatalf("failed to set status of %s: %v", shortCode, err)
+	}
+}
+
+func testCreateAssignsIDAndCreatedAt(t *testing.T, repo service.Repository) {
+	url := create(t, repo, &models.URL{})
+
+	if url.ID == 0 {
+		t.Fatal("expected id to be assigned")
+	}
+	if url.CreatedAt.IsZero() {
+		t.Fatal("expected created_at to be assigned")
+	}
+
+	got, err := repo.GetByShortCode(context.Background(), url.ShortCode)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.ID != url.ID || got.OriginalURL != url.OriginalURL || got.Status != models.URLStatusActive {
+		t.Fatalf("unexpected link: %+v", got)
+	}
+}
+
+func testCreateDuplicateShortCodeConflicts(t *testing.T, repo service.Repository) {
+	first := create(t, repo, &models.URL{})
+
+	err := repo.Create(context.Background(), &models.URL{
+		ShortCode:   first.ShortCode,
+		OriginalURL: "https://example.com/" + unique("other"),
+		Status:      models.URLStatusActive,
+	})
+	if !errors.Is(err, service.ErrConflict) {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+
+	got, err := repo.GetByShortCode(context.Background(), first.ShortCode)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.OriginalURL != first.OriginalURL {
+		t.Fatalf("expected the first link to be kept, got %s", got.OriginalURL)
+	}
+}
+
+func testCreateBatchReportsConflictsPerItem(t *testing.T, repo service.Repository) {
+	taken := create(t, repo, &models.URL{})
+	fresh := unique("c")
+
+	urls := []*models.URL{
+		{ShortCode: taken.ShortCode, OriginalURL: "https://example.com/" + unique("u"), Status: models.URLStatusActive},
+		{ShortCode: fresh, OriginalURL: "https://example.com/" + unique("u"), Status: models.URLStatusActive},
+	}
+	errs, err := repo.CreateBatch(context.Background(), urls)
+	if err != nil {
+		t.Fatalf("unexpected batch error: %v", err)
+	}
+	if len(errs) != 2 || !errors.Is(errs[0], service.ErrConflict) || errs[1] != nil {
+		t.Fatalf("unexpected per-item errors: %v", errs)
+	}
+	if urls[1].ID == 0 {
+		t.Fatal("expected created item to get an id")
+	}
+	if _, err := repo.GetByShortCode(context.Background(), fresh); err != nil {
+		t.Fatalf("expected %s to be created, got %v", fresh, err)
+	}
+}
+
+func testGetByShortCodeUnknown(t *testing.T, repo service.Repository) {
+	code := unique("missing")
+
+	if _, err := repo.GetByShortCode(context.Background(), code); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := repo.GetDetailsByShortCode(context.Background(), code); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound from details, got %v", err)
+	}
+}
+
+func testGetByShortCodeExpired(t *testing.T, repo service.Repository) {
+	url := create(t, repo, &models.URL{ExpiresAt: new(time.Now().Add(-time.Hour))})
+
+	_, err := repo.GetByShortCode(context.Background(), url.ShortCode)
+	if !errors.Is(err, service.ErrExpired) || !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("expected ErrExpired wrapping ErrNotFound, got %v", err)
+	}
+
+	details, err := repo.GetDetailsByShortCode(context.Background(), url.ShortCode)
+	if err != nil {
+		t.Fatalf("expected details of an expired link, got %v", err)
+	}
+	if details.ExpiresAt == nil {
+		t.Fatal("expected expires_at in details")
+	}
+}
+
+func testGetByShortCodeDisabledAndDeleted(t *testing.T, repo service.Repository) {
+	disabled := create(t, repo, &models.URL{})
+	setStatus(t, repo, disabled.ShortCode, models.URLStatusDisabled)
+	if _, err := repo.GetByShortCode(context.Background(), disabled.ShortCode); !errors.Is(err, service.ErrDisabled) {
+		t.Fatalf("expected ErrDisabled, got %v", err)
+	}
+
+	deleted := create(t, repo, &models.URL{})
+	setStatus(t, repo, deleted.ShortCode, models.URLStatusDeleted)
+	if _, err := repo.GetByShortCode(context.Background(), deleted.ShortCode); !errors.Is(err, service.ErrDeleted) {
+		t.Fatalf("expected ErrDeleted, got %v", err)
+	}
+
+	setStatus(t, repo, deleted.ShortCode, models.URLStatusActive)
+	if _, err := repo.GetByShortCode(context.Background(), deleted.ShortCode); err != nil {
+		t.Fatalf("expected restored link to resolve, got %v", err)
+	}
+
+	if _, err := repo.SetStatus(context.Background(), unique("missing"), models.URLStatusDisabled); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for unknown code, got %v", err)
+	}
+}
+
+func testGetByOriginalURLReturnsNewestActive(t *testing.T, repo service.Repository) {
+	ctx := context.Background()
+	originalURL := "https://example.com/" + unique("shared")
+
+	if _, err := repo.GetByOriginalURL(ctx, originalURL); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	older := create(t, repo, &models.URL{OriginalURL: originalURL})
+	newer := create(t, repo, &models.URL{OriginalURL: originalURL})
+	create(t, repo, &models.URL{OriginalURL: originalURL, ExpiresAt: new(time.Now().Add(-time.Hour))})
+
+	got, err := repo.GetByOriginalURL(ctx, originalURL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.ShortCode != newer.ShortCode {
+		t.Fatalf("expected newest active link %s, got %s", newer.ShortCode, got.ShortCode)
+	}
+
+	setStatus(t, repo, newer.ShortCode, models.URLStatusDisabled)
+	got, err = repo.GetByOriginalURL(ctx, originalURL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.ShortCode != older.ShortCode {
+		t.Fatalf("expected %s once the newer link is disabled, got %s", older.ShortCode, got.ShortCode)
+	}
+}
+
+func testGetByOriginalURLs(t *testing.T, repo service.Repository) {
+	first := create(t, repo, &models.URL{})
+	second := create(t, repo, &models.URL{})
+	missing := "https://example.com/" + unique("missing")
+
+	urls, err := repo.GetByOriginalURLs(context.Background(), []string{first.OriginalURL, second.OriginalURL, missing})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(urls) != 2 {
+		t.Fatalf("expected 2 matches, got %d", len(urls))
+	}
+	if urls[first.OriginalURL].ShortCode != first.ShortCode || urls[second.OriginalURL].ShortCode != second.ShortCode {
+		t.Fatalf("unexpected matches: %+v", urls)
+	}
+}
+
+func testUpdate(t *testing.T, repo service.Repository) {
+	ctx := context.Background()
+	url := create(t, repo, &models.URL{})
+
+	destination := "https://example.com/" + unique("moved")
+	// A non-UTC offset must be kept, not stored as UTC wall clock time.
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second).In(time.FixedZone("UTC+2", 2*60*60))
+	details, err := repo.Update(ctx, url.ShortCode, models.UpdateURLOptions{
+		OriginalURL: &destination,
+		ExpiresAt:   &expiresAt,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if details.OriginalURL != destination || details.ExpiresAt == nil || !details.ExpiresAt.Equal(expiresAt) {
+		t.Fatalf("unexpected update result: %+v", details)
+	}
+
+	details, err = repo.Update(ctx, url.ShortCode, models.UpdateURLOptions{ClearExpiresAt: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if details.OriginalURL != destination || details.ExpiresAt != nil {
+		t.Fatalf("expected only the expiry to be cleared, got %+v", details)
+	}
+
+	setStatus(t, repo, url.ShortCode, models.URLStatusDeleted)
+	if _, err := repo.Update(ctx, url.ShortCode, models.UpdateURLOptions{OriginalURL: &destination}); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("expected deleted link to be immutable, got %v", err)
+	}
+}
+
+func testList(t *testing.T, repo service.Repository) {
+	ctx := context.Background()
+	token := unique("list")
+
+	first := create(t, repo, &models.URL{OriginalURL: "https://example.com/" + token + "/a"})
+	second := create(t, repo, &models.URL{OriginalURL: "https://example.com/" + token + "/b"})
+	third := create(t, repo, &models.URL{OriginalURL: "https://example.com/" + token + "/c"})
+	deleted := create(t, repo, &models.URL{OriginalURL: "https://example.com/" + token + "/d"})
+	setStatus(t, repo, deleted.ShortCode, models.URLStatusDeleted)
+
+	page, err := repo.List(ctx, models.ListURLsFilter{Limit: 2, Query: token})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(page) != 2 || page[0].ShortCode != third.ShortCode || page[1].ShortCode != second.ShortCode {
+		t.Fatalf("expected newest non-deleted links first, got %+v", page)
+	}
+
+	page, err = repo.List(ctx, models.ListURLsFilter{Limit: 2, Query: token, AfterID: page[1].ID})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(page) != 1 || page[0].ShortCode != first.ShortCode {
+		t.Fatalf("expected the oldest link on the second page, got %+v", page)
+	}
+
+	page, err = repo.List(ctx, models.ListURLsFilter{Limit: 10, Query: token, Status: models.ListStatusDeleted})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(page) != 1 || page[0].ShortCode != deleted.ShortCode {
+		t.Fatalf("expected only the deleted link, got %+v", page)
+	}
+
+	// Bounds with an offset compare as instants.
+	zone := time.FixedZone("UTC+2", 2*60*60)
+	after := new(first.CreatedAt.Add(-time.Second).In(zone))
+	before := new(third.CreatedAt.Add(time.Second).In(zone))
+	page, err = repo.List(ctx, models.ListURLsFilter{Limit: 10, Query: token, CreatedAfter: after, CreatedBefore: before})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(page) != 3 {
+		t.Fatalf("expected the three links created in range, got %+v", page)
+	}
+}
+
+func testRecordClicks(t *testing.T, repo service.Repository) {
+	ctx := context.Background()
+	url := create(t, repo, &models.URL{})
+
+	hour := time.Now().UTC().Truncate(time.Hour)
+	clickedAt := hour.Add(10 * time.Minute)
+	counts := []models.ClickCount{
+		{URLID: url.ID, Hour: hour.Add(-time.Hour), Count: 2, LastClickedAt: hour.Add(-time.Minute)},
+		{URLID: url.ID, Hour: hour, Count: 3, LastClickedAt: clickedAt},
+	}
+	events := []models.Click{{URLID: url.ID, ClickedAt: clickedAt, Referrer: "https://referrer.example.com"}}
+	if err := repo.RecordClicks(ctx, counts, events); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	details, err := repo.GetDetailsByShortCode(ctx, url.ShortCode)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if details.ClickCount != 5 {
+		t.Fatalf("expected click count 5, got %d", details.ClickCount)
+	}
+	if details.LastClickedAt == nil || details.LastClickedAt.Unix() != clickedAt.Unix() {
+		t.Fatalf("expected last clicked at %s, got %v", clickedAt, details.LastClickedAt)
+	}
+
+	buckets, err := repo.GetHourlyClicks(ctx, url.ID, hour.Add(-time.Hour), hour.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(buckets) != 2 || buckets[0].Clicks != 2 || buckets[1].Clicks != 3 {
+		t.Fatalf("unexpected hourly buckets: %+v", buckets)
+	}
+	if !buckets[1].Start.Equal(hour) {
+		t.Fatalf("expected bucket at %s, got %s", hour, buckets[1].Start)
+	}
+
+	buckets, err = repo.GetHourlyClicks(ctx, url.ID, hour, hour.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(buckets) != 1 {
+		t.Fatalf("expected the range end to be exclusive and start inclusive, got %+v", buckets)
+	}
+}
+
+// importer is implemented by repositories that cmd/import can write to.
+type importer interface {
+	Import(ctx context.Context, details *models.URLDetails, overwrite bool) error
+}
+
+func testImportOverwriteDropsClicks(t *testing.T, repo service.Repository) {
+	imp, ok := repo.(importer)
+	if !ok {
+		t.Skip("repository does not support imports")
+	}
+	ctx := context.Background()
+	url := create(t, repo, &models.URL{})
+
+	hour := time.Now().UTC().Truncate(time.Hour)
+	counts := []models.ClickCount{{URLID: url.ID, Hour: hour, Count: 3, LastClickedAt: hour}}
+	events := []models.Click{{URLID: url.ID, ClickedAt: hour}}
+	if err := repo.RecordClicks(ctx, counts, events); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	details := &models.URLDetails{
+		URL:        models.URL{ShortCode: url.ShortCode, OriginalURL: "https://example.com/" + unique("u")},
+		ClickCount: 7,
+	}
+	if err := imp.Import(ctx, details, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	buckets, err := repo.GetHourlyClicks(ctx, details.ID, hour.Add(-time.Hour), hour.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(buckets) != 0 {
+		t.Fatalf("expected the replaced link's hourly clicks to be gone, got %+v", buckets)
+	}
+	got, err := repo.GetDetailsByShortCode(ctx, url.ShortCode)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.ClickCount != 7 || got.OriginalURL != details.OriginalURL {
+		t.Fatalf("expected the imported link and count, got %+v", got)
+	}
+}
+
+func testExportURLsIncludesDeleted(t *testing.T, repo service.Repository) {
+	active := create(t, repo, &models.URL{})
+	deleted := create(t, repo, &models.URL{})
+	setStatus(t, repo, deleted.ShortCode, models.URLStatusDeleted)
+
+	seen := make(map[string]models.URLStatus)
+	lastID := 0
+	err := repo.ExportURLs(context.Background(), func(details *models.URLDetails) error {
+		if details.ID <= lastID {
+			t.Errorf("expected ascending ids, got %d after %d", details.ID, lastID)
+		}
+		lastID = details.ID
+		seen[details.ShortCode] = details.Status
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if seen[active.ShortCode] != models.URLStatusActive || seen[deleted.ShortCode] != models.URLStatusDeleted {
+		t.Fatalf("expected both links in the export, got %v and %v", seen[active.ShortCode], seen[deleted.ShortCode])
+	}
+
+	stop := errors.New("stop")
+	if err := repo.ExportURLs(context.Background(), func(*models.URLDetails) error { return stop }); !errors.Is(err, stop) {
+		t.Fatalf("expected callback error to be returned, got %v", err)
+	}
+}
+
+func testDeleteExpiredURLs(t *testing.T, repo service.Repository) {
+	ctx := context.Background()
+	var expired []*models.URL
+	for range 3 {
+		expired = append(expired, create(t, repo, &models.URL{ExpiresAt: new(time.Now().Add(-time.Hour))}))
+	}
+	live := create(t, repo, &models.URL{ExpiresAt: new(time.Now().Add(time.Hour))})
+
+	// Other runs may have left expired rows behind, so only the batch limit
+	// and the end state are checked.
+	const limit = 2
+	deleted, err := repo.DeleteExpiredURLs(ctx, limit, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if deleted != limit {
+		t.Fatalf("expected a full batch of %d, got %d", limit, deleted)
+	}
+	for deleted == limit {
+		if deleted, err = repo.DeleteExpiredURLs(ctx, limit, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	for _, url := range expired {
+		if _, err := repo.GetDetailsByShortCode(ctx, url.ShortCode); !errors.Is(err, service.ErrNotFound) {
+			t.Fatalf("expected expired link to be removed, got %v", err)
+		}
+	}
+	if _, err := repo.GetByShortCode(ctx, live.ShortCode); err != nil {
+		t.Fatalf("expected live link to be kept, got %v", err)
+	}
+}
+
+func testArchiveExpiredURLs(t *testing.T, repo service.Repository) {
+	archive, ok := repo.(service.Archive)
+	if !ok {
+		t.Skip("repository has no archive")
+	}
+
+	ctx := context.Background()
+	url := create(t, repo, &models.URL{ExpiresAt: new(time.Now().Add(-time.Hour))})
+	clickedAt := time.Now().UTC().Add(-2 * time.Hour)
+	counts := []models.ClickCount{{URLID: url.ID, Hour: clickedAt.Truncate(time.Hour), Count: 2, LastClickedAt: clickedAt}}
+	if err := repo.RecordClicks(ctx, counts, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	archiveErr := errors.New("archive unavailable")
+	_, err := repo.DeleteExpiredURLs(ctx, 1000, func(context.Context, []models.URLDetails) error { return archiveErr })
+	if !errors.Is(err, archiveErr) {
+		t.Fatalf("expected archive error to be returned, got %v", err)
+	}
+	if _, err := repo.GetDetailsByShortCode(ctx, url.ShortCode); err != nil {
+		t.Fatalf("expected link to be kept when archiving fails, got %v", err)
+	}
+
+	const limit = 1000
+	for {
+		deleted, err := repo.DeleteExpiredURLs(ctx, limit, archive.ArchiveURLs)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if deleted < limit {
+			break
+		}
+	}
+	if _, err := repo.GetDetailsByShortCode(ctx, url.ShortCode); !errors.Is(err, service.ErrNotFound) {
+		t.Fatalf("expected expired link to be removed, got %v", err)
+	}
+
+	archived, err := archive.GetArchivedURLs(ctx, url.ShortCode)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(archived) != 1 {
+		t.Fatalf("expected one archived link, got %d", len(archived))
+	}
+	got := archived[0]
+	if got.ID != url.ID || got.OriginalURL != url.OriginalURL || got.ExpiresAt == nil || got.ArchivedAt.IsZero() {
+		t.Fatalf("unexpected archived link: %+v", got)
+	}
+	if got.ClickCount != 2 || got.LastClickedAt == nil || got.LastClickedAt.Unix() != clickedAt.Unix() {
+		t.Fatalf("expected archived click stats, got %d and %v", got.ClickCount, got.LastClickedAt)
+	}
+
+	if _, err := archive.PurgeArchivedURLs(ctx, time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if archived, _ := archive.GetArchivedURLs(ctx, url.ShortCode); len(archived) != 1 {
+		t.Fatalf("expected a recent archived link to be kept, got %d", len(archived))
+	}
+	purged, err := archive.PurgeArchivedURLs(ctx, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if purged < 1 {
+		t.Fatalf("expected the archived link to be purged, got %d", purged)
+	}
+	if archived, _ := archive.GetArchivedURLs(ctx, url.ShortCode); len(archived) != 0 {
+		t.Fatalf("expected no archived links after purge, got %d", len(archived))
+	}
+}
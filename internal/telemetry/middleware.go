@@ -50,6 +50,7 @@ func LoggingMiddleware(logger *slog.Logger) func(http.Handler) http.Handler {
 				"bytes", recorder.bytes,
 				"duration_ms", time.Since(start).Milliseconds(),
 				"request_id", GetRequestID(r.Context()),
+				"trace_id", GetTraceID(r.Context()),
 			)
 		})
 	}
@@ -60,6 +61,10 @@ func RecoveryMiddleware(logger *slog.Logger) func(http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			defer func() {
 				if err := recover(); err != nil {
+					if err == http.ErrAbortHandler {
+						// Deliberate aborts are handled by net/http.
+						panic(err)
+					}
 					logger.Error(
 						"panic recovered",
 						"error", err,
@@ -91,6 +96,13 @@ func (r *responseRecorder) Write(data []byte) (int, error) {
 	return size, err
 }
 
+// Unwrap lets http.ResponseController reach the underlying writer. Without
+// it, Flush and SetWriteDeadline fail behind this middleware and streaming
+// exports are cut off at the server's write timeout.
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
 func newRequestID() string {
 	var b [16]byte
 	if _, err := rand.Read(b[:]); err != nil {
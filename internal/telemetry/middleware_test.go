@@ -0,0 +1,35 @@
+package telemetry
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLoggingMiddleware_SupportsResponseController(t *testing.T) {
+	errs := make(chan error, 2)
+	handler := LoggingMiddleware(slog.New(slog.NewTextHandler(io.Discard, nil)))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		controller := http.NewResponseController(w)
+		errs <- controller.SetWriteDeadline(time.Time{})
+		w.Write([]byte("streamed"))
+		errs <- controller.Flush()
+	}))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if err := <-errs; err != nil {
+		t.Fatalf("expected SetWriteDeadline to reach the connection, got %v", err)
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("expected Flush to reach the connection, got %v", err)
+	}
+}
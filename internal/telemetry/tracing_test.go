@@ -0,0 +1,69 @@
+package telemetry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestTracingMiddleware(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	var handlerTraceID string
+	router := mux.NewRouter()
+	router.HandleFunc("/v1/urls/{code}", func(w http.ResponseWriter, r *http.Request) {
+		handlerTraceID = GetTraceID(r.Context())
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	router.Use(RequestIDMiddleware)
+	router.Use(TracingMiddleware)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/urls/abc123", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected one span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "GET /v1/urls/{code}" {
+		t.Fatalf("expected the span to be named after the route template, got %q", span.Name())
+	}
+	if span.SpanKind() != trace.SpanKindServer {
+		t.Fatalf("expected a server span, got %s", span.SpanKind())
+	}
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	if span.SpanContext().TraceID().String() != traceID {
+		t.Fatalf("expected the caller's trace to be continued, got %s", span.SpanContext().TraceID())
+	}
+	if handlerTraceID != traceID || rec.Header().Get("X-Trace-ID") != traceID {
+		t.Fatalf("expected trace ID in context and header, got %q and %q", handlerTraceID, rec.Header().Get("X-Trace-ID"))
+	}
+
+	attrs := attribute.NewSet(span.Attributes()...)
+	if value, _ := attrs.Value("request.id"); value.AsString() != "req-1" {
+		t.Fatalf("expected request.id attribute, got %q", value.AsString())
+	}
+	if value, _ := attrs.Value("http.response.status_code"); value.AsInt64() != http.StatusInternalServerError {
+		t.Fatalf("expected status code attribute, got %d", value.AsInt64())
+	}
+	if span.Status().Code.String() != "Error" {
+		t.Fatalf("expected a 5xx to mark the span as failed, got %s", span.Status().Code)
+	}
+}
@@ -0,0 +1,37 @@
+package transfer
+
+import "url-shortener-go/internal/models"
+
+// FromDetails converts a stored link into a record.
+func FromDetails(details *models.URLDetails) Record {
+	record := Record{
+		Code:          details.ShortCode,
+		OriginalURL:   details.OriginalURL,
+		Status:        string(details.Status),
+		ExpiresAt:     details.ExpiresAt,
+		ClickCount:    details.ClickCount,
+		LastClickedAt: details.LastClickedAt,
+	}
+	if !details.CreatedAt.IsZero() {
+		record.CreatedAt = new(details.CreatedAt)
+	}
+	return record
+}
+
+// Details converts a record into a link ready to be stored.
+func (r Record) Details() *models.URLDetails {
+	details := &models.URLDetails{
+		URL: models.URL{
+			ShortCode:   r.Code,
+			OriginalURL: r.OriginalURL,
+			Status:      models.URLStatus(r.Status),
+			ExpiresAt:   r.ExpiresAt,
+		},
+		ClickCount:    r.ClickCount,
+		LastClickedAt: r.LastClickedAt,
+	}
+	if r.CreatedAt != nil {
+		details.CreatedAt = *r.CreatedAt
+	}
+	return details
+}
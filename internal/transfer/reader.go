@@ -0,0 +1,184 @@
+package transfer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Reader yields records until io.EOF. A *RecordError describes one bad
+// record and the next call continues with the following one; any other
+// error is fatal.
+type Reader interface {
+	Read() (Record, error)
+	// Line is the input line of the last record returned by Read.
+	Line() int
+}
+
+func NewReader(r io.Reader, format Format) (Reader, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVReader(r)
+	case FormatJSONL:
+		return newJSONLReader(r), nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+}
+
+type csvReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+	line    int
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read CSV header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"code", "original_url"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("CSV header is missing column %q", required)
+		}
+	}
+
+	return &csvReader{reader: reader, columns: columns}, nil
+}
+
+func (c *csvReader) Line() int {
+	return c.line
+}
+
+func (c *csvReader) Read() (Record, error) {
+	row, err := c.reader.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			c.line = parseErr.StartLine
+			return Record{}, &RecordError{Line: parseErr.StartLine, Err: parseErr.Err}
+		}
+		return Record{}, err
+	}
+	line, _ := c.reader.FieldPos(0)
+	c.line = line
+
+	field := func(name string) string {
+		if i, ok := c.columns[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	record := Record{
+		Code:        field("code"),
+		OriginalURL: field("original_url"),
+		Status:      field("status"),
+	}
+	for _, ts := range []struct {
+		name   string
+		target **time.Time
+	}{
+		{"created_at", &record.CreatedAt},
+		{"expires_at", &record.ExpiresAt},
+		{"last_clicked_at", &record.LastClickedAt},
+	} {
+		value, err := parseTime(field(ts.name))
+		if err != nil {
+			return Record{}, &RecordError{Line: line, Err: fmt.Errorf("%s: %w", ts.name, err)}
+		}
+		*ts.target = value
+	}
+	if raw := field("click_count"); raw != "" {
+		count, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return Record{}, &RecordError{Line: line, Err: fmt.Errorf("click_count: %w", err)}
+		}
+		record.ClickCount = count
+	}
+
+	if err := record.validate(); err != nil {
+		return Record{}, &RecordError{Line: line, Err: err}
+	}
+
+	return record, nil
+}
+
+type jsonlReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func newJSONLReader(r io.Reader) *jsonlReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	return &jsonlReader{scanner: scanner}
+}
+
+func (j *jsonlReader) Line() int {
+	return j.line
+}
+
+func (j *jsonlReader) Read() (Record, error) {
+	for j.scanner.Scan() {
+		j.line++
+		data := strings.TrimSpace(j.scanner.Text())
+		if data == "" {
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal([]byte(data), &record); err != nil {
+			return Record{}, &RecordError{Line: j.line, Err: err}
+		}
+		if err := record.validate(); err != nil {
+			return Record{}, &RecordError{Line: j.line, Err: err}
+		}
+		return record, nil
+	}
+	if err := j.scanner.Err(); err != nil {
+		return Record{}, err
+	}
+	return Record{}, io.EOF
+}
+
+func (r Record) validate() error {
+	switch {
+	case r.Code == "":
+		return errors.New("code is required")
+	case r.OriginalURL == "":
+		return errors.New("original_url is required")
+	case r.ClickCount < 0:
+		return errors.New("click_count must not be negative")
+	}
+	switch r.Status {
+	case "", "active", "disabled", "deleted":
+	default:
+		return fmt.Errorf("unknown status %q", r.Status)
+	}
+	return nil
+}
+
+func parseTime(raw string) (*time.Time, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil, err
+	}
+	return &value, nil
+}
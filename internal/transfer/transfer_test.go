@@ -0,0 +1,143 @@
+package transfer
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readAll(t *testing.T, reader Reader) ([]Record, []*RecordError) {
+	t.Helper()
+	var (
+		records []Record
+		errs    []*RecordError
+	)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return records, errs
+		}
+		var recordErr *RecordError
+		if errors.As(err, &recordErr) {
+			errs = append(errs, recordErr)
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		records = append(records, record)
+	}
+}
+
+func TestCSVReader(t *testing.T) {
+	input := `code,original_url,created_at,expires_at,click_count
+abc,https://example.com/a,2024-01-02T03:04:05Z,,12
+,https://example.com/missing-code,,,
+def,https://example.com/d,,2030-01-01T00:00:00Z,
+ghi,https://example.com/g,not-a-time,,
+`
+	reader, err := NewReader(strings.NewReader(input), FormatCSV)
+	if err != nil {
+		t.Fatalf("failed to create reader: %v", err)
+	}
+
+	records, errs := readAll(t, reader)
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+	if records[0].ClickCount != 12 || records[0].CreatedAt == nil || records[0].ExpiresAt != nil {
+		t.Fatalf("unexpected first record: %+v", records[0])
+	}
+	if records[1].ExpiresAt == nil {
+		t.Fatalf("expected expires_at on second record")
+	}
+	if len(errs) != 2 || errs[0].Line != 3 || errs[1].Line != 5 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+}
+
+func TestCSVReader_MissingColumn(t *testing.T) {
+	if _, err := NewReader(strings.NewReader("code,url\n"), FormatCSV); err == nil {
+		t.Fatalf("expected error for missing original_url column")
+	}
+}
+
+func TestJSONLReader(t *testing.T) {
+	input := `{"code":"abc","original_url":"https://example.com/a","click_count":3}
+
+{"code":"bad","original_url":"https://example.com/b","click_count":-1}
+not json
+{"code":"def","original_url":"https://example.com/d","status":"disabled"}
+`
+	reader, err := NewReader(strings.NewReader(input), FormatJSONL)
+	if err != nil {
+		t.Fatalf("failed to create reader: %v", err)
+	}
+
+	records, errs := readAll(t, reader)
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+	if records[1].Status != "disabled" {
+		t.Fatalf("expected status to be read, got %q", records[1].Status)
+	}
+	if len(errs) != 2 || errs[0].Line != 3 || errs[1].Line != 4 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+}
+
+func TestFormatFromPath(t *testing.T) {
+	cases := map[string]Format{
+		"links.csv":    FormatCSV,
+		"links.jsonl":  FormatJSONL,
+		"links.ndjson": FormatJSONL,
+	}
+	for path, expected := range cases {
+		got, err := FormatFromPath(path)
+		if err != nil || got != expected {
+			t.Fatalf("%s: expected %s, got %s (%v)", path, expected, got, err)
+		}
+	}
+	if _, err := FormatFromPath("links.xml"); !errors.Is(err, ErrUnknownFormat) {
+		t.Fatalf("expected ErrUnknownFormat, got %v", err)
+	}
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	records := []Record{
+		{Code: "abc", OriginalURL: "https://example.com/a", Status: "active", CreatedAt: &created, ClickCount: 7},
+		{Code: "def", OriginalURL: "https://example.com/d,with,commas", Status: "deleted"},
+	}
+
+	for _, format := range []Format{FormatCSV, FormatJSONL} {
+		var buf bytes.Buffer
+		writer, err := NewWriter(&buf, format)
+		if err != nil {
+			t.Fatalf("%s: failed to create writer: %v", format, err)
+		}
+		for _, record := range records {
+			if err := writer.Write(record); err != nil {
+				t.Fatalf("%s: failed to write: %v", format, err)
+			}
+		}
+		if err := writer.Flush(); err != nil {
+			t.Fatalf("%s: failed to flush: %v", format, err)
+		}
+
+		reader, err := NewReader(&buf, format)
+		if err != nil {
+			t.Fatalf("%s: failed to create reader: %v", format, err)
+		}
+		got, errs := readAll(t, reader)
+		if len(errs) != 0 || len(got) != len(records) {
+			t.Fatalf("%s: expected %d records, got %d (%v)", format, len(records), len(got), errs)
+		}
+		if got[0].ClickCount != 7 || !got[0].CreatedAt.Equal(created) || got[1].OriginalURL != records[1].OriginalURL {
+			t.Fatalf("%s: round trip mismatch: %+v", format, got)
+		}
+	}
+}
@@ -0,0 +1 @@
+CREATE INDEX idx_created_at ON urls (created_at);
@@ -0,0 +1,3 @@
+ALTER TABLE urls
+  DROP CONSTRAINT IF EXISTS chk_urls_status,
+  DROP COLUMN IF EXISTS status;
@@ -0,0 +1,3 @@
+ALTER TABLE urls
+  ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'active',
+  ADD CONSTRAINT chk_urls_status CHECK (status IN ('active', 'disabled', 'deleted'));
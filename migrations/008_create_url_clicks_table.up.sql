@@ -0,0 +1,9 @@
+CREATE TABLE url_clicks (
+  id BIGSERIAL PRIMARY KEY,
+  url_id INT NOT NULL REFERENCES urls (id) ON DELETE CASCADE,
+  clicked_at TIMESTAMP NOT NULL DEFAULT NOW (),
+  referrer TEXT DEFAULT NULL,
+  user_agent TEXT DEFAULT NULL,
+  ip_hash VARCHAR(64) DEFAULT NULL,
+  request_id VARCHAR(64) DEFAULT NULL
+);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_url_clicks_url_id_clicked_at;
@@ -0,0 +1 @@
+CREATE INDEX idx_url_clicks_url_id_clicked_at ON url_clicks (url_id, clicked_at);
@@ -0,0 +1,11 @@
+CREATE TABLE url_clicks_hourly (
+  url_id INT NOT NULL REFERENCES urls (id) ON DELETE CASCADE,
+  bucket TIMESTAMP NOT NULL,
+  clicks BIGINT NOT NULL DEFAULT 0,
+  PRIMARY KEY (url_id, bucket)
+);
+
+INSERT INTO url_clicks_hourly (url_id, bucket, clicks)
+SELECT url_id, date_trunc('hour', clicked_at), COUNT(*)
+FROM url_clicks
+GROUP BY url_id, date_trunc('hour', clicked_at);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS url_stats_archive;
+DROP TABLE IF EXISTS urls_archive;
@@ -0,0 +1,18 @@
+CREATE TABLE urls_archive (
+  id INT PRIMARY KEY,
+  short_code VARCHAR(255) NOT NULL,
+  original_url TEXT NOT NULL,
+  status VARCHAR(16) NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  expires_at TIMESTAMP DEFAULT NULL,
+  archived_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_urls_archive_short_code ON urls_archive (short_code);
+CREATE INDEX idx_urls_archive_archived_at ON urls_archive (archived_at);
+
+CREATE TABLE url_stats_archive (
+  url_id INT PRIMARY KEY REFERENCES urls_archive (id) ON DELETE CASCADE,
+  click_count INT NOT NULL DEFAULT 0,
+  last_clicked_at TIMESTAMP DEFAULT NULL
+);